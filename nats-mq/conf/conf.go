@@ -44,23 +44,37 @@ const NATS2Topic = "NATS2Topic"
 
 // BridgeConfig holds the server configuration
 type BridgeConfig struct {
-	ReconnectInterval int // milliseconds
+	ReconnectInterval    int // milliseconds, the wait before the first attempt to restart a connector
+	MaxReconnectInterval int // milliseconds, the wait doubles after each failed attempt up to this, 0, or less than the interval, turns the backoff off
+	ReconnectJitter      int // percent, each wait is randomly changed by up to this much, 0 turns jitter off
+	MaxReconnectAttempts int // attempts to restart a connector before it is marked failed, 0 means no limit
+
+	FailFast bool // Stop the bridge if a connector can't start, by default the other connectors start and the failed ones are retried
 
 	NATS NATSConfig
 	STAN NATSStreamingConfig
 
+	NATSConnections []NATSConfig // Optional named connections, connectors use the shared NATS connection unless they name one of these
+
 	Logging    logging.Config
 	Monitoring MonitoringConfig
+	MQPool     MQPoolConfig
+	RateLimit  RateLimitConfig // Optional, shared by all of the connectors
+	Control    ControlConfig   // Optional, manage the bridge with NATS requests
+	Advisories AdvisoryConfig  // Optional, publish connector and connection state changes
+	HA         HAConfig        // Optional, run the connectors on one bridge at a time, the others wait as standbys
 
 	Connect []ConnectorConfig
 }
 
 // DefaultBridgeConfig generates a default configuration with
 // logging set to colors, time, debug and trace
-// reconnect interval set to 5000 ms (5s)
+// reconnect interval set to 5000 ms (5s), backing off to 60000 ms (1m) with 20% jitter
 func DefaultBridgeConfig() BridgeConfig {
 	return BridgeConfig{
-		ReconnectInterval: 5000,
+		ReconnectInterval:    5000,
+		MaxReconnectInterval: 60000,
+		ReconnectJitter:      20,
 		Logging: logging.Config{
 			Colors: true,
 			Time:   true,
@@ -73,6 +87,10 @@ func DefaultBridgeConfig() BridgeConfig {
 			MaxPubAcksInflight: stan.DefaultMaxPubAcksInflight,
 			ConnectWait:        2000,
 		},
+		MQPool: MQPoolConfig{
+			MaxShared:           10,
+			HealthCheckInterval: 30000,
+		},
 	}
 }
 
@@ -81,6 +99,9 @@ type TLSConf struct {
 	Key  string
 	Cert string
 	Root string
+
+	InsecureSkipVerify bool   // Client side only, used for NATS connections
+	ServerName         string // Client side only, overrides the server name used to verify NATS certificates
 }
 
 // MonitoringConfig is used to define the host and port for monitoring
@@ -88,18 +109,94 @@ type TLSConf struct {
 // enabled. By default the ports are 0 and monitoring is disabled. Set
 // a port to -1 to use ephemeral ports.
 // Similarly the host defaults to "" which indicates all network interfaces.
+// The admin endpoints are only available if an admin token is set.
 type MonitoringConfig struct {
 	HTTPHost  string
 	HTTPPort  int
 	HTTPSPort int
 	TLS       TLSConf
+
+	AdminToken   string // Optional, enables the admin endpoints, requests must send it as a bearer token
+	ReadyPercent int    // Optional, the percentage of connectors, not counting paused ones, that have to be running for /readyz to report ready, 0 means all of them
+}
+
+// ControlConfig turns on the NATS request-reply services used to inspect and manage the bridge
+// The services are off unless a name is set.
+type ControlConfig struct {
+	Name   string // Identifies this bridge in the service subjects, a single subject token
+	Prefix string // Optional, the subject prefix for the services, defaults to $MQBRIDGE
+}
+
+// AdvisoryConfig turns on the JSON advisories the bridge publishes when a connector or connection changes state
+// Advisories are off unless a subject is set.
+type AdvisoryConfig struct {
+	Subject string // The NATS subject advisories are published to, on the shared connection
+}
+
+// HAConfig turns on active/passive high availability, bridges with the same bucket and key elect a leader
+// with a lease in a NATS key value bucket and only the leader starts its connectors. HA is off unless a bucket is set.
+type HAConfig struct {
+	Bucket    string // The key value bucket holding the lease, created with the lease ttl if it doesn't exist
+	Key       string // Optional, the lease key, bridges sharing a bucket need different keys to form separate groups, defaults to leader
+	Name      string // Optional, identifies this bridge in the lease, defaults to the control name or the host name and process id
+	LeaseTTL  int    // milliseconds, a standby takes over once the lease hasn't been renewed for this long, 0 means 10000
+	Heartbeat int    // milliseconds, how often the leader renews the lease and standbys try to take it, 0 means a third of the lease ttl
+}
+
+// MQPoolConfig controls how connectors share queue manager connections
+// Connectors that only put messages outside of a unit of work share connections, connectors
+// that get messages always have their own connection.
+type MQPoolConfig struct {
+	DisableSharing      bool // Give every connector its own connection
+	MaxConnections      int  // Per queue manager configuration, 0 means no limit
+	MaxShared           int  // Connectors per shared connection, 0 means no limit
+	HealthCheckInterval int  // milliseconds, 0 disables health checks
+}
+
+// RateLimitConfig caps the rate messages move through a connector, or through the whole bridge
+// Zero means no limit, the schedule replaces the limits while one of its windows is active.
+type RateLimitConfig struct {
+	MessagesPerSecond int
+	BytesPerSecond    int
+	Schedule          []RateLimitWindow
+}
+
+// RateLimitWindow sets the limits for part of each day, start and end are local times
+// formatted as HH:MM, windows that end before they start run over midnight
+type RateLimitWindow struct {
+	Start             string
+	End               string
+	MessagesPerSecond int
+	BytesPerSecond    int
+}
+
+// QueueMonitorConfig controls how often a connector checks the depth and age of its queue, and the
+// pending messages of its NATS subscription, and when it publishes alerts
+type QueueMonitorConfig struct {
+	Interval       int    // milliseconds between checks, 0 turns monitoring off
+	DepthThreshold int    // Optional, alert when the queue depth reaches this, 0 means no alert
+	AgeThreshold   int    // Optional, milliseconds, alert when the oldest message is this old, 0 means no alert
+	AlertSubject   string // Required with a threshold, the NATS subject alerts are published to
+}
+
+// DedupConfig drops messages headed to MQ with the same id as a message put within the window
+// Deduplication is off unless a key is set.
+type DedupConfig struct {
+	Key        string // msgid, correlid, header, property or sequence
+	Name       string // The header or property name, used with the header and property keys
+	Window     int    // milliseconds, how long ids are remembered
+	MaxEntries int    // Used by the in memory store, the number of ids remembered
+	Bucket     string // Optional, a NATS key value bucket used instead of the in memory store
 }
 
 // MQConfig configuration for an MQ Connection
 type MQConfig struct {
-	ConnectionName string
-	ChannelName    string
-	QueueManager   string
+	ConnectionName  string
+	ConnectionNames []string // Optional, the instances of a multi-instance queue manager, tried in order after ConnectionName
+	ChannelName     string
+	QueueManager    string
+	CCDTURL         string // Optional, a client channel definition table file or URL, used instead of the connection and channel names
+	Reconnect       string // Optional, MQ client reconnect, disabled, qmgr or any, the default uses the channel's setting
 
 	UserName string
 	Password string
@@ -112,6 +209,8 @@ type MQConfig struct {
 
 // NATSConfig configuration for a NATS connection
 type NATSConfig struct {
+	Name    string // Used for named connections, referenced by connectors
+	Account string // Used for named connections, the account the credentials belong to, for monitoring
 	Servers []string
 
 	ConnectTimeout int //milliseconds
@@ -122,6 +221,11 @@ type NATSConfig struct {
 	Username  string
 	Password  string
 	CredsFile string
+
+	Token        string
+	NKeySeedFile string // Path to a file containing an nkey user seed
+	JWT          string // User JWT, requires Seed
+	Seed         string // The nkey seed used to sign the nonce for JWT
 }
 
 // NATSStreamingConfig configuration for a STAN connection
@@ -145,16 +249,67 @@ type ConnectorConfig struct {
 	StartAtSequence int64  // Start position for stan connection, -1 means StartWithLastReceived, 0 means DeliverAllAvailable (default)
 	StartAtTime     int64  // Start time, as Unix, time takes precedence over sequence
 
-	Subject   string // Used for nats connections
-	NatsQueue string // Optional, used for nats connections
+	Subject        string // Used for nats connections
+	NatsQueue      string // Optional, used for nats connections
+	NATSConnection string // Optional, the name of the NATS connection to use, defaults to the shared connection
+
+	Workers       int    // Optional, the number of workers, each with its own MQ connection, 0 or 1 means no workers
+	OrderBy       string // Optional, keep messages in order across workers by subject, correlid, groupid or property
+	OrderProperty string // Used with OrderBy property, the name of the property
+
+	PendingMsgsLimit  int // Optional, pending message limit for nats subscriptions, 0 uses the NATS default
+	PendingBytesLimit int // Optional, pending byte limit for nats subscriptions, 0 uses the NATS default
+	MaxInflight       int // Optional, maximum unacknowledged messages for stan subscriptions, 0 uses the streaming default
+	MaxBuffered       int // Optional, pause reading from MQ while the NATS connection buffers more bytes than this, 0 turns this off
+
+	RateLimit RateLimitConfig    // Optional, limits the rate messages move through this connector
+	Monitor   QueueMonitorConfig // Optional, checks the depth of the connector's queue
 
 	MQ    MQConfig // Connection information, nats connections are shared
 	Topic string   // Used for the mq side of things
 	Queue string
 
+	Targets       []MQConfig // Used for NATS2Queue connectors, spread the messages across these queue managers instead of using MQ
+	Balance       string     // Used with targets, roundrobin (the default), leastdepth or sticky, sticky keeps messages with the same orderby key on one target
+	DepthInterval int        // milliseconds, how often leastdepth checks the queue depths, 0 means 1000
+
 	UsePolling          bool // use polling vs callbacks when listening to MQ (the default is callbacks)
 	IncomingBufferSize  int  // buffer size for polling
 	IncomingMessageWait int  // wait time for polling in ms
 
-	ExcludeHeaders bool //exclude headers, and just send the body to/from nats messages
+	ExcludeHeaders  bool //exclude headers, and just send the body to/from nats messages
+	MsgTTL          bool // Used for mq to nats connectors, add the JetStream Nats-TTL header to messages that expire
+	MsgIDHeader     bool // Used for mq to nats connectors, add the JetStream Nats-Msg-Id header from the MQ MsgId
+	TimestampHeader bool // Used for mq to nats connectors, add the MQ-Timestamp header with the time the message was put to MQ
+
+	Dedup   DedupConfig // Optional, used for puts to mq, drops messages that were already put
+	Confirm bool        // Used for nats to mq connectors, reply to requests with the result of the put
+
+	Persistence string // Used for puts to mq, persistent, nonpersistent or queue (the default) to use the queue's setting
+	Priority    int    // Used for puts to mq, 1-9, 0 means use the priority from the message or the queue default
+	Expiry      int    // Used for puts to mq, time to live in milliseconds, rounded up to tenths of a second, 0 keeps the expiry from the message, unlimited if it doesn't have one
+	Format      string // Used for puts to mq, the MQ format name, i.e. MQSTR, if not set by the message
+
+	PutRules []PutRule // Optional overrides for the put settings above, the first matching rule is used
+
+	PutRetries   int // Used for puts to mq, retries for puts that fail with a transient reason, like a full queue, 0 turns retries off
+	PutRetryWait int // milliseconds, the wait between put retries, 0 means 100
+
+	GenerateReports bool   // Used for mq to nats connectors, put the COD and exception reports requested by MQ messages
+	ReportQueue     string // Used for nats to mq connectors, the default reply to queue for reports generated by MQ
+	ReportSubject   string // Used for nats to mq connectors, reports arriving on the ReportQueue are published to this subject
+}
+
+// PutRule overrides the persistence, priority, expiry and format for messages put to mq
+// A rule matches if the subject matches, supporting the * and > wildcards, and the message has
+// the property with the specified value. Empty subject, property or value settings are ignored.
+type PutRule struct {
+	Subject  string // The NATS subject or streaming channel the message arrived on
+	Property string // A bridge message property name, only available when headers are included
+	Value    string // The property value, compared as a string
+
+	Persistence string
+	Priority    int
+	Expiry      int
+	Format      string
 }
@@ -0,0 +1,87 @@
+// Copyright 2012-2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"time"
+)
+
+// Enabled returns true if any limit is set, including in the schedule
+func (config RateLimitConfig) Enabled() bool {
+	return config.MessagesPerSecond > 0 || config.BytesPerSecond > 0 || len(config.Schedule) > 0
+}
+
+// Limits returns the limits at t, from the first window that contains t or the default limits
+func (config RateLimitConfig) Limits(t time.Time) (int, int) {
+	for _, window := range config.Schedule {
+		if window.Contains(t) {
+			return window.MessagesPerSecond, window.BytesPerSecond
+		}
+	}
+	return config.MessagesPerSecond, config.BytesPerSecond
+}
+
+// Validate checks that the limits are positive and the schedule's times can be parsed
+func (config RateLimitConfig) Validate() error {
+	if config.MessagesPerSecond < 0 || config.BytesPerSecond < 0 {
+		return fmt.Errorf("rate limits can't be negative")
+	}
+
+	for _, window := range config.Schedule {
+		if window.MessagesPerSecond < 0 || window.BytesPerSecond < 0 {
+			return fmt.Errorf("rate limits can't be negative")
+		}
+
+		if _, err := parseTimeOfDay(window.Start); err != nil {
+			return err
+		}
+
+		if _, err := parseTimeOfDay(window.End); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Contains returns true if the time of day of t is in the window, invalid windows never match
+func (window RateLimitWindow) Contains(t time.Time) bool {
+	start, err := parseTimeOfDay(window.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := parseTimeOfDay(window.End)
+	if err != nil {
+		return false
+	}
+
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if start <= end {
+		return now >= start && now < end
+	}
+
+	return now >= start || now < end // runs over midnight
+}
+
+// parseTimeOfDay converts HH:MM to the time since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2012-2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitSchedule(t *testing.T) {
+	config := RateLimitConfig{
+		MessagesPerSecond: 100,
+		Schedule: []RateLimitWindow{
+			{Start: "09:00", End: "17:00", MessagesPerSecond: 10, BytesPerSecond: 1024},
+			{Start: "22:00", End: "02:00", MessagesPerSecond: 1000},
+		},
+	}
+	require.NoError(t, config.Validate())
+	require.True(t, config.Enabled())
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2019, 6, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	msgs, bytes := config.Limits(at(12, 0))
+	require.Equal(t, 10, msgs)
+	require.Equal(t, 1024, bytes)
+
+	msgs, _ = config.Limits(at(17, 0))
+	require.Equal(t, 100, msgs)
+
+	msgs, _ = config.Limits(at(23, 30))
+	require.Equal(t, 1000, msgs)
+
+	msgs, _ = config.Limits(at(1, 59))
+	require.Equal(t, 1000, msgs)
+
+	msgs, bytes = config.Limits(at(6, 0))
+	require.Equal(t, 100, msgs)
+	require.Equal(t, 0, bytes)
+}
+
+func TestInvalidRateLimit(t *testing.T) {
+	require.False(t, RateLimitConfig{}.Enabled())
+	require.Error(t, RateLimitConfig{MessagesPerSecond: -1}.Validate())
+	require.Error(t, RateLimitConfig{Schedule: []RateLimitWindow{{Start: "9am", End: "17:00"}}}.Validate())
+	require.Error(t, RateLimitConfig{Schedule: []RateLimitWindow{{Start: "09:00", End: "25:00"}}}.Validate())
+
+	config := DefaultBridgeConfig()
+	config.Connect = []ConnectorConfig{{Type: NATS2Queue, RateLimit: RateLimitConfig{BytesPerSecond: -5}}}
+	require.Error(t, config.Validate())
+}
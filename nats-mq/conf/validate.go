@@ -0,0 +1,214 @@
+// Copyright 2012-2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate checks the configuration for settings that can't be used together
+func (config BridgeConfig) Validate() error {
+	if err := config.NATS.Validate(); err != nil {
+		return fmt.Errorf("invalid NATS configuration, %s", err.Error())
+	}
+
+	names := map[string]bool{}
+
+	for _, nc := range config.NATSConnections {
+		if nc.Name == "" {
+			return fmt.Errorf("named NATS connections require a name")
+		}
+
+		if names[nc.Name] {
+			return fmt.Errorf("duplicate NATS connection name %q", nc.Name)
+		}
+		names[nc.Name] = true
+
+		if err := nc.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration for NATS connection %s, %s", nc.Name, err.Error())
+		}
+	}
+
+	if err := config.validateReconnect(); err != nil {
+		return fmt.Errorf("invalid reconnect settings, %s", err.Error())
+	}
+
+	if config.Monitoring.ReadyPercent < 0 || config.Monitoring.ReadyPercent > 100 {
+		return fmt.Errorf("invalid monitoring configuration, readypercent must be between 0 and 100")
+	}
+
+	if err := config.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("invalid rate limit, %s", err.Error())
+	}
+
+	if err := config.Control.Validate(); err != nil {
+		return fmt.Errorf("invalid control configuration, %s", err.Error())
+	}
+
+	if config.Advisories.Subject != "" && !validSubject(config.Advisories.Subject) {
+		return fmt.Errorf("invalid advisory subject %q", config.Advisories.Subject)
+	}
+
+	if err := config.HA.Validate(); err != nil {
+		return fmt.Errorf("invalid ha configuration, %s", err.Error())
+	}
+
+	for _, c := range config.Connect {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateReconnect checks the connector restart settings
+func (config BridgeConfig) validateReconnect() error {
+	if config.ReconnectInterval < 0 || config.MaxReconnectInterval < 0 || config.MaxReconnectAttempts < 0 {
+		return fmt.Errorf("intervals and attempts can't be negative")
+	}
+
+	if config.ReconnectJitter < 0 || config.ReconnectJitter > 100 {
+		return fmt.Errorf("reconnectjitter must be between 0 and 100")
+	}
+
+	return nil
+}
+
+// Validate checks the settings for a single connector that can be checked without a running bridge
+func (config ConnectorConfig) Validate() error {
+	if err := config.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("invalid rate limit for %s connector %q, %s", config.Type, config.ID, err.Error())
+	}
+
+	return nil
+}
+
+// Validate checks that at most one authentication method is configured and that
+// the settings for that method are complete
+func (config NATSConfig) Validate() error {
+	methods := []string{}
+
+	if config.Username != "" {
+		methods = append(methods, "username")
+	}
+
+	if config.Token != "" {
+		methods = append(methods, "token")
+	}
+
+	if config.CredsFile != "" {
+		methods = append(methods, "credsfile")
+	}
+
+	if config.NKeySeedFile != "" {
+		methods = append(methods, "nkeyseedfile")
+	}
+
+	if config.JWT != "" {
+		methods = append(methods, "jwt")
+	}
+
+	if len(methods) > 1 {
+		return fmt.Errorf("conflicting authentication methods %s, only one can be used", strings.Join(methods, ", "))
+	}
+
+	if config.Password != "" && config.Username == "" {
+		return fmt.Errorf("password requires a username")
+	}
+
+	if config.JWT != "" && config.Seed == "" {
+		return fmt.Errorf("jwt requires a seed")
+	}
+
+	if config.Seed != "" && config.JWT == "" {
+		return fmt.Errorf("seed requires a jwt")
+	}
+
+	if (config.TLS.Cert == "") != (config.TLS.Key == "") {
+		return fmt.Errorf("tls client certificates require both a cert and a key")
+	}
+
+	return nil
+}
+
+// Validate checks that the bridge name can be used as a subject token
+func (config ControlConfig) Validate() error {
+	if config.Name == "" {
+		if config.Prefix != "" {
+			return fmt.Errorf("a prefix requires a name")
+		}
+		return nil
+	}
+
+	if strings.ContainsAny(config.Name, ".*> \t\r\n") {
+		return fmt.Errorf("name %q can't contain dots, wildcards or whitespace", config.Name)
+	}
+
+	if config.Prefix != "" && !validSubject(config.Prefix) {
+		return fmt.Errorf("prefix %q isn't a valid subject", config.Prefix)
+	}
+
+	return nil
+}
+
+// Validate checks that the bucket and key can be used for the lease and that the leader renews it before it expires
+func (config HAConfig) Validate() error {
+	if config.Bucket == "" {
+		if config.Key != "" || config.Name != "" || config.LeaseTTL != 0 || config.Heartbeat != 0 {
+			return fmt.Errorf("ha settings require a bucket")
+		}
+		return nil
+	}
+
+	if strings.Trim(config.Bucket, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-") != "" {
+		return fmt.Errorf("bucket %q can only contain letters, numbers, dashes and underscores", config.Bucket)
+	}
+
+	if config.Key != "" && (strings.Trim(config.Key, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-/=.") != "" ||
+		strings.HasPrefix(config.Key, ".") || strings.HasSuffix(config.Key, ".")) {
+		return fmt.Errorf("key %q isn't a valid key value key", config.Key)
+	}
+
+	if config.LeaseTTL < 0 || config.Heartbeat < 0 {
+		return fmt.Errorf("leasettl and heartbeat can't be negative")
+	}
+
+	ttl := config.LeaseTTL
+	if ttl == 0 {
+		ttl = 10000
+	}
+
+	if config.Heartbeat >= ttl {
+		return fmt.Errorf("heartbeat must be shorter than the lease ttl, %d milliseconds", ttl)
+	}
+
+	return nil
+}
+
+// validSubject returns true if subject can be published to, it can't contain wildcards or whitespace or empty tokens
+func validSubject(subject string) bool {
+	if subject == "" || strings.ContainsAny(subject, "*> \t\r\n") {
+		return false
+	}
+
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return false
+		}
+	}
+
+	return true
+}
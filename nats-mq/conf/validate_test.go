@@ -0,0 +1,140 @@
+// Copyright 2012-2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidNATSAuthentication(t *testing.T) {
+	require.NoError(t, NATSConfig{}.Validate())
+	require.NoError(t, NATSConfig{Username: "user", Password: "pass"}.Validate())
+	require.NoError(t, NATSConfig{Token: "secret"}.Validate())
+	require.NoError(t, NATSConfig{CredsFile: "user.creds"}.Validate())
+	require.NoError(t, NATSConfig{NKeySeedFile: "user.nk"}.Validate())
+	require.NoError(t, NATSConfig{JWT: "jwt", Seed: "seed"}.Validate())
+	require.NoError(t, NATSConfig{TLS: TLSConf{Cert: "cert.pem", Key: "key.pem", InsecureSkipVerify: true}}.Validate())
+}
+
+func TestInvalidNATSAuthentication(t *testing.T) {
+	require.Error(t, NATSConfig{Username: "user", Token: "secret"}.Validate())
+	require.Error(t, NATSConfig{CredsFile: "user.creds", NKeySeedFile: "user.nk"}.Validate())
+	require.Error(t, NATSConfig{Token: "secret", JWT: "jwt", Seed: "seed"}.Validate())
+	require.Error(t, NATSConfig{Password: "pass"}.Validate())
+	require.Error(t, NATSConfig{JWT: "jwt"}.Validate())
+	require.Error(t, NATSConfig{Seed: "seed"}.Validate())
+	require.Error(t, NATSConfig{TLS: TLSConf{Cert: "cert.pem"}}.Validate())
+}
+
+func TestNATSConnectionNames(t *testing.T) {
+	config := DefaultBridgeConfig()
+	config.NATSConnections = []NATSConfig{{Name: "a"}, {Name: "b"}}
+	require.NoError(t, config.Validate())
+
+	config.NATSConnections = []NATSConfig{{Name: "a"}, {Name: "a"}}
+	require.Error(t, config.Validate())
+
+	config.NATSConnections = []NATSConfig{{}}
+	require.Error(t, config.Validate())
+
+	config.NATSConnections = []NATSConfig{{Name: "a", Token: "secret", Username: "user"}}
+	require.Error(t, config.Validate())
+}
+
+func TestControlNames(t *testing.T) {
+	require.NoError(t, ControlConfig{}.Validate())
+	require.NoError(t, ControlConfig{Name: "bridge-1"}.Validate())
+	require.NoError(t, ControlConfig{Name: "bridge-1", Prefix: "OPS.MQBRIDGE"}.Validate())
+
+	require.Error(t, ControlConfig{Prefix: "OPS"}.Validate())
+	require.Error(t, ControlConfig{Name: "bridge.1"}.Validate())
+	require.Error(t, ControlConfig{Name: "*"}.Validate())
+	require.Error(t, ControlConfig{Name: "bridge", Prefix: "OPS.>"}.Validate())
+	require.Error(t, ControlConfig{Name: "bridge", Prefix: "OPS."}.Validate())
+}
+
+func TestAdvisorySubject(t *testing.T) {
+	config := DefaultBridgeConfig()
+	require.NoError(t, config.Validate())
+
+	config.Advisories.Subject = "mqbridge.advisories"
+	require.NoError(t, config.Validate())
+
+	for _, subject := range []string{"advisories.*", "advisories.>", "advisories..events", "advisories.", "bad subject"} {
+		config.Advisories.Subject = subject
+		require.Error(t, config.Validate(), subject)
+	}
+}
+
+func TestHASettings(t *testing.T) {
+	require.NoError(t, HAConfig{}.Validate())
+	require.NoError(t, HAConfig{Bucket: "mq_bridge-ha"}.Validate())
+	require.NoError(t, HAConfig{Bucket: "ha", Key: "orders.leader", Name: "bridge-1", LeaseTTL: 6000, Heartbeat: 2000}.Validate())
+	require.NoError(t, HAConfig{Bucket: "ha", Heartbeat: 9999}.Validate())
+
+	require.Error(t, HAConfig{Key: "leader"}.Validate())
+	require.Error(t, HAConfig{LeaseTTL: 5000}.Validate())
+	require.Error(t, HAConfig{Bucket: "ha.bucket"}.Validate())
+	require.Error(t, HAConfig{Bucket: "ha", Key: "leader.*"}.Validate())
+	require.Error(t, HAConfig{Bucket: "ha", Key: ".leader"}.Validate())
+	require.Error(t, HAConfig{Bucket: "ha", LeaseTTL: -1}.Validate())
+	require.Error(t, HAConfig{Bucket: "ha", Heartbeat: 10000}.Validate())
+	require.Error(t, HAConfig{Bucket: "ha", LeaseTTL: 3000, Heartbeat: 3000}.Validate())
+
+	config := DefaultBridgeConfig()
+	config.HA.Bucket = "bad bucket"
+	require.Error(t, config.Validate())
+}
+
+func TestReadyPercent(t *testing.T) {
+	config := DefaultBridgeConfig()
+
+	for _, percent := range []int{0, 50, 100} {
+		config.Monitoring.ReadyPercent = percent
+		require.NoError(t, config.Validate())
+	}
+
+	for _, percent := range []int{-1, 101} {
+		config.Monitoring.ReadyPercent = percent
+		require.Error(t, config.Validate())
+	}
+}
+
+func TestReconnectSettings(t *testing.T) {
+	config := DefaultBridgeConfig()
+	require.NoError(t, config.Validate())
+
+	config.MaxReconnectInterval = 0
+	config.ReconnectJitter = 0
+	config.MaxReconnectAttempts = 10
+	require.NoError(t, config.Validate())
+
+	config.ReconnectInterval = 120000 // longer than the default maximum, there is no backoff
+	config.MaxReconnectInterval = 60000
+	require.NoError(t, config.Validate())
+
+	invalid := []func(*BridgeConfig){
+		func(c *BridgeConfig) { c.ReconnectInterval = -1 },
+		func(c *BridgeConfig) { c.MaxReconnectInterval = -1 },
+		func(c *BridgeConfig) { c.MaxReconnectAttempts = -1 },
+		func(c *BridgeConfig) { c.ReconnectJitter = 101 },
+	}
+
+	for _, change := range invalid {
+		config := DefaultBridgeConfig()
+		change(&config)
+		require.Error(t, config.Validate())
+	}
+}
@@ -0,0 +1,412 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+)
+
+// AdminPath is the root of the admin endpoints, they are only available if an admin token is configured
+const AdminPath = "/admin/connectors"
+
+// maxAdminRequestSize limits the size of posted connector configurations
+const maxAdminRequestSize = 1 << 20
+
+// Connector states, reported in the connector statistics and by the admin endpoints
+const (
+	ConnectorRunning = "running"
+	ConnectorStopped = "stopped" // shut down by an error, the bridge will try to restart it
+	ConnectorPaused  = "paused"
+	ConnectorFailed  = "failed" // the bridge gave up trying to restart it
+)
+
+var (
+	errBridgeStopped     = errors.New("the bridge isn't running")
+	errBridgeStandby     = errors.New("the bridge is a standby, connectors only run on the leader")
+	errConnectorNotFound = errors.New("unknown connector")
+	errConnectorExists   = errors.New("a connector with that id already exists")
+	errConnectorPaused   = errors.New("the connector is paused, resume it instead")
+	errInvalidConnector  = errors.New("invalid connector configuration")
+)
+
+// ConnectorInfo describes a connector for the admin endpoints
+type ConnectorInfo struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	State string `json:"state"`
+}
+
+// AdminError is returned by the admin endpoints, and the control services, when a request fails
+type AdminError struct {
+	Error string `json:"error"`
+}
+
+func connectorInfo(connector Connector) ConnectorInfo {
+	stats := connector.Stats()
+	return ConnectorInfo{
+		ID:    stats.ID,
+		Name:  stats.Name,
+		Type:  stats.Type,
+		State: stats.State,
+	}
+}
+
+// findConnector returns the connector with the id, or nil
+func (bridge *BridgeServer) findConnector(id string) Connector {
+	for _, c := range bridge.currentConnectors() {
+		if c.ID() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// Connectors describes the running, stopped and paused connectors
+func (bridge *BridgeServer) Connectors() []ConnectorInfo {
+	infos := []ConnectorInfo{}
+	for _, c := range bridge.currentConnectors() {
+		infos = append(infos, connectorInfo(c))
+	}
+	return infos
+}
+
+// AddConnector creates and starts a connector while the bridge is running, the connector isn't
+// added to the configuration, so it is gone if the bridge restarts or reloads its configuration
+func (bridge *BridgeServer) AddConnector(config conf.ConnectorConfig) (ConnectorInfo, error) {
+	if err := config.Validate(); err != nil {
+		return ConnectorInfo{}, fmt.Errorf("%w, %s", errInvalidConnector, err.Error())
+	}
+
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	if !bridge.running {
+		return ConnectorInfo{}, errBridgeStopped
+	}
+
+	if bridge.ha.isStandby() {
+		return ConnectorInfo{}, errBridgeStandby
+	}
+
+	if config.ID != "" && bridge.findConnector(config.ID) != nil {
+		return ConnectorInfo{}, errConnectorExists
+	}
+
+	connector, err := CreateConnector(config, bridge)
+	if err != nil {
+		return ConnectorInfo{}, fmt.Errorf("%w, %s", errInvalidConnector, err.Error())
+	}
+
+	// add before starting so that errors while it starts are handled like any other
+	bridge.connectorsLock.Lock()
+	bridge.connectors = append(bridge.connectors, connector)
+	bridge.connectorsLock.Unlock()
+
+	if err := connector.Start(); err != nil {
+		bridge.removeConnector(connector)
+		return ConnectorInfo{}, fmt.Errorf("unable to start %s, %s", connector.String(), err.Error())
+	}
+
+	bridge.logger.Noticef("added connector %s", connector.String())
+	bridge.connectorAdvisory(ConnectorStartedAdvisory, connector, nil)
+	return connectorInfo(connector), nil
+}
+
+// RemoveConnector shuts down a connector and removes it from the bridge
+func (bridge *BridgeServer) RemoveConnector(id string) error {
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	connector := bridge.findConnector(id)
+	if connector == nil {
+		return errConnectorNotFound
+	}
+
+	if !bridge.running {
+		return errBridgeStopped
+	}
+
+	bridge.removeConnector(connector)
+	bridge.logger.Noticef("removed connector %s", connector.String())
+	return nil
+}
+
+// removeConnector takes the connector out of the list, the reconnect map and the reply to info, then shuts it down if it is running
+func (bridge *BridgeServer) removeConnector(connector Connector) {
+	bridge.connectorsLock.Lock()
+	for i, c := range bridge.connectors {
+		if c == connector {
+			bridge.connectors = append(bridge.connectors[:i], bridge.connectors[i+1:]...)
+			break
+		}
+	}
+	bridge.connectorsLock.Unlock()
+
+	bridge.unregisterReplyInfo(connector.ID())
+
+	bridge.reconnectLock.Lock()
+	_, stopped := bridge.reconnect[connector.ID()]
+	bridge.clearReconnect(connector.ID())
+	bridge.reconnectLock.Unlock()
+
+	if stopped || connector.Paused() {
+		return
+	}
+
+	err := connector.Shutdown()
+	if err != nil {
+		bridge.logger.Noticef("error shutting down connector %s, %s", connector.String(), err.Error())
+	}
+
+	bridge.connectorAdvisory(ConnectorStoppedAdvisory, connector, err)
+}
+
+// PauseConnector shuts down a connector until it is resumed
+func (bridge *BridgeServer) PauseConnector(id string) (ConnectorInfo, error) {
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	connector := bridge.findConnector(id)
+	if connector == nil {
+		return ConnectorInfo{}, errConnectorNotFound
+	}
+
+	if !bridge.running {
+		return ConnectorInfo{}, errBridgeStopped
+	}
+
+	if bridge.ha.isStandby() {
+		return ConnectorInfo{}, errBridgeStandby
+	}
+
+	// a connector waiting to be restarted is already shut down, Pause leaves it that way
+	bridge.reconnectLock.Lock()
+	bridge.clearReconnect(id)
+	bridge.reconnectLock.Unlock()
+
+	err := connector.Pause()
+	if err != nil {
+		bridge.logger.Noticef("error pausing connector %s, %s", connector.String(), err.Error())
+	}
+
+	bridge.connectorAdvisory(ConnectorPausedAdvisory, connector, err)
+
+	return connectorInfo(connector), nil
+}
+
+// ResumeConnector starts a paused connector, if it can't start the bridge keeps trying
+func (bridge *BridgeServer) ResumeConnector(id string) (ConnectorInfo, error) {
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	connector := bridge.findConnector(id)
+	if connector == nil {
+		return ConnectorInfo{}, errConnectorNotFound
+	}
+
+	if !bridge.running {
+		return ConnectorInfo{}, errBridgeStopped
+	}
+
+	if bridge.ha.isStandby() {
+		return ConnectorInfo{}, errBridgeStandby
+	}
+
+	if err := connector.Resume(); err != nil {
+		bridge.reconnectLock.Lock()
+		defer bridge.reconnectLock.Unlock()
+		return connectorInfo(connector), bridge.restartLater(connector, err)
+	}
+
+	bridge.connectorAdvisory(ConnectorResumedAdvisory, connector, nil)
+	return connectorInfo(connector), nil
+}
+
+// RestartConnector shuts down a connector, unless it is already waiting to be restarted, and starts it again,
+// this also restarts connectors the bridge gave up on
+func (bridge *BridgeServer) RestartConnector(id string) (ConnectorInfo, error) {
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	connector := bridge.findConnector(id)
+	if connector == nil {
+		return ConnectorInfo{}, errConnectorNotFound
+	}
+
+	if !bridge.running {
+		return ConnectorInfo{}, errBridgeStopped
+	}
+
+	if bridge.ha.isStandby() {
+		return ConnectorInfo{}, errBridgeStandby
+	}
+
+	if connector.Paused() {
+		return connectorInfo(connector), errConnectorPaused
+	}
+
+	bridge.reconnectLock.Lock()
+	defer bridge.reconnectLock.Unlock()
+
+	bridge.logger.Noticef("restarting connector %s", connector.String())
+
+	if _, stopped := bridge.reconnect[id]; !stopped {
+		err := connector.Shutdown()
+		if err != nil {
+			bridge.logger.Noticef("error shutting down connector %s, %s", connector.String(), err.Error())
+		}
+		bridge.connectorAdvisory(ConnectorStoppedAdvisory, connector, err)
+	}
+
+	if err := connector.Start(); err != nil {
+		return connectorInfo(connector), bridge.restartLater(connector, err)
+	}
+
+	bridge.clearReconnect(id)
+	bridge.connectorAdvisory(ConnectorStartedAdvisory, connector, nil)
+	return connectorInfo(connector), nil
+}
+
+// restartLater shuts down a connector that failed to start and hands it to the reconnect timer
+// expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) restartLater(connector Connector, err error) error {
+	bridge.logger.Noticef("error starting connector %s, %s", connector.String(), err.Error())
+
+	if err := connector.Shutdown(); err != nil {
+		bridge.logger.Noticef("error shutting down connector %s, %s", connector.String(), err.Error())
+	}
+
+	bridge.scheduleReconnect(connector, err)
+
+	return fmt.Errorf("unable to start %s, the bridge will keep trying, %s", connector.String(), err.Error())
+}
+
+// registerAdminHandlers adds the admin endpoints to the monitoring server, if an admin token is configured
+func (bridge *BridgeServer) registerAdminHandlers(mux *http.ServeMux) {
+	if bridge.config.Monitoring.AdminToken == "" {
+		return
+	}
+
+	mux.HandleFunc("GET "+AdminPath, bridge.adminHandler(bridge.HandleListConnectors))
+	mux.HandleFunc("POST "+AdminPath, bridge.adminHandler(bridge.HandleAddConnector))
+	mux.HandleFunc("DELETE "+AdminPath+"/{id}", bridge.adminHandler(bridge.HandleRemoveConnector))
+	mux.HandleFunc("POST "+AdminPath+"/{id}/pause", bridge.adminHandler(bridge.connectorAction(bridge.PauseConnector)))
+	mux.HandleFunc("POST "+AdminPath+"/{id}/resume", bridge.adminHandler(bridge.connectorAction(bridge.ResumeConnector)))
+	mux.HandleFunc("POST "+AdminPath+"/{id}/restart", bridge.adminHandler(bridge.connectorAction(bridge.RestartConnector)))
+}
+
+// adminHandler counts admin requests and rejects those without the admin token
+func (bridge *BridgeServer) adminHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bridge.statsLock.Lock()
+		bridge.httpReqStats[AdminPath]++
+		bridge.statsLock.Unlock()
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		expected := bridge.config.Monitoring.AdminToken
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONResponse(w, http.StatusUnauthorized, AdminError{Error: "admin token required"})
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// HandleListConnectors returns the id, name, type and state of each connector
+func (bridge *BridgeServer) HandleListConnectors(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, bridge.Connectors())
+}
+
+// HandleAddConnector creates and starts a connector from the posted JSON connector configuration
+func (bridge *BridgeServer) HandleAddConnector(w http.ResponseWriter, r *http.Request) {
+	config := conf.ConnectorConfig{}
+	body := http.MaxBytesReader(w, r.Body, maxAdminRequestSize)
+	if err := json.NewDecoder(body).Decode(&config); err != nil {
+		writeAdminError(w, fmt.Errorf("%w, %s", errInvalidConnector, err.Error()))
+		return
+	}
+
+	info, err := bridge.AddConnector(config)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, info)
+}
+
+// HandleRemoveConnector shuts down and removes a connector
+func (bridge *BridgeServer) HandleRemoveConnector(w http.ResponseWriter, r *http.Request) {
+	if err := bridge.RemoveConnector(r.PathValue("id")); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// connectorAction builds a handler that runs action on the connector named in the path
+func (bridge *BridgeServer) connectorAction(action func(id string) (ConnectorInfo, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := action(r.PathValue("id"))
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, info)
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, errConnectorNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errConnectorExists), errors.Is(err, errConnectorPaused):
+		status = http.StatusConflict
+	case errors.Is(err, errInvalidConnector):
+		status = http.StatusBadRequest
+	case errors.Is(err, errBridgeStopped), errors.Is(err, errBridgeStandby):
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSONResponse(w, status, AdminError{Error: err.Error()})
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, response interface{}) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
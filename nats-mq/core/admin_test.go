@@ -0,0 +1,216 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nst "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+)
+
+func adminRequest(t *testing.T, method string, url string, body interface{}) *http.Request {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+
+	request, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer "+testAdminToken)
+	return request
+}
+
+func TestAdminEndpointsWithoutBridge(t *testing.T) {
+	bridge := NewBridgeServer()
+	bridge.config.Monitoring.AdminToken = testAdminToken
+	bridge.httpReqStats = map[string]int64{}
+
+	mux := http.NewServeMux()
+	bridge.registerAdminHandlers(mux)
+
+	serve := func(request *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	request := adminRequest(t, http.MethodGet, AdminPath, nil)
+	request.Header.Del("Authorization")
+	require.Equal(t, http.StatusUnauthorized, serve(request).Code)
+
+	request.Header.Set("Authorization", "Bearer wrong")
+	require.Equal(t, http.StatusUnauthorized, serve(request).Code)
+
+	response := serve(adminRequest(t, http.MethodGet, AdminPath, nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "[]", response.Body.String())
+
+	require.Equal(t, http.StatusNotFound, serve(adminRequest(t, http.MethodPost, AdminPath+"/one/pause", nil)).Code)
+	require.Equal(t, http.StatusNotFound, serve(adminRequest(t, http.MethodDelete, AdminPath+"/one", nil)).Code)
+
+	invalid := conf.ConnectorConfig{Type: conf.NATS2Queue, RateLimit: conf.RateLimitConfig{MessagesPerSecond: -1}}
+	require.Equal(t, http.StatusBadRequest, serve(adminRequest(t, http.MethodPost, AdminPath, invalid)).Code)
+	require.Equal(t, http.StatusBadRequest, serve(adminRequest(t, http.MethodPost, AdminPath, "not a connector")).Code)
+	require.Equal(t, http.StatusServiceUnavailable, serve(adminRequest(t, http.MethodPost, AdminPath, conf.ConnectorConfig{Type: conf.NATS2Queue})).Code)
+
+	require.Equal(t, int64(8), bridge.httpReqStats[AdminPath])
+}
+
+func TestAdminPauseAndResume(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+
+	connect := []conf.ConnectorConfig{
+		{
+			ID:             "one",
+			Type:           "NATS2Queue",
+			Subject:        subject,
+			Queue:          queue,
+			ExcludeHeaders: true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	client := http.Client{}
+	url := tbs.Bridge.GetMonitoringRootURL() + AdminPath[1:]
+
+	response, err := client.Do(adminRequest(t, http.MethodPost, url+"/one/pause", nil))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	info := ConnectorInfo{}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&info))
+	require.Equal(t, ConnectorPaused, info.State)
+	require.Equal(t, ConnectorPaused, tbs.Bridge.SafeStats().Connections[0].State)
+
+	_, err = tbs.Bridge.RestartConnector("one")
+	require.Error(t, err)
+
+	require.NoError(t, tbs.NC.Publish(subject, []byte("paused")))
+	tbs.NC.Flush()
+	_, _, _, err = tbs.GetMessageFromQueue(queue, 500)
+	require.Error(t, err)
+
+	info, err = tbs.Bridge.ResumeConnector("one")
+	require.NoError(t, err)
+	require.Equal(t, ConnectorRunning, info.State)
+
+	require.NoError(t, tbs.NC.Publish(subject, []byte("resumed")))
+	_, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, "resumed", string(data))
+
+	info, err = tbs.Bridge.RestartConnector("one")
+	require.NoError(t, err)
+	require.Equal(t, ConnectorRunning, info.State)
+	require.Equal(t, int64(3), tbs.Bridge.SafeStats().Connections[0].Connects)
+}
+
+func TestAdminAddAndRemoveConnector(t *testing.T) {
+	queue := "DEV.QUEUE.1"
+
+	tbs, err := StartTestEnvironment([]conf.ConnectorConfig{})
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	config := conf.ConnectorConfig{
+		ID:             "added",
+		Type:           "NATS2Queue",
+		Subject:        "added",
+		Queue:          queue,
+		ExcludeHeaders: true,
+		MQ: conf.MQConfig{
+			ConnectionName: tbs.MQServer.AppHostPort,
+			ChannelName:    "DEV.APP.SVRCONN",
+			QueueManager:   "QM1",
+		},
+	}
+
+	client := http.Client{}
+	url := tbs.Bridge.GetMonitoringRootURL() + AdminPath[1:]
+
+	response, err := client.Do(adminRequest(t, http.MethodPost, url, config))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusCreated, response.StatusCode)
+
+	response, err = client.Do(adminRequest(t, http.MethodPost, url, config))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusConflict, response.StatusCode)
+
+	require.NoError(t, tbs.NC.Publish("added", []byte("hello")))
+	_, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	stats := tbs.Bridge.SafeStats()
+	require.Len(t, stats.Connections, 1)
+	require.Equal(t, "added", stats.Connections[0].ID)
+	_, ok := tbs.Bridge.lookupReplyInfo("Q:" + queue + "@QM1")
+	require.True(t, ok)
+
+	response, err = client.Do(adminRequest(t, http.MethodDelete, url+"/added", nil))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusNoContent, response.StatusCode)
+	require.Empty(t, tbs.Bridge.SafeStats().Connections)
+
+	_, ok = tbs.Bridge.lookupReplyInfo("Q:" + queue + "@QM1")
+	require.False(t, ok)
+}
+
+func TestAdminAddConnectorThatFailsToStart(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	config := conf.DefaultBridgeConfig()
+	config.NATS.Servers = []string{server.ClientURL()}
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	// streaming isn't configured, so the connector fails to start
+	_, err := bridge.AddConnector(conf.ConnectorConfig{
+		ID:             "unstarted",
+		Type:           conf.Stan2Queue,
+		Channel:        "orders",
+		Queue:          "DEV.QUEUE.1",
+		ExcludeHeaders: true,
+		MQ:             conf.MQConfig{QueueManager: "QM1"},
+	})
+	require.Error(t, err)
+	require.Empty(t, bridge.SafeStats().Connections)
+
+	_, ok := bridge.lookupReplyInfo("Q:DEV.QUEUE.1@QM1")
+	require.False(t, ok)
+}
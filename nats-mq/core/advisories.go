@@ -0,0 +1,139 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The advisory events, connector events describe a single connector, bridge events the bridge's high availability
+// role, the others a NATS or streaming connection
+const (
+	ConnectorStartedAdvisory     = "connector_started"
+	ConnectorStoppedAdvisory     = "connector_stopped"
+	ConnectorErrorAdvisory       = "connector_error"
+	ConnectorReconnectAdvisory   = "connector_reconnect_scheduled"
+	ConnectorReconnectedAdvisory = "connector_reconnected"
+	ConnectorFailedAdvisory      = "connector_failed"
+	ConnectorPausedAdvisory      = "connector_paused"
+	ConnectorResumedAdvisory     = "connector_resumed"
+	MQReconnectingAdvisory       = "mq_reconnecting"
+	MQReconnectedAdvisory        = "mq_reconnected"
+	NATSDisconnectedAdvisory     = "nats_disconnected"
+	NATSReconnectedAdvisory      = "nats_reconnected"
+	NATSClosedAdvisory           = "nats_closed"
+	StanDisconnectedAdvisory     = "stan_disconnected"
+	StanReconnectedAdvisory      = "stan_reconnected"
+	BridgeLeaderAdvisory         = "bridge_leader"
+	BridgeStandbyAdvisory        = "bridge_standby"
+)
+
+// Advisory is published to the advisory subject when a connector or connection changes state
+type Advisory struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Bridge      string    `json:"bridge,omitempty"`       // the control name, if there is one
+	ID          string    `json:"id,omitempty"`           // connector events only
+	Name        string    `json:"name,omitempty"`         // connector events, and the ha name for bridge events
+	Type        string    `json:"type,omitempty"`         // connector events only
+	Connection  string    `json:"connection,omitempty"`   // the named NATS connection, empty for the shared connection
+	Reason      int32     `json:"reason,omitempty"`       // the MQ reason code, if the error came from MQ
+	Error       string    `json:"error,omitempty"`        // the error that caused the change
+	ReconnectIn int       `json:"reconnect_in,omitempty"` // milliseconds until the bridge tries to restart the connector
+}
+
+// connectorAdvisory publishes an advisory for the connector, err can be nil
+func (bridge *BridgeServer) connectorAdvisory(event string, connector Connector, err error) {
+	if bridge.config.Advisories.Subject == "" {
+		return
+	}
+
+	bridge.publishAdvisory(newConnectorAdvisory(event, connector), err)
+}
+
+// reconnectAdvisory publishes the time until the bridge tries to restart the connector
+func (bridge *BridgeServer) reconnectAdvisory(connector Connector, err error, delay time.Duration) {
+	if bridge.config.Advisories.Subject == "" {
+		return
+	}
+
+	advisory := newConnectorAdvisory(ConnectorReconnectAdvisory, connector)
+	advisory.ReconnectIn = int(delay / time.Millisecond)
+	bridge.publishAdvisory(advisory, err)
+}
+
+func newConnectorAdvisory(event string, connector Connector) Advisory {
+	info := connectorInfo(connector)
+	return Advisory{
+		Event: event,
+		ID:    info.ID,
+		Name:  info.Name,
+		Type:  info.Type,
+	}
+}
+
+// connectionAdvisory publishes an advisory for a NATS or streaming connection, the name is empty for
+// the shared NATS connection, err can be nil
+func (bridge *BridgeServer) connectionAdvisory(event string, name string, err error) {
+	if bridge.config.Advisories.Subject == "" {
+		return
+	}
+
+	bridge.publishAdvisory(Advisory{
+		Event:      event,
+		Connection: name,
+	}, err)
+}
+
+// haAdvisory publishes a change in the bridge's high availability role, err can be nil
+func (bridge *BridgeServer) haAdvisory(event string, err error) {
+	if bridge.config.Advisories.Subject == "" {
+		return
+	}
+
+	bridge.publishAdvisory(Advisory{
+		Event: event,
+		Name:  bridge.ha.Stats().Name,
+	}, err)
+}
+
+// publishAdvisory sends the advisory on the shared NATS connection, while the connection is down
+// advisories are buffered until it reconnects, once it is closed they are dropped
+func (bridge *BridgeServer) publishAdvisory(advisory Advisory, err error) {
+	nc := bridge.NATS()
+	if nc == nil || nc.IsClosed() {
+		return
+	}
+
+	advisory.Time = time.Now()
+	advisory.Bridge = bridge.config.Control.Name
+
+	if err != nil {
+		advisory.Reason = mqReason(err)
+		advisory.Error = err.Error()
+	}
+
+	data, err := json.Marshal(advisory)
+	if err != nil {
+		bridge.logger.Noticef("unable to encode %s advisory, %s", advisory.Event, err.Error())
+		return
+	}
+
+	if err := nc.Publish(bridge.config.Advisories.Subject, data); err != nil {
+		bridge.logger.Noticef("unable to publish %s advisory, %s", advisory.Event, err.Error())
+	}
+}
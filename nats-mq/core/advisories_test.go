@@ -0,0 +1,130 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nst "github.com/nats-io/nats-server/v2/test"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func nextAdvisory(t *testing.T, sub *nats.Subscription) Advisory {
+	msg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+
+	advisory := Advisory{}
+	require.NoError(t, json.Unmarshal(msg.Data, &advisory))
+	return advisory
+}
+
+func TestAdvisories(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	namedOpts := nst.DefaultTestOptions
+	namedOpts.Port = -1
+	named := nst.RunServer(&namedOpts)
+	defer named.Shutdown()
+
+	config := conf.DefaultBridgeConfig()
+	config.NATS.Servers = []string{server.ClientURL()}
+	config.NATSConnections = []conf.NATSConfig{{Name: "other", Servers: []string{named.ClientURL()}}}
+	config.Control.Name = "test"
+	config.Advisories.Subject = "advisories"
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	nc, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	sub, err := nc.SubscribeSync("advisories")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	connector, err := CreateConnector(conf.ConnectorConfig{
+		ID:      "one",
+		Type:    conf.NATS2Queue,
+		Subject: "orders",
+		Queue:   "DEV.QUEUE.1",
+	}, bridge)
+	require.NoError(t, err)
+
+	bridge.reconnectAdvisory(connector, &ibmmq.MQReturn{MQCC: ibmmq.MQCC_FAILED, MQRC: ibmmq.MQRC_CONNECTION_BROKEN}, 1500*time.Millisecond)
+
+	advisory := nextAdvisory(t, sub)
+	require.Equal(t, ConnectorReconnectAdvisory, advisory.Event)
+	require.Equal(t, "test", advisory.Bridge)
+	require.Equal(t, "one", advisory.ID)
+	require.Equal(t, conf.NATS2Queue, advisory.Type)
+	require.Equal(t, connector.String(), advisory.Name)
+	require.Equal(t, int32(ibmmq.MQRC_CONNECTION_BROKEN), advisory.Reason)
+	require.NotEmpty(t, advisory.Error)
+	require.Equal(t, 1500, advisory.ReconnectIn)
+	require.False(t, advisory.Time.IsZero())
+
+	// the named connection doesn't reconnect, so losing its server closes it
+	named.Shutdown()
+
+	advisory = nextAdvisory(t, sub)
+	require.Equal(t, NATSDisconnectedAdvisory, advisory.Event)
+	require.Equal(t, "other", advisory.Connection)
+	require.Empty(t, advisory.ID)
+
+	advisory = nextAdvisory(t, sub)
+	require.Equal(t, NATSClosedAdvisory, advisory.Event)
+	require.Equal(t, "other", advisory.Connection)
+}
+
+func TestAdvisoriesOff(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	config := conf.DefaultBridgeConfig()
+	config.NATS.Servers = []string{server.ClientURL()}
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	nc, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	sub, err := nc.SubscribeSync(">")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	bridge.connectionAdvisory(NATSReconnectedAdvisory, "", nil)
+	require.NoError(t, bridge.NATS().Flush())
+
+	_, err = sub.NextMsg(100 * time.Millisecond)
+	require.Equal(t, nats.ErrTimeout, err)
+}
@@ -0,0 +1,552 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+)
+
+// The policies used to spread a connector's messages across its targets
+const (
+	balanceRoundRobin = "roundrobin"
+	balanceLeastDepth = "leastdepth"
+	balanceSticky     = "sticky"
+)
+
+// defaultDepthInterval is used when a leastdepth connector doesn't set a depth interval
+const defaultDepthInterval = time.Second
+
+// connectorTarget puts a connector's messages to one of its queue managers
+// Each target has its own connection, object handle and statistics, the other fields are protected
+// by the connector's lock.
+type connectorTarget struct {
+	BridgeConnector
+
+	index    int
+	queue    *ibmmq.MQObject
+	inquire  func() (int32, error) // returns the queue depth, replaced by tests
+	healthy  bool
+	attempts int       // failed attempts to reconnect since the target failed
+	retryAt  time.Time // the next attempt to reconnect, zero while the target is healthy
+	lastErr  string
+
+	depth   int32     // the queue depth at the last check, for leastdepth
+	sent    int64     // messages sent to the target since the last check
+	checked time.Time // the last depth check
+}
+
+// validateTargets checks the target and balance settings for a connector
+func validateTargets(config conf.ConnectorConfig) error {
+	if len(config.Targets) == 0 {
+		if config.Balance != "" {
+			return fmt.Errorf("balance requires targets")
+		}
+		return nil
+	}
+
+	if config.Type != conf.NATS2Queue {
+		return fmt.Errorf("%s connectors can't use targets, only NATS2Queue connectors can", config.Type)
+	}
+
+	if config.Workers > 1 {
+		return fmt.Errorf("targets can't be used with workers, each target has its own connection")
+	}
+
+	if config.ReportQueue != "" || config.Monitor.Interval > 0 {
+		return fmt.Errorf("targets can't be used with a report queue or monitor, they use the mq settings")
+	}
+
+	if config.DepthInterval < 0 {
+		return fmt.Errorf("depthinterval %d is invalid, expected a positive number", config.DepthInterval)
+	}
+
+	switch strings.ToLower(config.Balance) {
+	case "", balanceRoundRobin, balanceLeastDepth:
+	case balanceSticky:
+		if config.OrderBy == "" {
+			return fmt.Errorf("sticky balancing requires an orderby key")
+		}
+	default:
+		return fmt.Errorf("unknown balance %q, expected roundrobin, leastdepth or sticky", config.Balance)
+	}
+
+	keys := map[string]int{}
+	for i, target := range config.Targets {
+		if err := validateMQConfig(target); err != nil {
+			return fmt.Errorf("target %d, %s", i, err.Error())
+		}
+
+		key := mqConfigKey(target)
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("targets %d and %d are the same queue manager", other, i)
+		}
+		keys[key] = i
+	}
+
+	return nil
+}
+
+// balance returns the connector's balance policy
+func (mq *BridgeConnector) balance() string {
+	if mq.config.Balance == "" {
+		return balanceRoundRobin
+	}
+	return strings.ToLower(mq.config.Balance)
+}
+
+// depthInterval returns the time between depth checks for leastdepth
+func (mq *BridgeConnector) depthInterval() time.Duration {
+	if mq.config.DepthInterval == 0 {
+		return defaultDepthInterval
+	}
+	return time.Duration(mq.config.DepthInterval) * time.Millisecond
+}
+
+// ensureTargets creates the targets, targets are kept across restarts so their statistics are too
+func (mq *BridgeConnector) ensureTargets() {
+	for i := len(mq.targets); i < len(mq.config.Targets); i++ {
+		t := &connectorTarget{
+			index: i,
+		}
+		t.config = mq.config
+		t.config.MQ = mq.config.Targets[i]
+		t.bridge = mq.bridge
+		t.putDefaults = mq.putDefaults
+		t.putRules = mq.putRules
+		t.limiter = mq.limiter
+		t.dedup = mq.dedup
+		t.stats = NewConnectorStats()
+		t.stats.Name = fmt.Sprintf("%s target %d", mq.String(), i)
+		t.stats.ID = mq.stats.ID
+		t.inquire = t.inquireDepth
+
+		mq.targets = append(mq.targets, t)
+	}
+}
+
+// startTargets connects to each of the targets, targets that can't connect are retried later, an
+// error is returned if none of them connect. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) startTargets(conn Connector) error {
+	mq.stopTargets(conn)
+	mq.ensureTargets()
+
+	var lastErr error
+	healthy := 0
+
+	for _, t := range mq.targets {
+		qMgr, queue, err := t.dial(conn, mq.balance() == balanceLeastDepth)
+		if err != nil {
+			mq.targetFailed(conn, t, err)
+			lastErr = err
+			continue
+		}
+		mq.targetConnected(t, qMgr, queue)
+		healthy++
+	}
+
+	if healthy == 0 {
+		mq.stopTargets(conn)
+		return fmt.Errorf("unable to connect to any of the %d targets, %s", len(mq.targets), lastErr.Error())
+	}
+
+	mq.targetsStarted = true
+	mq.bridge.Logger().Tracef("started %d of %d targets for %s", healthy, len(mq.targets), mq.String())
+	return nil
+}
+
+// stopTargets disconnects the targets, expects the lock to be held by the caller
+func (mq *BridgeConnector) stopTargets(conn Connector) {
+	mq.targetsStarted = false
+
+	if mq.targetRetry != nil {
+		mq.targetRetry.Cancel()
+		mq.targetRetry = nil
+	}
+
+	for _, t := range mq.targets {
+		t.stop(conn)
+		t.healthy = false
+		t.attempts = 0
+		t.retryAt = time.Time{}
+	}
+}
+
+// dial connects to the target's queue manager and opens its queue, the target isn't changed so
+// the connector doesn't have to be locked while it waits for MQ
+func (t *connectorTarget) dial(conn Connector, inquire bool) (*ibmmq.MQQueueManager, *ibmmq.MQObject, error) {
+	pool := t.bridge.QueueManagerPool()
+
+	qMgr, err := pool.Acquire(t.config.MQ, true, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := ibmmq.MQOO_OUTPUT
+	if inquire {
+		options |= ibmmq.MQOO_INQUIRE
+	}
+
+	mqod := ibmmq.NewMQOD()
+	mqod.ObjectType = ibmmq.MQOT_Q
+	mqod.ObjectName = t.config.Queue
+
+	queue, err := qMgr.Open(mqod, options)
+	if err != nil {
+		pool.Release(qMgr, conn)
+		return nil, nil, err
+	}
+
+	return qMgr, &queue, nil
+}
+
+// hangUp closes a queue and connection from dial that the target didn't use
+func (t *connectorTarget) hangUp(conn Connector, qMgr *ibmmq.MQQueueManager, queue *ibmmq.MQObject) {
+	if err := queue.Close(0); err != nil {
+		t.bridge.Logger().Noticef("error closing %s, %s", t.String(), err.Error())
+	}
+
+	if err := t.bridge.QueueManagerPool().Release(qMgr, conn); err != nil {
+		t.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", t.String(), err.Error())
+	}
+}
+
+// targetConnected puts a target that dialed its queue manager into the rotation, expects the lock to be held
+func (mq *BridgeConnector) targetConnected(t *connectorTarget, qMgr *ibmmq.MQQueueManager, queue *ibmmq.MQObject) {
+	t.Lock()
+	t.qMgr = qMgr
+	t.queue = queue
+	t.stats.MQInstance = mq.bridge.QueueManagerPool().Instance(qMgr)
+	t.stats.AddConnect()
+	t.Unlock()
+
+	t.healthy = true
+	t.attempts = 0
+	t.retryAt = time.Time{}
+	t.sent = 0
+	t.checked = time.Time{}
+}
+
+func (t *connectorTarget) stop(conn Connector) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.queue != nil {
+		if err := t.queue.Close(0); err != nil {
+			t.bridge.Logger().Noticef("error closing %s, %s", t.String(), err.Error())
+		}
+		t.queue = nil
+	}
+
+	if t.qMgr != nil {
+		if err := t.disconnectFromMQ(conn); err != nil {
+			t.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", t.String(), err.Error())
+		}
+		t.stats.AddDisconnect()
+	}
+}
+
+// targetFailed takes a target out of the rotation until it is reconnected, expects the lock to be held
+func (mq *BridgeConnector) targetFailed(conn Connector, t *connectorTarget, err error) {
+	t.stop(conn)
+
+	delay := mq.bridge.reconnectDelay(t.attempts)
+	t.healthy = false
+	t.attempts++
+	t.retryAt = time.Now().Add(delay)
+	t.lastErr = err.Error()
+
+	mq.bridge.Logger().Noticef("%s failed, will try to reconnect in %s, %s", t.String(), delay, err.Error())
+
+	mq.scheduleTargetRetry(conn)
+}
+
+// scheduleTargetRetry sets the timer that reconnects failed targets for the first one whose wait ends
+// expects the lock to be held
+func (mq *BridgeConnector) scheduleTargetRetry(conn Connector) {
+	next := time.Time{}
+	for _, t := range mq.targets {
+		if !t.healthy && !t.retryAt.IsZero() && (next.IsZero() || t.retryAt.Before(next)) {
+			next = t.retryAt
+		}
+	}
+
+	if next.IsZero() {
+		return
+	}
+
+	if mq.targetRetry != nil {
+		if !next.Before(mq.targetRetryAt) {
+			return // the timer will go off first
+		}
+		mq.targetRetry.Cancel()
+	}
+
+	timer := newReconnectTimer()
+	mq.targetRetry = timer
+	mq.targetRetryAt = next
+
+	go func() {
+		if ok := <-timer.After(time.Until(next)); ok {
+			mq.retryTargets(conn, timer)
+		}
+	}()
+}
+
+// retryTargets reconnects the failed targets whose wait is over, without holding the lock while it
+// connects. A target only goes back into the rotation once it is connected.
+func (mq *BridgeConnector) retryTargets(conn Connector, timer *reconnectTimer) {
+	mq.Lock()
+
+	if mq.targetRetry != timer {
+		mq.Unlock()
+		return // replaced or stopped while this one was waiting for the lock
+	}
+	mq.targetRetry = nil
+
+	now := time.Now()
+	due := []*connectorTarget{}
+	for _, t := range mq.targets {
+		if !t.healthy && !t.retryAt.IsZero() && !now.Before(t.retryAt) {
+			due = append(due, t)
+		}
+	}
+	inquire := mq.balance() == balanceLeastDepth
+
+	mq.Unlock()
+
+	for _, t := range due {
+		qMgr, queue, err := t.dial(conn, inquire)
+
+		mq.Lock()
+		switch {
+		case !mq.targetsStarted || t.healthy:
+			if err == nil {
+				t.hangUp(conn, qMgr, queue) // stopped, or restarted, while this one connected
+			}
+		case err != nil:
+			mq.targetFailed(conn, t, err)
+		default:
+			mq.targetConnected(t, qMgr, queue)
+			mq.bridge.Logger().Noticef("%s reconnected", t.String())
+		}
+		mq.Unlock()
+	}
+
+	mq.Lock()
+	if mq.targetsStarted {
+		mq.scheduleTargetRetry(conn)
+	}
+	mq.Unlock()
+}
+
+// dispatchToTarget puts a message to one of the connector's targets, returns false if the connector doesn't have targets
+// If none of the targets are available the message fails and the bridge restarts the connector.
+func (mq *BridgeConnector) dispatchToTarget(conn Connector, m *nats.Msg) bool {
+	if len(mq.config.Targets) == 0 {
+		return false
+	}
+
+	mq.Lock()
+
+	if !mq.targetsStarted {
+		mq.Unlock()
+		return true // stopped, drop the message like a closed subscription would
+	}
+
+	t := mq.pickTarget(conn, m)
+
+	if t == nil || t.queue == nil {
+		err := fmt.Errorf("none of the %d targets are available", len(mq.targets))
+		mq.stats.AddMessageIn(int64(len(m.Data)))
+		mq.recordFailure(PutFailure, err)
+		mq.confirmPut(m, notConfirmed(err))
+		mq.Unlock()
+
+		go mq.bridge.ConnectorError(conn, err)
+		return true
+	}
+
+	t.sent++
+	dest := t.queue
+	mq.Unlock()
+
+	err := t.putNATSMessage(nil, m, dest)
+
+	if err != nil && classifyPutError(err) == PutErrorConnection {
+		mq.Lock()
+		if t.healthy && t.queue == dest {
+			mq.targetFailed(conn, t, err)
+		}
+		mq.Unlock()
+	}
+
+	return true
+}
+
+// pickTarget chooses a healthy target for the message using the balance policy, failed targets are
+// reconnected in the background. Returns nil if none are healthy. Expects the lock to be held.
+func (mq *BridgeConnector) pickTarget(conn Connector, m *nats.Msg) *connectorTarget {
+	healthy := mq.healthyTargets()
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	switch mq.balance() {
+	case balanceLeastDepth:
+		if t := mq.leastDepthTarget(conn, healthy, time.Now()); t != nil {
+			return t
+		}
+
+		// the depth checks can fail targets
+		if healthy = mq.healthyTargets(); len(healthy) == 0 {
+			return nil
+		}
+	case balanceSticky:
+		if key, ok := mq.orderKey(m.Subject, m.Data); ok {
+			return stickyTarget(healthy, key)
+		}
+	}
+
+	mq.nextTarget = (mq.nextTarget + 1) % len(healthy)
+	return healthy[mq.nextTarget]
+}
+
+// healthyTargets returns the targets in the rotation, expects the lock to be held
+func (mq *BridgeConnector) healthyTargets() []*connectorTarget {
+	healthy := []*connectorTarget{}
+	for _, t := range mq.targets {
+		if t.healthy {
+			healthy = append(healthy, t)
+		}
+	}
+	return healthy
+}
+
+// leastDepthTarget returns the target with the fewest messages, the depth is checked every depth interval and
+// the messages sent since are added to it. Returns nil if none of the depths are available. Expects the lock to be held.
+func (mq *BridgeConnector) leastDepthTarget(conn Connector, targets []*connectorTarget, now time.Time) *connectorTarget {
+	var least *connectorTarget
+	var leastDepth int64
+
+	for _, t := range targets {
+		if now.Sub(t.checked) >= mq.depthInterval() {
+			depth, err := t.inquire()
+			if err != nil {
+				if classifyPutError(err) == PutErrorConnection {
+					mq.targetFailed(conn, t, err)
+				}
+				continue
+			}
+
+			t.depth = depth
+			t.sent = 0
+			t.checked = now
+		}
+
+		if depth := int64(t.depth) + t.sent; least == nil || depth < leastDepth {
+			least = t
+			leastDepth = depth
+		}
+	}
+
+	return least
+}
+
+func (t *connectorTarget) inquireDepth() (int32, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.queue == nil {
+		return 0, fmt.Errorf("%s is not connected", t.String())
+	}
+
+	values, err := t.queue.Inq([]int32{ibmmq.MQIA_CURRENT_Q_DEPTH})
+	if err != nil {
+		return 0, err
+	}
+
+	depth, _ := values[ibmmq.MQIA_CURRENT_Q_DEPTH].(int32)
+	return depth, nil
+}
+
+// stickyTarget picks the target with the highest hash of the key and target, so a key only moves
+// to another target when its target fails
+func stickyTarget(targets []*connectorTarget, key string) *connectorTarget {
+	var best *connectorTarget
+	var bestScore uint32
+
+	for _, t := range targets {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(t.index)))
+
+		if score := h.Sum32(); best == nil || score > bestScore {
+			best = t
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+// targetStats combines the connector's statistics with its targets. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) targetStats() ConnectorStats {
+	stats := mq.stats
+	stats.histogram = NewHistogram(60)
+	stats.histogram.Merge(mq.stats.histogram)
+	stats.latency = NewHistogram(60)
+	stats.latency.Merge(mq.stats.latency)
+	stats.Targets = []TargetStats{}
+
+	for _, t := range mq.targets {
+		t.Lock()
+		ts := t.stats
+		stats.histogram.Merge(t.stats.histogram)
+		stats.latency.Merge(t.stats.latency)
+		t.Unlock()
+
+		stats.addCounts(ts)
+
+		target := TargetStats{
+			Target:       t.index,
+			QueueManager: t.config.MQ.QueueManager,
+			MQInstance:   ts.MQInstance,
+			Healthy:      t.healthy,
+			NextAttempt:  t.retryAt,
+			LastError:    t.lastErr,
+			BytesOut:     ts.BytesOut,
+			MessagesOut:  ts.MessagesOut,
+			PutFailures:  ts.PutFailures,
+		}
+
+		if mq.balance() == balanceLeastDepth {
+			target.Depth = int64(t.depth) + t.sent
+		}
+
+		stats.Targets = append(stats.Targets, target)
+	}
+
+	return stats
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTargets(t *testing.T) {
+	targets := []conf.MQConfig{
+		{ConnectionName: "one(1414)", QueueManager: "QM1"},
+		{ConnectionName: "two(1414)", QueueManager: "QM2"},
+	}
+
+	require.NoError(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue}))
+	require.NoError(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets}))
+	require.NoError(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, Balance: "LeastDepth", DepthInterval: 500}))
+	require.NoError(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, Balance: "sticky", OrderBy: "subject"}))
+
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Balance: "roundrobin"}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.Stan2Queue, Targets: targets}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, Workers: 2}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, ReportQueue: "REPORTS"}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, Balance: "sticky"}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, Balance: "random"}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: targets, DepthInterval: -1}))
+	require.Error(t, validateTargets(conf.ConnectorConfig{Type: conf.NATS2Queue, Targets: append(targets, targets[0])}))
+}
+
+// balanceTestConnector has healthy targets that aren't connected, so they can be picked but not used
+func balanceTestConnector(config conf.ConnectorConfig, count int) *NATS2QueueConnector {
+	for i := 0; i < count; i++ {
+		config.Targets = append(config.Targets, conf.MQConfig{QueueManager: fmt.Sprintf("QM%d", i)})
+	}
+	mq := NewNATS2QueueConnector(NewBridgeServer(), config).(*NATS2QueueConnector)
+	mq.ensureTargets()
+	for _, t := range mq.targets {
+		t.healthy = true
+	}
+	return mq
+}
+
+func TestRoundRobinSkipsFailedTargets(t *testing.T) {
+	mq := balanceTestConnector(conf.ConnectorConfig{Type: conf.NATS2Queue}, 3)
+	msg := &nats.Msg{Subject: "orders"}
+
+	counts := map[int]int{}
+	for i := 0; i < 30; i++ {
+		counts[mq.pickTarget(mq, msg).index]++
+	}
+	require.Equal(t, map[int]int{0: 10, 1: 10, 2: 10}, counts)
+
+	mq.targets[1].healthy = false
+	mq.targets[1].retryAt = time.Now().Add(time.Hour)
+
+	counts = map[int]int{}
+	for i := 0; i < 30; i++ {
+		counts[mq.pickTarget(mq, msg).index]++
+	}
+	require.Equal(t, map[int]int{0: 15, 2: 15}, counts)
+
+	for _, target := range mq.targets {
+		target.healthy = false
+	}
+	require.Nil(t, mq.pickTarget(mq, msg))
+}
+
+func TestStickyTargets(t *testing.T) {
+	mq := balanceTestConnector(conf.ConnectorConfig{Type: conf.NATS2Queue, Balance: "sticky", OrderBy: "subject"}, 3)
+
+	picks := map[string]int{}
+	used := map[int]bool{}
+	for i := 0; i < 50; i++ {
+		subject := fmt.Sprintf("orders.%d", i)
+		target := mq.pickTarget(mq, &nats.Msg{Subject: subject})
+		require.Equal(t, target, mq.pickTarget(mq, &nats.Msg{Subject: subject}))
+		picks[subject] = target.index
+		used[target.index] = true
+	}
+	require.Len(t, used, 3)
+
+	// only the keys on the failed target move
+	mq.targets[2].healthy = false
+	mq.targets[2].retryAt = time.Now().Add(time.Hour)
+
+	for subject, index := range picks {
+		target := mq.pickTarget(mq, &nats.Msg{Subject: subject})
+		if index != 2 {
+			require.Equal(t, index, target.index)
+		} else {
+			require.NotEqual(t, 2, target.index)
+		}
+	}
+}
+
+func TestLeastDepthTarget(t *testing.T) {
+	mq := balanceTestConnector(conf.ConnectorConfig{Type: conf.NATS2Queue, Balance: "leastdepth", DepthInterval: 60000}, 2)
+	now := time.Now()
+
+	for i, depth := range []int32{5, 2} {
+		mq.targets[i].depth = depth
+		mq.targets[i].checked = now
+	}
+
+	msg := &nats.Msg{Subject: "orders"}
+	target := mq.pickTarget(mq, msg)
+	require.Equal(t, 1, target.index)
+
+	// messages sent since the last check count towards the depth
+	mq.targets[1].sent = 4
+	target = mq.pickTarget(mq, msg)
+	require.Equal(t, 0, target.index)
+
+	mq.targets[0].sent = 1
+	stats := mq.targetStats()
+	require.Len(t, stats.Targets, 2)
+	require.Equal(t, int64(6), stats.Targets[0].Depth)
+	require.Equal(t, int64(6), stats.Targets[1].Depth)
+	require.Equal(t, "QM1", stats.Targets[1].QueueManager)
+}
+
+func TestLeastDepthAllTargetsBroken(t *testing.T) {
+	mq := balanceTestConnector(conf.ConnectorConfig{Type: conf.NATS2Queue, Balance: "leastdepth"}, 3)
+	mq.bridge.config.ReconnectInterval = 60000 // keep the failed targets out of the rotation
+	mq.targetsStarted = true
+	defer mq.stopTargets(mq)
+
+	for _, target := range mq.targets {
+		target.inquire = func() (int32, error) {
+			return 0, mqError(ibmmq.MQRC_CONNECTION_BROKEN)
+		}
+	}
+
+	msg := &nats.Msg{Subject: "orders"}
+	require.Nil(t, mq.pickTarget(mq, msg))
+
+	for _, target := range mq.targets {
+		require.False(t, target.healthy)
+		require.Equal(t, 1, target.attempts)
+	}
+	require.NotNil(t, mq.targetRetry)
+
+	// the message fails instead of being put to a target without a queue
+	require.True(t, mq.dispatchToTarget(mq, msg))
+	require.Equal(t, int64(1), mq.stats.PutFailures)
+}
+
+func TestTargetStats(t *testing.T) {
+	mq := balanceTestConnector(conf.ConnectorConfig{Type: conf.NATS2Queue}, 2)
+
+	mq.targets[0].stats.AddMessageIn(10)
+	mq.targets[0].stats.AddMessageOut(10)
+	mq.targets[1].stats.AddMessageIn(5)
+	mq.targets[1].stats.AddFailure(ErrorStats{Kind: PutFailure, Error: "queue full"})
+	mq.targets[1].healthy = false
+	mq.targets[1].lastErr = "connection broken"
+
+	stats := mq.targetStats()
+	require.Equal(t, int64(2), stats.MessagesIn)
+	require.Equal(t, int64(1), stats.MessagesOut)
+	require.Equal(t, int64(1), stats.PutFailures)
+	require.Len(t, stats.Targets, 2)
+	require.True(t, stats.Targets[0].Healthy)
+	require.Equal(t, int64(10), stats.Targets[0].BytesOut)
+	require.False(t, stats.Targets[1].Healthy)
+	require.Equal(t, "connection broken", stats.Targets[1].LastError)
+	require.Equal(t, int64(1), stats.Targets[1].PutFailures)
+}
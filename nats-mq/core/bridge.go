@@ -16,6 +16,7 @@
 package core
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -37,20 +38,33 @@ type BridgeServer struct {
 	runningLock sync.Mutex
 	running     bool
 
-	startTime time.Time
-	config    conf.BridgeConfig
-	logger    logging.Logger
+	startTime  time.Time
+	config     conf.BridgeConfig
+	configFile string // set by LoadConfigFile, used to reload
+	logger     logging.Logger
 
-	natsLock sync.Mutex
-	nats     *nats.Conn
-	stan     stan.Conn
+	natsLock        sync.Mutex
+	nats            *nats.Conn
+	stan            stan.Conn
+	natsConnections map[string]*nats.Conn
+	subscriptions   map[*nats.Subscription]*BridgeConnector
 
-	connectors  []Connector
-	replyToInfo map[string]conf.ConnectorConfig
+	connectorsLock sync.Mutex // the admin endpoints add and remove connectors while the bridge runs
+	connectors     []Connector
+
+	mqPool  *QueueManagerPool
+	limiter *rateLimiter // shared by all of the connectors, nil if there is no bridge wide limit
+
+	replyToLock sync.RWMutex // connectors are added and removed while messages are converted
+	replyToInfo map[string]replyInfo
 
 	reconnectLock  sync.Mutex
-	reconnect      map[string]Connector
+	reconnect      map[string]*reconnectState
 	reconnectTimer *reconnectTimer
+	reconnectAt    time.Time // when the timer will wake up
+
+	startup *StartupStats // the connectors that started, and failed to start, with the bridge
+	ha      *haElector    // nil unless high availability is configured
 
 	statsLock        sync.Mutex
 	httpReqStats     map[string]int64
@@ -74,8 +88,6 @@ func NewBridgeServer() *BridgeServer {
 
 // LoadConfigFile initialize the server's configuration from a file
 func (bridge *BridgeServer) LoadConfigFile(configFile string) error {
-	config := conf.DefaultBridgeConfig()
-
 	if configFile == "" {
 		configFile = os.Getenv("MQNATS_BRIDGE_CONFIG")
 		if configFile != "" {
@@ -89,17 +101,78 @@ func (bridge *BridgeServer) LoadConfigFile(configFile string) error {
 		return fmt.Errorf("no config file specified")
 	}
 
+	config, err := readConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	bridge.config = config
+	bridge.configFile = configFile
+	return nil
+}
+
+// readConfigFile loads a configuration file over the defaults and validates it
+func readConfigFile(configFile string) (conf.BridgeConfig, error) {
+	config := conf.DefaultBridgeConfig()
+
 	if err := conf.LoadConfigFromFile(configFile, &config, false); err != nil {
+		return config, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// Reload reads the configuration file again and restarts the bridge with it. If the file can't be
+// loaded the bridge keeps running with its current configuration, if the restart fails the bridge is stopped.
+func (bridge *BridgeServer) Reload() error {
+	config, err := bridge.reloadConfig()
+	if err != nil {
 		return err
 	}
+	return bridge.restart(config)
+}
+
+// reloadConfig reads the file the configuration was loaded from
+func (bridge *BridgeServer) reloadConfig() (conf.BridgeConfig, error) {
+	if bridge.configFile == "" {
+		return conf.BridgeConfig{}, fmt.Errorf("the configuration wasn't loaded from a file")
+	}
+
+	config, err := readConfigFile(bridge.configFile)
+	if err != nil {
+		return config, fmt.Errorf("unable to reload %q, %s", bridge.configFile, err.Error())
+	}
+
+	return config, nil
+}
 
+// restart stops the bridge and starts it with the new configuration, the bridge is stopped if it can't start
+func (bridge *BridgeServer) restart(config conf.BridgeConfig) error {
+	bridge.Stop()
+
+	bridge.runningLock.Lock()
 	bridge.config = config
+	bridge.runningLock.Unlock()
+
+	if err := bridge.Start(); err != nil {
+		bridge.Stop()
+		return err
+	}
+
 	return nil
 }
 
 // LoadConfig initialize the server's configuration to an existing config object, useful for tests
 // Does not initialize the config at all, use DefaultBridgeConfig() to create a default config
 func (bridge *BridgeServer) LoadConfig(config conf.BridgeConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
 	bridge.config = config
 	return nil
 }
@@ -116,9 +189,18 @@ func (bridge *BridgeServer) Start() error {
 	bridge.running = true
 	bridge.startTime = time.Now()
 	bridge.logger = logging.NewNATSLogger(bridge.config.Logging)
-	bridge.replyToInfo = map[string]conf.ConnectorConfig{}
+	bridge.replyToLock.Lock()
+	bridge.replyToInfo = map[string]replyInfo{}
+	bridge.replyToLock.Unlock()
+	bridge.connectorsLock.Lock()
 	bridge.connectors = []Connector{}
-	bridge.reconnect = map[string]Connector{}
+	bridge.connectorsLock.Unlock()
+	bridge.reconnect = map[string]*reconnectState{}
+	bridge.natsConnections = map[string]*nats.Conn{}
+	bridge.subscriptions = map[*nats.Subscription]*BridgeConnector{}
+	bridge.mqPool = NewQueueManagerPool(bridge, bridge.config.MQPool)
+	bridge.limiter = newRateLimiter(bridge.config.RateLimit)
+	bridge.ha = newHAElector(bridge.config.HA, bridge.config.Control.Name)
 
 	bridge.logger.Noticef("starting MQ-NATS bridge, version %s", version)
 	bridge.logger.Noticef("server time is %s", bridge.startTime.Format(time.UnixDate))
@@ -127,15 +209,25 @@ func (bridge *BridgeServer) Start() error {
 		return err
 	}
 
+	if err := bridge.connectToNATSConnections(); err != nil {
+		return err
+	}
+
 	if err := bridge.connectToSTAN(); err != nil {
 		return err
 	}
 
+	bridge.mqPool.startHealthChecks()
+
 	if err := bridge.initializeConnectors(); err != nil {
 		return err
 	}
 
-	if err := bridge.startConnectors(); err != nil {
+	if bridge.ha != nil {
+		if err := bridge.startHA(); err != nil {
+			return err
+		}
+	} else if err := bridge.startConnectors(); err != nil {
 		return err
 	}
 
@@ -143,6 +235,10 @@ func (bridge *BridgeServer) Start() error {
 		return err
 	}
 
+	if err := bridge.startControl(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -159,19 +255,17 @@ func (bridge *BridgeServer) Stop() {
 
 	bridge.running = false
 	bridge.stopReconnectTimer()
-	bridge.reconnect = map[string]Connector{} // clear the map
 
-	for _, c := range bridge.connectors {
-		err := c.Shutdown()
-
-		if err != nil {
-			bridge.logger.Noticef("error shutting down connector %s", err.Error())
-		}
+	if bridge.ha.isStandby() {
+		bridge.reconnect = map[string]*reconnectState{} // the connectors were shut down when the bridge stepped down
+	} else {
+		bridge.stopConnectors()
 	}
 
-	if bridge.nats != nil {
-		bridge.nats.Close()
-		bridge.logger.Noticef("disconnected from NATS")
+	bridge.stopHA() // after the connectors, so a standby doesn't start them while they are still running
+
+	if bridge.mqPool != nil {
+		bridge.mqPool.Close()
 	}
 
 	if bridge.stan != nil {
@@ -179,12 +273,44 @@ func (bridge *BridgeServer) Stop() {
 		bridge.logger.Noticef("disconnected from NATS streaming")
 	}
 
+	for name, nc := range bridge.natsConnections {
+		nc.Close()
+		bridge.logger.Noticef("disconnected from NATS connection %s", name)
+	}
+
+	if bridge.nats != nil {
+		bridge.nats.Close()
+		bridge.logger.Noticef("disconnected from NATS")
+	}
+
 	err := bridge.StopMonitoring()
 	if err != nil {
 		bridge.logger.Noticef("error shutting down monitoring server %s", err.Error())
 	}
 }
 
+// stopConnectors shuts down the connectors and forgets the ones waiting to be restarted, paused connectors
+// are already shut down, assumes the running lock is held by the caller
+func (bridge *BridgeServer) stopConnectors() {
+	bridge.reconnectLock.Lock()
+	bridge.reconnect = map[string]*reconnectState{} // clear the map
+	bridge.reconnectLock.Unlock()
+
+	for _, c := range bridge.currentConnectors() {
+		if c.Paused() {
+			continue // already shut down
+		}
+
+		err := c.Shutdown()
+
+		if err != nil {
+			bridge.logger.Noticef("error shutting down connector %s", err.Error())
+		}
+
+		bridge.connectorAdvisory(ConnectorStoppedAdvisory, c, err)
+	}
+}
+
 // assumes the lock is held by the caller
 func (bridge *BridgeServer) initializeConnectors() error {
 	connectorConfigs := bridge.config.Connect
@@ -196,22 +322,82 @@ func (bridge *BridgeServer) initializeConnectors() error {
 			return err
 		}
 
+		bridge.connectorsLock.Lock()
 		bridge.connectors = append(bridge.connectors, connector)
+		bridge.connectorsLock.Unlock()
 	}
 	return nil
 }
 
+// startConnectors starts each connector, connectors that fail are handed to the reconnect timer
+// unless the bridge is configured to fail fast, paused connectors stay down until they are resumed
 // assumes the lock is held by the caller
 func (bridge *BridgeServer) startConnectors() error {
-	for _, c := range bridge.connectors {
-		if err := c.Start(); err != nil {
+	connectors := []Connector{}
+	for _, c := range bridge.currentConnectors() {
+		if !c.Paused() {
+			connectors = append(connectors, c)
+		}
+	}
+
+	startup := &StartupStats{
+		Time:       time.Now(),
+		Connectors: len(connectors),
+		Failed:     []StartupFailure{},
+	}
+	bridge.startup = startup
+
+	for _, c := range connectors {
+		err := c.Start()
+
+		if err == nil {
+			startup.Started++
+			bridge.connectorAdvisory(ConnectorStartedAdvisory, c, nil)
+			continue
+		}
+
+		if bridge.config.FailFast {
 			bridge.logger.Noticef("error starting %s, %s", c.String(), err.Error())
 			return err
 		}
+
+		startup.Failed = append(startup.Failed, StartupFailure{
+			ID:    c.ID(),
+			Name:  c.String(),
+			Error: err.Error(),
+		})
+
+		bridge.reconnectLock.Lock()
+		_ = bridge.restartLater(c, err) // the error is logged, and reported in the startup summary
+		bridge.reconnectLock.Unlock()
+	}
+
+	if len(startup.Failed) > 0 {
+		bridge.logger.Warnf("started %d of %d connectors, the bridge will keep trying to start the other %d", startup.Started, startup.Connectors, len(startup.Failed))
+	} else {
+		bridge.logger.Noticef("started %d connectors", startup.Started)
 	}
+
 	return nil
 }
 
+// currentConnectors returns a copy of the connector list, which can change while the bridge runs
+func (bridge *BridgeServer) currentConnectors() []Connector {
+	bridge.connectorsLock.Lock()
+	defer bridge.connectorsLock.Unlock()
+	return append([]Connector{}, bridge.connectors...)
+}
+
+// hasConnector returns false if the connector was removed
+func (bridge *BridgeServer) hasConnector(connector Connector) bool {
+	for _, c := range bridge.currentConnectors() {
+		if c == connector {
+			return true
+		}
+	}
+	return false
+}
+
 func (bridge *BridgeServer) checkRunning() bool {
 	bridge.runningLock.Lock()
 	defer bridge.runningLock.Unlock()
@@ -225,6 +411,22 @@ func (bridge *BridgeServer) NATS() *nats.Conn {
 	return bridge.nats
 }
 
+// NATSConnection returns the named nats connection, or the shared connection if the name is empty
+func (bridge *BridgeServer) NATSConnection(name string) *nats.Conn {
+	if name == "" {
+		return bridge.NATS()
+	}
+
+	bridge.natsLock.Lock()
+	defer bridge.natsLock.Unlock()
+	return bridge.natsConnections[name]
+}
+
+// QueueManagerPool returns the pool connectors use to connect to MQ
+func (bridge *BridgeServer) QueueManagerPool() *QueueManagerPool {
+	return bridge.mqPool
+}
+
 // Stan hosts a shared streaming connection for the connectors
 func (bridge *BridgeServer) Stan() stan.Conn {
 	bridge.natsLock.Lock()
@@ -249,6 +451,24 @@ func (bridge *BridgeServer) CheckNATS() bool {
 	return false
 }
 
+// CheckNATSConnection returns true if the named nats connection, or the shared connection if the name is empty, is connected
+func (bridge *BridgeServer) CheckNATSConnection(name string) bool {
+	if name == "" {
+		return bridge.CheckNATS()
+	}
+
+	bridge.natsLock.Lock()
+	defer bridge.natsLock.Unlock()
+
+	nc := bridge.natsConnections[name]
+
+	if nc != nil {
+		return nc.ConnectedUrl() != ""
+	}
+
+	return false
+}
+
 // CheckStan returns true if the bridge is connected to stan
 func (bridge *BridgeServer) CheckStan() bool {
 	bridge.natsLock.Lock()
@@ -265,9 +485,39 @@ func (bridge *BridgeServer) CheckStan() bool {
 	return bridge.stan != nil
 }
 
+// replyInfo is the config of the connector that registered a description
+type replyInfo struct {
+	connectorID string
+	config      conf.ConnectorConfig
+}
+
 // RegisterReplyInfo tracks incoming descriptions so that reply to values can be mapped correctly
-func (bridge *BridgeServer) RegisterReplyInfo(desc string, config conf.ConnectorConfig) {
-	bridge.replyToInfo[desc] = config
+// The connector's id is kept so the description can be removed with the connector.
+func (bridge *BridgeServer) RegisterReplyInfo(desc string, connectorID string, config conf.ConnectorConfig) {
+	bridge.replyToLock.Lock()
+	defer bridge.replyToLock.Unlock()
+	bridge.replyToInfo[desc] = replyInfo{connectorID: connectorID, config: config}
+}
+
+// unregisterReplyInfo removes the descriptions registered by a connector
+func (bridge *BridgeServer) unregisterReplyInfo(connectorID string) {
+	bridge.replyToLock.Lock()
+	defer bridge.replyToLock.Unlock()
+
+	for desc, info := range bridge.replyToInfo {
+		if info.connectorID == connectorID {
+			delete(bridge.replyToInfo, desc)
+		}
+	}
+}
+
+// lookupReplyInfo returns the config of the connector registered for a description
+func (bridge *BridgeServer) lookupReplyInfo(desc string) (conf.ConnectorConfig, bool) {
+	bridge.replyToLock.RLock()
+	defer bridge.replyToLock.RUnlock()
+
+	info, ok := bridge.replyToInfo[desc]
+	return info.config, ok
 }
 
 // assumes the lock is held by the caller
@@ -277,15 +527,95 @@ func (bridge *BridgeServer) connectToNATS() error {
 
 	bridge.logger.Noticef("connecting to NATS core")
 
-	config := bridge.config.NATS
-	options := []nats.Option{nats.MaxReconnects(config.MaxReconnects),
-		nats.ReconnectWait(time.Duration(config.ReconnectWait) * time.Millisecond),
-		nats.Timeout(time.Duration(config.ConnectTimeout) * time.Millisecond),
+	nc, err := bridge.dialNATS(bridge.config.NATS,
 		nats.ErrorHandler(bridge.natsError),
 		nats.DiscoveredServersHandler(bridge.natsDiscoveredServers),
 		nats.DisconnectHandler(bridge.natsDisconnected),
 		nats.ReconnectHandler(bridge.natsReconnected),
 		nats.ClosedHandler(bridge.natsClosed),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	bridge.nats = nc
+	return nil
+}
+
+// connectToNATSConnections connects to each of the named nats connections, names are checked when the config is loaded
+func (bridge *BridgeServer) connectToNATSConnections() error {
+	for _, config := range bridge.config.NATSConnections {
+		if err := bridge.connectToNATSConnection(config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connectToNATSConnection connects to a named nats connection, replacing the existing connection if there is one
+func (bridge *BridgeServer) connectToNATSConnection(config conf.NATSConfig) error {
+	bridge.natsLock.Lock()
+	defer bridge.natsLock.Unlock()
+
+	bridge.logger.Noticef("connecting to NATS connection %s", config.Name)
+
+	nc, err := bridge.dialNATS(config,
+		nats.ErrorHandler(bridge.natsError),
+		nats.DisconnectHandler(bridge.natsConnectionDisconnected(config.Name)),
+		nats.ReconnectHandler(bridge.natsConnectionReconnected(config.Name)),
+		nats.ClosedHandler(bridge.natsConnectionClosed(config.Name)),
+	)
+
+	if err != nil {
+		return fmt.Errorf("error connecting to NATS connection %s, %s", config.Name, err.Error())
+	}
+
+	bridge.natsConnections[config.Name] = nc
+	return nil
+}
+
+// reconnectNATSConnections tries to reconnect named nats connections that were closed
+func (bridge *BridgeServer) reconnectNATSConnections() {
+	for _, config := range bridge.config.NATSConnections {
+		nc := bridge.NATSConnection(config.Name)
+
+		if nc != nil && !nc.IsClosed() {
+			continue
+		}
+
+		if err := bridge.connectToNATSConnection(config); err != nil {
+			bridge.logger.Noticef("%s, will retry in %d milliseconds", err.Error(), bridge.config.ReconnectInterval)
+			continue
+		}
+
+		if nc != nil {
+			bridge.connectionAdvisory(NATSReconnectedAdvisory, config.Name, nil)
+		}
+	}
+}
+
+// dialNATS connects to nats using the configuration, handlers are added to the options
+func (bridge *BridgeServer) dialNATS(config conf.NATSConfig, handlers ...nats.Option) (*nats.Conn, error) {
+	options := []nats.Option{nats.MaxReconnects(config.MaxReconnects),
+		nats.ReconnectWait(time.Duration(config.ReconnectWait) * time.Millisecond),
+		nats.Timeout(time.Duration(config.ConnectTimeout) * time.Millisecond),
+	}
+
+	options = append(options, handlers...)
+
+	if config.Name != "" {
+		options = append(options, nats.Name(config.Name))
+	}
+
+	// Secure replaces the TLS config, so it has to come before the root and client certificates
+	if config.TLS.InsecureSkipVerify || config.TLS.ServerName != "" {
+		options = append(options, nats.Secure(&tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: config.TLS.InsecureSkipVerify,
+			ServerName:         config.TLS.ServerName,
+		}))
 	}
 
 	if config.TLS.Root != "" {
@@ -296,20 +626,34 @@ func (bridge *BridgeServer) connectToNATS() error {
 		options = append(options, nats.ClientCert(config.TLS.Cert, config.TLS.Key))
 	}
 
+	// Only one of these is set, the config is validated when it is loaded
+	if config.Username != "" {
+		options = append(options, nats.UserInfo(config.Username, config.Password))
+	}
+
+	if config.Token != "" {
+		options = append(options, nats.Token(config.Token))
+	}
+
 	if config.CredsFile != "" {
 		options = append(options, nats.UserCredentials(config.CredsFile))
 	}
 
-	nc, err := nats.Connect(strings.Join(config.Servers, ","),
-		options...,
-	)
+	if config.NKeySeedFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(config.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading nkey seed from %s, %s", config.NKeySeedFile, err.Error())
+		}
+		options = append(options, opt)
+	}
 
-	if err != nil {
-		return err
+	if config.JWT != "" {
+		options = append(options, nats.UserJWTAndSeed(config.JWT, config.Seed))
 	}
 
-	bridge.nats = nc
-	return nil
+	return nats.Connect(strings.Join(config.Servers, ","),
+		options...,
+	)
 }
 
 // assumes the lock is held by the caller
@@ -329,6 +673,8 @@ func (bridge *BridgeServer) connectToSTAN() error {
 	bridge.logger.Noticef("connecting to NATS streaming")
 	config := bridge.config.STAN
 
+	// streaming runs over the shared NATS connection, so it uses the same authentication and TLS settings
+
 	sc, err := stan.Connect(config.ClusterID, config.ClientID,
 		stan.NatsConn(bridge.nats),
 		stan.PubAckWait(time.Duration(config.PubAckWait)*time.Millisecond),
@@ -349,8 +695,8 @@ func (bridge *BridgeServer) connectToSTAN() error {
 
 // ConnectorError is called by a connector if it has a failure that requires a reconnect
 func (bridge *BridgeServer) ConnectorError(connector Connector, err error) {
-	if !bridge.checkRunning() {
-		return
+	if !bridge.checkRunning() || bridge.ha.isStandby() {
+		return // a standby shut its connectors down when it stepped down
 	}
 
 	bridge.reconnectLock.Lock()
@@ -362,18 +708,19 @@ func (bridge *BridgeServer) ConnectorError(connector Connector, err error) {
 		return // we already have that connector, no need to stop or pring any messages
 	}
 
+	if connector.Paused() || !bridge.hasConnector(connector) {
+		return // paused and removed connectors are already shut down, and shouldn't be restarted
+	}
+
 	description := connector.String()
 	bridge.logger.Errorf("a connector error has occurred, bridge will try to restart %s, %s", description, err.Error())
+	bridge.connectorAdvisory(ConnectorErrorAdvisory, connector, err)
 
-	err = connector.Shutdown()
-
-	if err != nil {
-		bridge.logger.Warnf("error shutting down connector %s, bridge will try to restart, %s", description, err.Error())
+	if shutdownErr := connector.Shutdown(); shutdownErr != nil {
+		bridge.logger.Warnf("error shutting down connector %s, bridge will try to restart, %s", description, shutdownErr.Error())
 	}
 
-	bridge.reconnect[connector.ID()] = connector
-
-	bridge.ensureReconnectTimer()
+	bridge.scheduleReconnect(connector, err)
 }
 
 // checkConnections loops over the connections and has them each check check their requirements
@@ -387,10 +734,12 @@ func (bridge *BridgeServer) checkConnections() {
 	bridge.reconnectLock.Lock()
 	defer bridge.reconnectLock.Unlock()
 
-	for _, connector := range bridge.connectors {
+	standby := bridge.ha.isStandby()
+
+	for _, connector := range bridge.currentConnectors() {
 		_, check := bridge.reconnect[connector.ID()]
 
-		if check {
+		if check || connector.Paused() || standby {
 			continue // we already have that connector, no need to stop or pring any messages
 		}
 
@@ -402,34 +751,40 @@ func (bridge *BridgeServer) checkConnections() {
 
 		description := connector.String()
 		bridge.logger.Errorf("a connector error has occurred, trying to restart %s, %s", description, err.Error())
+		bridge.connectorAdvisory(ConnectorErrorAdvisory, connector, err)
 
-		err = connector.Shutdown()
-
-		if err != nil {
-			bridge.logger.Warnf("error shutting down connector %s, trying to restart, %s", description, err.Error())
+		if shutdownErr := connector.Shutdown(); shutdownErr != nil {
+			bridge.logger.Warnf("error shutting down connector %s, trying to restart, %s", description, shutdownErr.Error())
 		}
 
-		bridge.reconnect[connector.ID()] = connector
+		bridge.scheduleReconnect(connector, err)
 	}
 
-	bridge.ensureReconnectTimer()
+	bridge.ensureReconnectTimer() // brings the NATS and streaming connections back, even if the connectors are fine
 }
 
 // requires the reconnect lock be held by the caller
-// spawns a go routine that will acquire the lock for handling reconnect tasks
+// spawns a go routine that will acquire the lock for handling reconnect tasks, the timer wakes up
+// for the earliest connector attempt and is replaced if a new attempt is due sooner
 func (bridge *BridgeServer) ensureReconnectTimer() {
+	next := bridge.nextReconnect()
+
 	if bridge.reconnectTimer != nil {
-		return
+		if !next.Before(bridge.reconnectAt) {
+			return
+		}
+		bridge.reconnectTimer.Cancel()
 	}
 
 	timer := newReconnectTimer()
 	bridge.reconnectTimer = timer
+	bridge.reconnectAt = next
 
 	go func() {
 		var doReconnect bool
 		interval := bridge.config.ReconnectInterval
 
-		doReconnect = <-timer.After(time.Duration(interval) * time.Millisecond)
+		doReconnect = <-timer.After(time.Until(next))
 		if !doReconnect {
 			return
 		}
@@ -437,41 +792,67 @@ func (bridge *BridgeServer) ensureReconnectTimer() {
 		bridge.reconnectLock.Lock()
 		defer bridge.reconnectLock.Unlock()
 
+		if bridge.reconnectTimer != timer {
+			return // replaced while this one was waiting for the lock
+		}
+		bridge.reconnectTimer = nil
+
 		// Wait for nats to be reconnected
 		if !bridge.CheckNATS() {
 			bridge.logger.Noticef("nats connection is down, will try reconnecting to streaming and restarting connectors in %d milliseconds", interval)
-			bridge.reconnectTimer = nil
 			bridge.ensureReconnectTimer()
 		}
 
+		// Bring back any named connections that were closed
+		bridge.reconnectNATSConnections()
+
 		// Make sure stan is up, if it should be
 		if bridge.stan == nil {
 			bridge.logger.Noticef("trying to reconnect to nats streaming")
 			err := bridge.connectToSTAN() // this may be a no-op if bridge.stan == nil was true but is not true once we get the lock in the connect
 
 			if err != nil {
-				bridge.logger.Noticef("error restarting streaming connection, will retry in %d milliseconds", interval, err.Error())
-				bridge.reconnectTimer = nil
+				bridge.logger.Noticef("error restarting streaming connection, will retry in %d milliseconds, %s", interval, err.Error())
 				bridge.ensureReconnectTimer()
+			} else if bridge.Stan() != nil {
+				bridge.connectionAdvisory(StanReconnectedAdvisory, "", nil)
 			}
 		}
 
-		// Do all the reconnects
-		for id, connector := range bridge.reconnect {
-			bridge.logger.Noticef("trying to restart connector %s", connector.String())
+		// Do the reconnects that are due
+		now := time.Now()
+
+		for id, state := range bridge.reconnect {
+			connector := state.connector
+
+			if bridge.ha.isStandby() {
+				bridge.reconnect = map[string]*reconnectState{} // stepped down, the leader runs the connectors
+				break
+			}
+
+			if connector.Paused() || !bridge.hasConnector(connector) {
+				bridge.clearReconnect(id) // resuming starts paused connectors, removed connectors stay down
+				continue
+			}
+
+			if state.failed || now.Before(state.next) {
+				continue
+			}
+
+			bridge.logger.Noticef("trying to restart connector %s, attempt %d", connector.String(), state.attempts+1)
 			err := connector.Start()
 
 			if err != nil {
-				bridge.logger.Noticef("error restarting connector %s, will retry in %d milliseconds, %s", connector.String(), interval, err.Error())
+				bridge.logger.Noticef("error restarting connector %s, %s", connector.String(), err.Error())
+				bridge.retryReconnect(state, err)
 				continue
 			}
 
-			delete(bridge.reconnect, id)
+			bridge.clearReconnect(id)
+			bridge.connectorAdvisory(ConnectorReconnectedAdvisory, connector, nil)
 		}
 
-		bridge.reconnectTimer = nil
-
-		if len(bridge.reconnect) > 0 {
+		if bridge.checkPendingReconnects() {
 			bridge.ensureReconnectTimer()
 		}
 	}()
@@ -491,7 +872,17 @@ func (bridge *BridgeServer) stopReconnectTimer() {
 
 func (bridge *BridgeServer) checkReconnecting() bool {
 	bridge.reconnectLock.Lock()
-	reconnecting := len(bridge.reconnect) > 0
-	bridge.reconnectLock.Unlock()
-	return reconnecting
+	defer bridge.reconnectLock.Unlock()
+	return bridge.checkPendingReconnects()
+}
+
+// checkPendingReconnects returns true if any connectors are waiting to be restarted, failed connectors
+// aren't included, expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) checkPendingReconnects() bool {
+	for _, state := range bridge.reconnect {
+		if !state.failed {
+			return true
+		}
+	}
+	return false
 }
@@ -14,6 +14,7 @@ package core
 
 import (
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats-mq/nats-mq/conf"
 	"github.com/nats-io/nuid"
@@ -73,6 +74,92 @@ func TestStartBridgeNATSOnly(t *testing.T) {
 	bridge.Stop()
 }
 
+func TestStartBridgeNamedNATSConnections(t *testing.T) {
+	tbs, err := StartTestEnvironmentInfrastructure(false)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	natsConfig := conf.NATSConfig{
+		Servers:        []string{tbs.natsURL},
+		ConnectTimeout: 2000,
+		ReconnectWait:  2000,
+		MaxReconnects:  5,
+	}
+
+	config := conf.DefaultBridgeConfig()
+	config.Monitoring = conf.MonitoringConfig{
+		HTTPPort: -1,
+	}
+	config.NATS = natsConfig
+
+	tenant := natsConfig
+	tenant.Name = "tenant"
+	tenant.Account = "A"
+	config.NATSConnections = []conf.NATSConfig{tenant}
+
+	bridge := NewBridgeServer()
+	err = bridge.LoadConfig(config)
+	require.NoError(t, err)
+
+	err = bridge.Start()
+	require.NoError(t, err)
+	defer bridge.Stop()
+
+	require.True(t, bridge.CheckNATSConnection("tenant"))
+	require.False(t, bridge.CheckNATSConnection("unknown"))
+	require.NotEqual(t, bridge.NATS(), bridge.NATSConnection("tenant"))
+	require.Equal(t, bridge.NATS(), bridge.NATSConnection(""))
+
+	stats := bridge.SafeStats()
+	require.Len(t, stats.NATSConnections, 1)
+	require.Equal(t, "tenant", stats.NATSConnections[0].Name)
+	require.Equal(t, "A", stats.NATSConnections[0].Account)
+	require.True(t, stats.NATSConnections[0].Connected)
+
+	_, err = CreateConnector(conf.ConnectorConfig{
+		Type:           "NATS2Queue",
+		NATSConnection: "unknown",
+	}, bridge)
+	require.Error(t, err)
+}
+
+func TestDuplicateNATSConnectionNames(t *testing.T) {
+	natsConfig := conf.NATSConfig{
+		Servers:        []string{"nats://localhost:4222"},
+		ConnectTimeout: 2000,
+		ReconnectWait:  2000,
+		MaxReconnects:  5,
+	}
+
+	config := conf.DefaultBridgeConfig()
+	config.Monitoring = conf.MonitoringConfig{
+		HTTPPort: -1,
+	}
+	config.NATS = natsConfig
+
+	tenant := natsConfig
+	tenant.Name = "tenant"
+	config.NATSConnections = []conf.NATSConfig{tenant, tenant}
+
+	// names are checked with the rest of the configuration, before the bridge connects
+	bridge := NewBridgeServer()
+	err := bridge.LoadConfig(config)
+	require.Error(t, err)
+}
+
+func TestConflictingNATSAuthentication(t *testing.T) {
+	config := conf.DefaultBridgeConfig()
+	config.NATS = conf.NATSConfig{
+		Servers:   []string{"nats://localhost:4222"},
+		Token:     "secret",
+		CredsFile: "user.creds",
+	}
+
+	bridge := NewBridgeServer()
+	err := bridge.LoadConfig(config)
+	require.Error(t, err)
+}
+
 func TestStartBridgeNATSAndStan(t *testing.T) {
 	tbs, err := StartTestEnvironmentInfrastructure(false)
 	require.NoError(t, err)
@@ -161,3 +248,34 @@ func TestMonitoringDisabled(t *testing.T) {
 
 	bridge.Stop()
 }
+
+func TestStartupRetriesFailedConnectors(t *testing.T) {
+	flaky := &flakyConnector{stateConnector: stateConnector{id: "flaky", state: ConnectorStopped}, failures: 1}
+	bridge := startReconnectTestBridge(t, flaky)
+	defer bridge.stopReconnectTimer()
+
+	running := &stateConnector{id: "running", state: ConnectorRunning}
+	bridge.connectors = append(bridge.connectors, running)
+
+	require.NoError(t, bridge.startConnectors())
+	require.Equal(t, 2, bridge.startup.Connectors)
+	require.Equal(t, 1, bridge.startup.Started)
+	require.Len(t, bridge.startup.Failed, 1)
+	require.Equal(t, "flaky", bridge.startup.Failed[0].ID)
+	require.Equal(t, "start 1 failed", bridge.startup.Failed[0].Error)
+
+	require.Eventually(t, func() bool {
+		stats, starts := flaky.reconnectStats()
+		return stats == nil && starts == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStartupFailFast(t *testing.T) {
+	flaky := &flakyConnector{stateConnector: stateConnector{id: "flaky", state: ConnectorStopped}, failures: 1}
+	bridge := startReconnectTestBridge(t, flaky)
+	defer bridge.stopReconnectTimer()
+	bridge.config.FailFast = true
+
+	require.Error(t, bridge.startConnectors())
+	require.False(t, bridge.checkReconnecting())
+}
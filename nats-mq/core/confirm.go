@@ -0,0 +1,81 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+)
+
+// PutConfirmation is the reply connectors in confirm mode send to NATS requests once the put is done
+type PutConfirmation struct {
+	Success   bool   `json:"success"`
+	MsgID     string `json:"msg_id,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Reason    int32  `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// validateConfirm checks that confirm mode is only used by connectors that receive NATS requests
+func validateConfirm(config conf.ConnectorConfig) error {
+	if !config.Confirm {
+		return nil
+	}
+
+	if config.Type != conf.NATS2Queue && config.Type != conf.NATS2Topic {
+		return fmt.Errorf("%s connectors can't confirm puts, only NATS connectors have a reply subject", config.Type)
+	}
+
+	return nil
+}
+
+// confirmed returns the confirmation for a message that was put with mqmd
+func confirmed(mqmd *ibmmq.MQMD) PutConfirmation {
+	return PutConfirmation{
+		Success: true,
+		MsgID:   hex.EncodeToString(mqmd.MsgId),
+	}
+}
+
+// notConfirmed returns the confirmation for a message that couldn't be put, MQ errors include their reason code
+func notConfirmed(err error) PutConfirmation {
+	return PutConfirmation{
+		Error:  err.Error(),
+		Reason: mqReason(err),
+	}
+}
+
+// confirmPut replies to m with the result of its put, if the connector is in confirm mode and m is a request
+func (mq *BridgeConnector) confirmPut(m *nats.Msg, confirmation PutConfirmation) {
+	if !mq.config.Confirm || m.Reply == "" {
+		return
+	}
+
+	data, err := json.Marshal(confirmation)
+	if err != nil {
+		mq.bridge.Logger().Noticef("unable to encode confirmation for %s, %s", mq.String(), err.Error())
+		return
+	}
+
+	if err := mq.natsConn().Publish(m.Reply, data); err != nil {
+		mq.bridge.Logger().Noticef("unable to send confirmation for %s, %s", mq.String(), err.Error())
+	}
+}
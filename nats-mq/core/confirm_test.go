@@ -0,0 +1,80 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfirm(t *testing.T) {
+	require.NoError(t, validateConfirm(conf.ConnectorConfig{Type: conf.NATS2Queue, Confirm: true}))
+	require.NoError(t, validateConfirm(conf.ConnectorConfig{Type: conf.NATS2Topic, Confirm: true}))
+	require.NoError(t, validateConfirm(conf.ConnectorConfig{Type: conf.Stan2Queue}))
+	require.Error(t, validateConfirm(conf.ConnectorConfig{Type: conf.Stan2Queue, Confirm: true}))
+	require.Error(t, validateConfirm(conf.ConnectorConfig{Type: conf.Queue2NATS, Confirm: true}))
+}
+
+func TestNotConfirmedReason(t *testing.T) {
+	confirmation := notConfirmed(&ibmmq.MQReturn{MQCC: ibmmq.MQCC_FAILED, MQRC: ibmmq.MQRC_Q_FULL})
+	require.False(t, confirmation.Success)
+	require.Equal(t, ibmmq.MQRC_Q_FULL, confirmation.Reason)
+
+	confirmation = notConfirmed(fmt.Errorf("message expired"))
+	require.Equal(t, int32(0), confirmation.Reason)
+	require.Equal(t, "message expired", confirmation.Error)
+}
+
+func TestNATSToQueueConfirmsPut(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+	msg := "hello world"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "NATS2Queue",
+			Subject:        subject,
+			Queue:          queue,
+			ExcludeHeaders: true,
+			Confirm:        true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	reply, err := tbs.NC.Request(subject, []byte(msg), 5*time.Second)
+	require.NoError(t, err)
+
+	confirmation := PutConfirmation{}
+	err = json.Unmarshal(reply.Data, &confirmation)
+	require.NoError(t, err)
+	require.True(t, confirmation.Success)
+
+	mqmd, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, msg, string(data))
+	require.Equal(t, hex.EncodeToString(mqmd.MsgId), confirmation.MsgID)
+	require.Equal(t, "", mqmd.ReplyToQ)
+}
@@ -16,12 +16,146 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
 	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
 	"github.com/nats-io/nats-mq/nats-mq/conf"
 )
 
+// The MQ client reconnect settings
+const (
+	mqReconnectDisabled = "disabled"
+	mqReconnectQMgr     = "qmgr"
+	mqReconnectAny      = "any"
+)
+
+// validateMQConfig checks the connection settings in an mq section
+func validateMQConfig(config conf.MQConfig) error {
+	if _, err := reconnectOptions(config); err != nil {
+		return err
+	}
+
+	if config.CCDTURL != "" && len(mqInstances(config)) > 0 {
+		return fmt.Errorf("ccdturl can't be used with connection names, the channel definition table lists the connections")
+	}
+
+	return nil
+}
+
+// reconnectOptions returns the connection options for the MQ client reconnect setting
+func reconnectOptions(config conf.MQConfig) (int32, error) {
+	switch strings.ToLower(config.Reconnect) {
+	case "":
+		return ibmmq.MQCNO_RECONNECT_AS_DEF, nil
+	case mqReconnectDisabled:
+		return ibmmq.MQCNO_RECONNECT_DISABLED, nil
+	case mqReconnectQMgr:
+		return ibmmq.MQCNO_RECONNECT_Q_MGR, nil
+	case mqReconnectAny:
+		return ibmmq.MQCNO_RECONNECT, nil
+	default:
+		return 0, fmt.Errorf("unknown reconnect %q, expected disabled, qmgr or any", config.Reconnect)
+	}
+}
+
+// mqInstances returns the connection names for a configuration, ConnectionName can be a comma separated list
+func mqInstances(config conf.MQConfig) []string {
+	instances := []string{}
+
+	for _, name := range append(strings.Split(config.ConnectionName, ","), config.ConnectionNames...) {
+		if name = strings.TrimSpace(name); name != "" {
+			instances = append(instances, name)
+		}
+	}
+
+	return instances
+}
+
+// connectionAttempts returns the connection names to try in turn, each name is tried on its own so the
+// instance that accepted is known. MQ only moves a reconnecting client between the names it was given, so
+// with client reconnect turned on MQ gets the whole list once, and picks the instance itself.
+func connectionAttempts(instances []string, reconnect int32) []string {
+	if len(instances) == 0 {
+		return []string{""} // let MQ report the missing connection name
+	}
+
+	if len(instances) > 1 && (reconnect == ibmmq.MQCNO_RECONNECT || reconnect == ibmmq.MQCNO_RECONNECT_Q_MGR) {
+		return []string{strings.Join(instances, ",")}
+	}
+
+	return instances
+}
+
+// mqConfigKey identifies a configuration, MQConfig can't be a map key because it contains a list
+// The password is hashed, so connections with different passwords aren't shared without keeping the password
+// in the key, the fields are separated by a character that can't be in them.
+func mqConfigKey(config conf.MQConfig) string {
+	return strings.Join([]string{
+		strings.Join(mqInstances(config), ","),
+		config.ChannelName,
+		config.QueueManager,
+		config.CCDTURL,
+		strings.ToLower(config.Reconnect),
+		config.UserName,
+		passwordHash(config.Password),
+		config.KeyRepository,
+		config.CertificateLabel,
+		config.SSLPeerName,
+		config.SSLCipherSpec,
+	}, "\x00")
+}
+
+// passwordHash returns a hex encoded hash of the password, empty if there isn't one
+func passwordHash(password string) string {
+	if password == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
 // ConnectToQueueManager utility to connect to a queue manager from a configuration
 func ConnectToQueueManager(mqconfig conf.MQConfig) (*ibmmq.MQQueueManager, error) {
+	qMgr, _, err := connectToQueueManager(mqconfig, 0)
+	return qMgr, err
+}
+
+// connectToQueueManager connects with extra connection options, i.e. MQCNO_HANDLE_SHARE_BLOCK
+// The instances are tried in order, the connection name of the instance that accepted the
+// connection is returned, or the channel definition table if one is used. The instance is
+// empty if MQ was given several names to pick from.
+func connectToQueueManager(mqconfig conf.MQConfig, options int32) (*ibmmq.MQQueueManager, string, error) {
+	reconnect, err := reconnectOptions(mqconfig)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if mqconfig.CCDTURL != "" {
+		qMgr, err := connectToInstance(mqconfig, "", options|reconnect)
+		return qMgr, mqconfig.CCDTURL, err
+	}
+
+	for _, name := range connectionAttempts(mqInstances(mqconfig), reconnect) {
+		var qMgr *ibmmq.MQQueueManager
+		qMgr, err = connectToInstance(mqconfig, name, options|reconnect)
+		if err != nil {
+			continue
+		}
+
+		if strings.Contains(name, ",") {
+			return qMgr, "", nil
+		}
+		return qMgr, name, nil
+	}
+
+	return nil, "", err
+}
+
+// connectToInstance connects using connectionName, or the channel definition table if the name is empty
+func connectToInstance(mqconfig conf.MQConfig, connectionName string, options int32) (*ibmmq.MQQueueManager, error) {
 	qMgrName := mqconfig.QueueManager
 
 	connectionOptions := ibmmq.NewMQCNO()
@@ -54,10 +188,15 @@ func ConnectToQueueManager(mqconfig conf.MQConfig) (*ibmmq.MQQueueManager, error
 	}
 
 	channelDefinition.ChannelName = mqconfig.ChannelName
-	channelDefinition.ConnectionName = mqconfig.ConnectionName
+	channelDefinition.ConnectionName = connectionName
 
-	connectionOptions.Options = ibmmq.MQCNO_CLIENT_BINDING
-	connectionOptions.ClientConn = channelDefinition
+	connectionOptions.Options = ibmmq.MQCNO_CLIENT_BINDING | options
+
+	if mqconfig.CCDTURL != "" {
+		connectionOptions.CCDTUrl = mqconfig.CCDTURL
+	} else {
+		connectionOptions.ClientConn = channelDefinition
+	}
 
 	qMgr, err := ibmmq.Connx(qMgrName, connectionOptions)
 
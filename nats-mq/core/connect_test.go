@@ -19,6 +19,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,3 +57,73 @@ func TestMQTestServerWithTLS(t *testing.T) {
 
 	time.Sleep(1 * time.Second)
 }
+
+func TestMQInstances(t *testing.T) {
+	require.Empty(t, mqInstances(conf.MQConfig{}))
+	require.Equal(t, []string{"one(1414)"}, mqInstances(conf.MQConfig{ConnectionName: "one(1414)"}))
+	require.Equal(t, []string{"one(1414)", "two(1414)", "three(1414)"}, mqInstances(conf.MQConfig{
+		ConnectionName:  "one(1414), two(1414)",
+		ConnectionNames: []string{"three(1414)", ""},
+	}))
+}
+
+func TestConnectionAttempts(t *testing.T) {
+	instances := []string{"one(1414)", "two(1414)", "three(1414)"}
+	require.Equal(t, []string{""}, connectionAttempts(nil, ibmmq.MQCNO_RECONNECT_AS_DEF))
+	require.Equal(t, instances, connectionAttempts(instances, ibmmq.MQCNO_RECONNECT_AS_DEF))
+	require.Equal(t, instances, connectionAttempts(instances, ibmmq.MQCNO_RECONNECT_DISABLED))
+	require.Equal(t, []string{"one(1414),two(1414),three(1414)"}, connectionAttempts(instances, ibmmq.MQCNO_RECONNECT_Q_MGR))
+	require.Equal(t, []string{"one(1414),two(1414),three(1414)"}, connectionAttempts(instances, ibmmq.MQCNO_RECONNECT))
+	require.Equal(t, []string{"one(1414)"}, connectionAttempts(instances[:1], ibmmq.MQCNO_RECONNECT))
+}
+
+func TestReconnectOptions(t *testing.T) {
+	options, err := reconnectOptions(conf.MQConfig{})
+	require.NoError(t, err)
+	require.Equal(t, ibmmq.MQCNO_RECONNECT_AS_DEF, options)
+
+	options, err = reconnectOptions(conf.MQConfig{Reconnect: "QMgr"})
+	require.NoError(t, err)
+	require.Equal(t, ibmmq.MQCNO_RECONNECT_Q_MGR, options)
+
+	options, err = reconnectOptions(conf.MQConfig{Reconnect: "any"})
+	require.NoError(t, err)
+	require.Equal(t, ibmmq.MQCNO_RECONNECT, options)
+
+	options, err = reconnectOptions(conf.MQConfig{Reconnect: "disabled"})
+	require.NoError(t, err)
+	require.Equal(t, ibmmq.MQCNO_RECONNECT_DISABLED, options)
+
+	_, err = reconnectOptions(conf.MQConfig{Reconnect: "always"})
+	require.Error(t, err)
+}
+
+func TestValidateMQConfig(t *testing.T) {
+	require.NoError(t, validateMQConfig(conf.MQConfig{ConnectionNames: []string{"one(1414)", "two(1414)"}, Reconnect: "qmgr"}))
+	require.NoError(t, validateMQConfig(conf.MQConfig{CCDTURL: "/etc/mq/ccdt.json"}))
+	require.Error(t, validateMQConfig(conf.MQConfig{CCDTURL: "/etc/mq/ccdt.json", ConnectionName: "one(1414)"}))
+	require.Error(t, validateMQConfig(conf.MQConfig{Reconnect: "sometimes"}))
+}
+
+func TestMQConfigKey(t *testing.T) {
+	one := conf.MQConfig{QueueManager: "QM1", ConnectionNames: []string{"one(1414)", "two(1414)"}}
+	same := conf.MQConfig{QueueManager: "QM1", ConnectionNames: []string{"one(1414)", "two(1414)"}}
+	other := conf.MQConfig{QueueManager: "QM1", ConnectionNames: []string{"one(1414)"}}
+
+	require.Equal(t, mqConfigKey(one), mqConfigKey(same))
+	require.NotEqual(t, mqConfigKey(one), mqConfigKey(other))
+
+	// the same instances, listed differently
+	listed := conf.MQConfig{QueueManager: "QM1", ConnectionName: "one(1414), two(1414)"}
+	require.Equal(t, mqConfigKey(one), mqConfigKey(listed))
+
+	spaced := conf.MQConfig{QueueManager: "QM1", ConnectionNames: []string{"one(1414) two(1414)"}}
+	require.NotEqual(t, mqConfigKey(one), mqConfigKey(spaced))
+
+	password := conf.MQConfig{QueueManager: "QM1", UserName: "app", Password: "secret"}
+	require.NotContains(t, mqConfigKey(password), "secret")
+
+	// connectors with different passwords don't share a connection
+	changed := conf.MQConfig{QueueManager: "QM1", UserName: "app", Password: "changed"}
+	require.NotEqual(t, mqConfigKey(password), mqConfigKey(changed))
+}
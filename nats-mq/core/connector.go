@@ -32,6 +32,14 @@ type Connector interface {
 	Start() error
 	Shutdown() error
 
+	// Pause shuts the connector down until Resume is called, the bridge doesn't restart paused connectors
+	Pause() error
+	Resume() error
+	Paused() bool
+
+	// SetReconnect records the bridge's attempts to restart the connector, nil once it is running again
+	SetReconnect(stats *ReconnectStats)
+
 	CheckConnections() error
 
 	String() string
@@ -42,34 +50,68 @@ type Connector interface {
 
 // CreateConnector builds a connector from the supplied configuration
 func CreateConnector(config conf.ConnectorConfig, bridge *BridgeServer) (Connector, error) {
+	if _, _, err := newPutSettings(config); err != nil {
+		return nil, fmt.Errorf("invalid put settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if err := validateWorkers(config); err != nil {
+		return nil, fmt.Errorf("invalid worker settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if err := validateConfirm(config); err != nil {
+		return nil, fmt.Errorf("invalid confirm setting, %s", err.Error())
+	}
+
+	if err := validateDedup(config); err != nil {
+		return nil, fmt.Errorf("invalid dedup settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if err := validateMonitor(config); err != nil {
+		return nil, fmt.Errorf("invalid monitor settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if err := validateMQConfig(config.MQ); err != nil {
+		return nil, fmt.Errorf("invalid mq settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if err := validatePutRetries(config); err != nil {
+		return nil, fmt.Errorf("invalid put retry settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if err := validateTargets(config); err != nil {
+		return nil, fmt.Errorf("invalid target settings for %s connector, %s", config.Type, err.Error())
+	}
+
+	if config.NATSConnection != "" && bridge.NATSConnection(config.NATSConnection) == nil {
+		return nil, fmt.Errorf("unknown NATS connection %q for %s connector", config.NATSConnection, config.Type)
+	}
+
+	var connector Connector
+	var desc string
+
 	switch config.Type {
 	case conf.Queue2NATS:
-		bridge.RegisterReplyInfo("S:"+config.Subject, config)
-		return NewQueue2NATSConnector(bridge, config), nil
+		connector, desc = NewQueue2NATSConnector(bridge, config), "S:"+config.Subject
 	case conf.Queue2Stan:
-		bridge.RegisterReplyInfo("C:"+config.Channel, config)
-		return NewQueue2STANConnector(bridge, config), nil
+		connector, desc = NewQueue2STANConnector(bridge, config), "C:"+config.Channel
 	case conf.NATS2Queue:
-		bridge.RegisterReplyInfo("Q:"+config.Queue+"@"+config.MQ.QueueManager, config)
-		return NewNATS2QueueConnector(bridge, config), nil
+		connector, desc = NewNATS2QueueConnector(bridge, config), "Q:"+config.Queue+"@"+config.MQ.QueueManager
 	case conf.Stan2Queue:
-		bridge.RegisterReplyInfo("Q:"+config.Queue+"@"+config.MQ.QueueManager, config)
-		return NewStan2QueueConnector(bridge, config), nil
+		connector, desc = NewStan2QueueConnector(bridge, config), "Q:"+config.Queue+"@"+config.MQ.QueueManager
 	case conf.Topic2NATS:
-		bridge.RegisterReplyInfo("S:"+config.Subject, config)
-		return NewTopic2NATSConnector(bridge, config), nil
+		connector, desc = NewTopic2NATSConnector(bridge, config), "S:"+config.Subject
 	case conf.Topic2Stan:
-		bridge.RegisterReplyInfo("C:"+config.Channel, config)
-		return NewTopic2StanConnector(bridge, config), nil
+		connector, desc = NewTopic2StanConnector(bridge, config), "C:"+config.Channel
 	case conf.NATS2Topic:
-		bridge.RegisterReplyInfo("T:"+config.Topic+"@"+config.MQ.QueueManager, config)
-		return NewNATS2TopicConnector(bridge, config), nil
+		connector, desc = NewNATS2TopicConnector(bridge, config), "T:"+config.Topic+"@"+config.MQ.QueueManager
 	case conf.Stan2Topic:
-		bridge.RegisterReplyInfo("T:"+config.Topic+"@"+config.MQ.QueueManager, config)
-		return NewStan2TopicConnector(bridge, config), nil
+		connector, desc = NewStan2TopicConnector(bridge, config), "T:"+config.Topic+"@"+config.MQ.QueueManager
 	default:
 		return nil, fmt.Errorf("unknown connector type %q in configuration", config.Type)
 	}
+
+	bridge.RegisterReplyInfo(desc, connector.ID(), config)
+	return connector, nil
 }
 
 // BridgeConnector is the base type used for connectors so that they can share code
@@ -80,7 +122,29 @@ type BridgeConnector struct {
 	bridge *BridgeServer
 	stats  ConnectorStats
 
+	putDefaults putSettings
+	putRules    []putRule
+
+	workers    []*connectorWorker
+	nextWorker int
+
+	targets        []*connectorTarget
+	nextTarget     int
+	targetsStarted bool
+	targetRetry    *reconnectTimer // reconnects the failed targets at targetRetryAt, nil if none are waiting
+	targetRetryAt  time.Time
+
+	limiter   *rateLimiter  // shared with the workers
+	dedup     *deduplicator // shared with the workers
+	monitor   *queueMonitor
+	paused    bool
+	reconnect *ReconnectStats // set by the bridge while it is trying to restart the connector
+
 	qMgr *ibmmq.MQQueueManager
+
+	reportQMgr  *ibmmq.MQQueueManager
+	reportQueue *ibmmq.MQObject
+	reportCB    ShutdownCallback
 }
 
 // Start is a no-op, designed for overriding
@@ -114,7 +178,94 @@ func (mq *BridgeConnector) ID() string {
 func (mq *BridgeConnector) Stats() ConnectorStats {
 	mq.Lock()
 	defer mq.Unlock()
-	return mq.stats
+
+	stats := mq.stats
+
+	if len(mq.workers) > 0 {
+		stats = mq.workerStats()
+	}
+
+	if len(mq.targets) > 0 {
+		stats = mq.targetStats()
+	}
+
+	stats.RateLimit = mq.limiter.Stats()
+	stats.Queue = mq.queueStats()
+
+	if mq.reconnect != nil {
+		reconnect := *mq.reconnect
+		stats.Reconnect = &reconnect
+	}
+
+	switch {
+	case mq.paused:
+		stats.State = ConnectorPaused
+	case stats.Connected:
+		stats.State = ConnectorRunning
+	case stats.Reconnect != nil && stats.Reconnect.Failed:
+		stats.State = ConnectorFailed
+	default:
+		stats.State = ConnectorStopped
+	}
+
+	return stats
+}
+
+// SetReconnect records the bridge's attempts to restart the connector
+func (mq *BridgeConnector) SetReconnect(stats *ReconnectStats) {
+	mq.Lock()
+	defer mq.Unlock()
+	mq.reconnect = stats
+}
+
+// Paused returns true if the connector was paused by the admin endpoints
+func (mq *BridgeConnector) Paused() bool {
+	mq.Lock()
+	defer mq.Unlock()
+	return mq.paused
+}
+
+// pause shuts conn down, if it is running, and keeps it down until resume is called
+func (mq *BridgeConnector) pause(conn Connector) error {
+	mq.Lock()
+	if mq.paused {
+		mq.Unlock()
+		return nil
+	}
+	mq.paused = true
+	connected := mq.stats.Connected
+	mq.Unlock()
+
+	mq.bridge.Logger().Noticef("pausing %s", mq.String())
+
+	if !connected {
+		return nil // already shut down, waiting for the bridge to restart it
+	}
+	return conn.Shutdown()
+}
+
+// resume starts conn again after pause
+func (mq *BridgeConnector) resume(conn Connector) error {
+	mq.Lock()
+	if !mq.paused {
+		mq.Unlock()
+		return nil
+	}
+	mq.paused = false
+	mq.Unlock()
+
+	mq.bridge.Logger().Noticef("resuming %s", mq.String())
+	return conn.Start()
+}
+
+// natsConn returns the nats connection this connector uses, named connections are set in the config
+func (mq *BridgeConnector) natsConn() *nats.Conn {
+	return mq.bridge.NATSConnection(mq.config.NATSConnection)
+}
+
+// checkNATS returns true if the nats connection this connector uses is up
+func (mq *BridgeConnector) checkNATS() bool {
+	return mq.bridge.CheckNATSConnection(mq.config.NATSConnection)
 }
 
 // Init sets up common fields for all connectors
@@ -123,28 +274,55 @@ func (mq *BridgeConnector) init(bridge *BridgeServer, config conf.ConnectorConfi
 	mq.bridge = bridge
 	mq.stats = NewConnectorStats()
 	mq.stats.Name = name
+	mq.stats.Type = config.Type
 	mq.stats.ID = mq.config.ID
 
 	if mq.config.ID == "" {
 		mq.stats.ID = nuid.Next()
 	}
+
+	// errors are reported by CreateConnector
+	mq.putDefaults, mq.putRules, _ = newPutSettings(config)
+	mq.limiter = newRateLimiter(config.RateLimit)
+	mq.dedup = newDeduplicator(config.Dedup)
 }
 
 // init the MQ connection - expects the lock to be held by the caller
-func (mq *BridgeConnector) connectToMQ() error {
+// the connection is exclusive to this connector, so it can be used for gets and syncpoint
+func (mq *BridgeConnector) connectToMQ(conn Connector) error {
+	return mq.acquireMQ(conn, false)
+}
+
+// connectToSharedMQ is like connectToMQ, but the connection may be shared with other connectors
+// only use the connection for puts outside of a unit of work
+func (mq *BridgeConnector) connectToSharedMQ(conn Connector) error {
+	return mq.acquireMQ(conn, true)
+}
+
+func (mq *BridgeConnector) acquireMQ(conn Connector, shared bool) error {
 	mqconfig := mq.config.MQ
 
-	qMgr, err := ConnectToQueueManager(mqconfig)
+	qMgr, err := mq.bridge.QueueManagerPool().Acquire(mqconfig, shared, conn)
 	if err != nil {
 		return err
 	}
 
-	mq.bridge.Logger().Tracef("connected to queue manager %s at %s as %s for %s", mqconfig.QueueManager, mqconfig.ConnectionName, mqconfig.ChannelName, mq.String())
+	instance := mq.bridge.QueueManagerPool().Instance(qMgr)
+	mq.bridge.Logger().Tracef("connected to queue manager %s at %s as %s for %s", mqconfig.QueueManager, instance, mqconfig.ChannelName, mq.String())
 
 	mq.qMgr = qMgr
+	mq.stats.MQInstance = instance
 	return nil
 }
 
+// disconnectFromMQ returns the connection to the pool - expects the lock to be held by the caller
+func (mq *BridgeConnector) disconnectFromMQ(conn Connector) error {
+	qMgr := mq.qMgr
+	mq.qMgr = nil
+	mq.stats.MQInstance = ""
+	return mq.bridge.QueueManagerPool().Release(qMgr, conn)
+}
+
 func (mq *BridgeConnector) connectToQueue(queueName string, openOptions int32) (*ibmmq.MQObject, error) {
 	mqod := ibmmq.NewMQOD()
 	mqod.ObjectType = ibmmq.MQOT_Q
@@ -188,24 +366,30 @@ func (mq *BridgeConnector) connectToTopic(topicName string) (*ibmmq.MQObject, er
 }
 
 // NATSCallback used by mq-nats connectors in an MQ library callback
+// expires is the time the message expires, or the zero time if it doesn't, putTime is when it was put to MQ
 // The lock will be held by the caller!
-type NATSCallback func(natsMsg []byte, replyTo string) error
+type NATSCallback func(natsMsg []byte, replyTo string, expires time.Time, msgID []byte, putTime time.Time) error
 
 // ShutdownCallback is returned when setting up a callback or polling so the connector can shut it down
 type ShutdownCallback func() error
 
 func (mq *BridgeConnector) setUpListener(target *ibmmq.MQObject, cb NATSCallback, conn Connector) (ShutdownCallback, error) {
+	return mq.setUpListenerOn(mq.qMgr, target, cb, conn)
+}
+
+// setUpListenerOn is like setUpListener, but uses the specified queue manager connection
+func (mq *BridgeConnector) setUpListenerOn(qMgr *ibmmq.MQQueueManager, target *ibmmq.MQObject, cb NATSCallback, conn Connector) (ShutdownCallback, error) {
 	if mq.config.UsePolling {
-		return mq.setUpPolling(target, cb, conn)
+		return mq.setUpPolling(qMgr, target, cb, conn)
 	}
-	return mq.setUpCallback(target, cb, conn)
+	return mq.setUpCallback(qMgr, target, cb, conn)
 }
 
-func (mq *BridgeConnector) setUpCallback(target *ibmmq.MQObject, cb NATSCallback, conn Connector) (ShutdownCallback, error) {
+func (mq *BridgeConnector) setUpCallback(qMgr *ibmmq.MQQueueManager, target *ibmmq.MQObject, cb NATSCallback, conn Connector) (ShutdownCallback, error) {
 	mqmd := ibmmq.NewMQMD()
 	gmo := ibmmq.NewMQGMO()
 	cmho := ibmmq.NewMQCMHO()
-	propsMsgHandle, err := mq.qMgr.CrtMH(cmho)
+	propsMsgHandle, err := qMgr.CrtMH(cmho)
 
 	if err != nil {
 		return nil, err
@@ -219,8 +403,14 @@ func (mq *BridgeConnector) setUpCallback(target *ibmmq.MQObject, cb NATSCallback
 
 	mq.bridge.Logger().Tracef("setting up callback for %s", mq.String())
 
+	callback := mq.createMQCallback(cb, conn)
+	fc := mq.newFlowControl(qMgr)
+
 	cbd := ibmmq.NewMQCBD()
-	cbd.CallbackFunction = mq.createMQCallback(cb, conn)
+	cbd.CallbackFunction = func(qMgr *ibmmq.MQQueueManager, hObj *ibmmq.MQObject, md *ibmmq.MQMD, gmo *ibmmq.MQGMO, buffer []byte, cbc *ibmmq.MQCBC, mqErr *ibmmq.MQReturn) {
+		callback(qMgr, hObj, md, gmo, buffer, cbc, mqErr)
+		fc.check()
+	}
 
 	err = target.CB(ibmmq.MQOP_REGISTER, cbd, mqmd, gmo)
 
@@ -230,13 +420,14 @@ func (mq *BridgeConnector) setUpCallback(target *ibmmq.MQObject, cb NATSCallback
 
 	ctlo := ibmmq.NewMQCTLO()
 	ctlo.Options = ibmmq.MQCTLO_FAIL_IF_QUIESCING
-	err = mq.qMgr.Ctl(ibmmq.MQOP_START, ctlo)
+	err = qMgr.Ctl(ibmmq.MQOP_START, ctlo)
 	if err != nil {
 		return nil, err
 	}
 
 	return func() error {
-		if err := mq.qMgr.Ctl(ibmmq.MQOP_STOP, ctlo); err != nil {
+		fc.stop()
+		if err := qMgr.Ctl(ibmmq.MQOP_STOP, ctlo); err != nil {
 			mq.bridge.Logger().Noticef("error stopping callbacks, %s", err.Error())
 		}
 		gmo.MsgHandle.DltMH(ibmmq.NewMQDMHO()) // ignore the error
@@ -244,7 +435,7 @@ func (mq *BridgeConnector) setUpCallback(target *ibmmq.MQObject, cb NATSCallback
 	}, nil
 }
 
-func (mq *BridgeConnector) setUpPolling(target *ibmmq.MQObject, cb NATSCallback, conn Connector) (ShutdownCallback, error) {
+func (mq *BridgeConnector) setUpPolling(qMgr *ibmmq.MQQueueManager, target *ibmmq.MQObject, cb NATSCallback, conn Connector) (ShutdownCallback, error) {
 	bufferSize := mq.config.IncomingBufferSize
 	if bufferSize == 0 {
 		bufferSize = 1024 * 8
@@ -260,7 +451,7 @@ func (mq *BridgeConnector) setUpPolling(target *ibmmq.MQObject, cb NATSCallback,
 	callback := mq.createMQCallback(cb, conn)
 
 	cmho := ibmmq.NewMQCMHO()
-	propsMsgHandle, err := mq.qMgr.CrtMH(cmho)
+	propsMsgHandle, err := qMgr.CrtMH(cmho)
 
 	if err != nil {
 		return nil, err
@@ -270,6 +461,8 @@ func (mq *BridgeConnector) setUpPolling(target *ibmmq.MQObject, cb NATSCallback,
 
 	go func() {
 		for running {
+			mq.waitForNATS(done)
+
 			mqmd := ibmmq.NewMQMD()
 			gmo := ibmmq.NewMQGMO()
 			gmo.Options = ibmmq.MQGMO_SYNCPOINT
@@ -284,10 +477,10 @@ func (mq *BridgeConnector) setUpPolling(target *ibmmq.MQObject, cb NATSCallback,
 			if err != nil {
 				mqret := err.(*ibmmq.MQReturn)
 				if mqret.MQRC != ibmmq.MQRC_NO_MSG_AVAILABLE {
-					callback(mq.qMgr, target, mqmd, gmo, buffer[0:len], nil, mqret)
+					callback(qMgr, target, mqmd, gmo, buffer[0:len], nil, mqret)
 				}
 			} else {
-				callback(mq.qMgr, target, mqmd, gmo, buffer[0:len], nil, nil)
+				callback(qMgr, target, mqmd, gmo, buffer[0:len], nil, nil)
 			}
 
 			select {
@@ -308,16 +501,17 @@ func (mq *BridgeConnector) setUpPolling(target *ibmmq.MQObject, cb NATSCallback,
 
 func (mq *BridgeConnector) createMQCallback(cb NATSCallback, conn Connector) func(qMgr *ibmmq.MQQueueManager, hObj *ibmmq.MQObject, md *ibmmq.MQMD, gmo *ibmmq.MQGMO, buffer []byte, cbc *ibmmq.MQCBC, mqErr *ibmmq.MQReturn) {
 	return func(qMgr *ibmmq.MQQueueManager, hObj *ibmmq.MQObject, md *ibmmq.MQMD, gmo *ibmmq.MQGMO, buffer []byte, cbc *ibmmq.MQCBC, mqErr *ibmmq.MQReturn) {
-		mq.Lock()
-		defer mq.Unlock()
-		start := time.Now()
-
 		if mqErr != nil && mqErr.MQCC != ibmmq.MQCC_OK {
 			if mqErr.MQRC == ibmmq.MQRC_NO_MSG_AVAILABLE {
 				mq.bridge.Logger().Tracef("message timeout on %s", mq.String())
 				return
 			}
 
+			if mqErr.MQRC == ibmmq.MQRC_RECONNECTING || mqErr.MQRC == ibmmq.MQRC_RECONNECTED {
+				mq.clientReconnect(conn, mqErr.MQRC == ibmmq.MQRC_RECONNECTED)
+				return
+			}
+
 			err := fmt.Errorf("mq error in callback %s", mqErr.Error())
 			go mq.bridge.ConnectorError(conn, err)
 			return
@@ -330,9 +524,16 @@ func (mq *BridgeConnector) createMQCallback(cb NATSCallback, conn Connector) fun
 
 		bufferLen := len(buffer)
 
+		// wait before taking the lock, MQ won't deliver the next message until this one is done
+		mq.throttle(bufferLen)
+
+		mq.Lock()
+		defer mq.Unlock()
+		start := time.Now()
+
 		mq.bridge.Logger().Tracef("%s got raw mq message with body of length %d", mq.String(), bufferLen)
 
-		qmgrFlag := mq.qMgr
+		qmgrFlag := qMgr
 
 		if mq.config.ExcludeHeaders {
 			qmgrFlag = nil
@@ -343,91 +544,222 @@ func (mq *BridgeConnector) createMQCallback(cb NATSCallback, conn Connector) fun
 
 		if err != nil {
 			mq.bridge.Logger().Noticef("message conversion failure %s, %s", mq.String(), err.Error())
-			mq.qMgr.Back()
+			mq.recordFailure(ConversionFailure, err)
+			if err := mq.handlePermanentFailure(qMgr, md, ReportFeedbackConversionFailure, buffer); err != nil {
+				mq.bridge.Logger().Noticef("failed to complete unit of work for %s, %s", mq.String(), err.Error())
+			}
 			return
 		}
 
-		err = cb(natsMsg, replyTo)
+		err = cb(natsMsg, replyTo, expiryDeadline(md.Expiry, start), md.MsgId, md.PutDateTime)
 
 		if err != nil {
 			mq.bridge.Logger().Noticef("publish failure for %s, %s", mq.String(), err.Error())
-			mq.qMgr.Back()
+			mq.recordFailure(PublishFailure, err)
+			if isPermanentPublishError(err) {
+				if err := mq.handlePermanentFailure(qMgr, md, ReportFeedbackPublishFailure, buffer); err != nil {
+					mq.bridge.Logger().Noticef("failed to complete unit of work for %s, %s", mq.String(), err.Error())
+				}
+			} else if err := mq.backout(qMgr); err != nil {
+				mq.bridge.Logger().Noticef("failed to back out for %s, %s", mq.String(), err.Error())
+			}
 		} else {
-			if err := mq.qMgr.Cmit(); err != nil {
+			mq.sendDeliveryReport(qMgr, md, buffer)
+
+			if err := qMgr.Cmit(); err != nil {
 				mq.bridge.Logger().Noticef("failed to commit, %s", err.Error())
+				mq.recordFailure(CommitFailure, err)
 				go mq.bridge.ConnectorError(conn, err) // run in a go routine so we can finish this method and unlock
 				return
 			}
 			mq.stats.AddMessageOut(int64(len(natsMsg)))
 			mq.stats.AddRequestTime(time.Since(start))
+			mq.recordLatency(md.PutDateTime)
 		}
 	}
 }
 
-func (mq *BridgeConnector) stanMessageHandler(natsMsg []byte, replyTo string) error {
+// stanMessageHandler publishes to streaming, which doesn't support headers, so expiry is
+// only available in the encoded message
+func (mq *BridgeConnector) stanMessageHandler(natsMsg []byte, replyTo string, expires time.Time, msgID []byte, putTime time.Time) error {
 	return mq.bridge.Stan().Publish(mq.config.Channel, natsMsg)
 }
 
-func (mq *BridgeConnector) natsMessageHandler(natsMsg []byte, replyTo string) error {
+func (mq *BridgeConnector) natsMessageHandler(natsMsg []byte, replyTo string, expires time.Time, msgID []byte, putTime time.Time) error {
+	var header nats.Header
+
+	if !expires.IsZero() {
+		header = mq.expiryHeaders(expires)
+	}
+
+	if id, ok := mq.natsMsgID(msgID); ok {
+		if header == nil {
+			header = nats.Header{}
+		}
+		header.Set(nats.MsgIdHdr, id)
+	}
+
+	if mq.config.TimestampHeader && !putTime.IsZero() {
+		if header == nil {
+			header = nats.Header{}
+		}
+		header.Set(TimestampHeader, putTime.UTC().Format(time.RFC3339Nano))
+	}
+
+	if header != nil {
+		return mq.natsConn().PublishMsg(&nats.Msg{
+			Subject: mq.config.Subject,
+			Reply:   replyTo,
+			Header:  header,
+			Data:    natsMsg,
+		})
+	}
+
 	var err error
 	if replyTo != "" {
-		err = mq.bridge.NATS().PublishRequest(mq.config.Subject, replyTo, natsMsg)
+		err = mq.natsConn().PublishRequest(mq.config.Subject, replyTo, natsMsg)
 	} else {
-		err = mq.bridge.NATS().Publish(mq.config.Subject, natsMsg)
+		err = mq.natsConn().Publish(mq.config.Subject, natsMsg)
 	}
 	return err
 }
 
-// set up a nats subscription, assumes the lock is held
-func (mq *BridgeConnector) subscribeToNATS(subject string, natsQueue string, dest *ibmmq.MQObject) (*nats.Subscription, error) {
-	callback := func(m *nats.Msg) {
-		mq.Lock()
-		defer mq.Unlock()
-		start := time.Now()
+// clientReconnect records the MQ client moving the connection, MQ doesn't say which instance it moved to
+func (mq *BridgeConnector) clientReconnect(conn Connector, reconnected bool) {
+	if !reconnected {
+		mq.bridge.Logger().Noticef("MQ client is reconnecting %s", mq.String())
+		go mq.bridge.connectorAdvisory(MQReconnectingAdvisory, conn, nil)
+		return
+	}
 
-		qmgrFlag := mq.qMgr
+	mq.Lock()
+	mq.stats.AddMQReconnect()
+	mq.stats.MQInstance = ""
+	mq.Unlock()
 
-		if mq.config.ExcludeHeaders {
-			qmgrFlag = nil
-		}
-		mq.stats.AddMessageIn(int64(len(m.Data)))
-		mqmd, handle, buffer, err := mq.bridge.NATSToMQMessage(m.Data, m.Reply, qmgrFlag)
+	mq.bridge.Logger().Noticef("MQ client reconnected %s", mq.String())
+	go mq.bridge.connectorAdvisory(MQReconnectedAdvisory, conn, nil) // the owner may be locked while it stops workers
+}
 
-		mq.bridge.Logger().Tracef("%s got decoded nats message with body length %d", mq.String(), len(buffer))
+// set up a nats subscription, assumes the lock is held
+func (mq *BridgeConnector) subscribeToNATS(conn Connector, subject string, natsQueue string, dest *ibmmq.MQObject) (*nats.Subscription, error) {
+	if err := mq.dedup.open(mq.natsConn()); err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			mq.bridge.Logger().Noticef("message conversion failure, %s, %s", mq.String(), err.Error())
+	callback := func(m *nats.Msg) {
+		mq.throttle(len(m.Data))
+		if mq.dispatchToTarget(conn, m) {
+			return
+		}
+		if mq.dispatchToWorker(m.Subject, m.Data, m) {
 			return
 		}
+		mq.putNATSMessage(conn, m, dest)
+	}
 
-		pmo := ibmmq.NewMQPMO()
-		pmo.Options = ibmmq.MQPMO_NO_SYNCPOINT
-		pmo.OriginalMsgHandle = handle
+	var sub *nats.Subscription
+	var err error
 
-		err = dest.Put(mqmd, pmo, buffer)
+	if natsQueue == "" {
+		sub, err = mq.natsConn().Subscribe(subject, callback)
+	} else {
+		sub, err = mq.natsConn().QueueSubscribe(subject, natsQueue, callback)
+	}
 
-		if err != nil {
-			mq.bridge.Logger().Noticef("MQ publish failure, %s, %s", mq.String(), err.Error())
-		} else {
-			mq.stats.AddMessageOut(int64(len(buffer)))
-			mq.stats.AddRequestTime(time.Since(start))
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	if natsQueue == "" {
-		return mq.bridge.NATS().Subscribe(subject, callback)
+	if err := mq.setPendingLimits(sub); err != nil {
+		sub.Unsubscribe()
+		return nil, err
 	}
 
-	return mq.bridge.NATS().QueueSubscribe(subject, natsQueue, callback)
+	mq.bridge.registerSubscription(sub, mq)
+	return sub, nil
+}
+
+// putNATSMessage converts a message from NATS and puts it on dest, locks the connector
+// conn is restarted if the put shows that the connection to MQ is broken, the put error is returned.
+func (mq *BridgeConnector) putNATSMessage(conn Connector, m *nats.Msg, dest *ibmmq.MQObject) error {
+	mq.Lock()
+	defer mq.Unlock()
+	start := time.Now()
+
+	qmgrFlag := mq.qMgr
+
+	if mq.config.ExcludeHeaders {
+		qmgrFlag = nil
+	}
+	// in confirm mode the reply subject gets the confirmation, not replies from MQ applications
+	replyTo := m.Reply
+	if mq.config.Confirm {
+		replyTo = ""
+	}
+
+	mq.stats.AddMessageIn(int64(len(m.Data)))
+	mqmd, handle, buffer, bridgeMsg, err := mq.bridge.natsToMQMessage(m.Data, replyTo, qmgrFlag)
+
+	mq.bridge.Logger().Tracef("%s got decoded nats message with body length %d", mq.String(), len(buffer))
+
+	if err != nil {
+		mq.bridge.Logger().Noticef("message conversion failure, %s, %s", mq.String(), err.Error())
+		mq.recordFailure(ConversionFailure, err)
+		mq.confirmPut(m, notConfirmed(err))
+		return nil
+	}
+
+	if !mq.checkExpiry(mqmd, natsDeadline(m, bridgeMsg, start)) {
+		mq.confirmPut(m, notConfirmed(fmt.Errorf("message expired")))
+		return nil
+	}
+
+	dedupKey, claim := mq.checkDuplicate(m.Header, 0, bridgeMsg)
+	switch claim {
+	case dedupDuplicate:
+		mq.confirmPut(m, PutConfirmation{Success: true, Duplicate: true})
+		return nil
+	case dedupPending:
+		mq.confirmPut(m, notConfirmed(fmt.Errorf("a message with the same id is being put")))
+		return nil
+	}
+
+	mq.applyPutSettings(mqmd, m.Subject, bridgeMsg)
+	mq.setReportQueue(mqmd)
+
+	pmo := ibmmq.NewMQPMO()
+	pmo.Options = ibmmq.MQPMO_NO_SYNCPOINT
+	pmo.OriginalMsgHandle = handle
+
+	err = mq.putMessage(dest, mqmd, pmo, buffer)
+
+	if err != nil {
+		mq.releaseClaim(dedupKey)
+		mq.putFailed(conn, err)
+		mq.confirmPut(m, notConfirmed(err))
+	} else {
+		mq.confirmClaim(dedupKey)
+		mq.confirmPut(m, confirmed(mqmd))
+		mq.stats.AddMessageOut(int64(len(buffer)))
+		mq.stats.AddRequestTime(time.Since(start))
+		mq.recordLatency(natsTimestamp(m))
+	}
+
+	return err
 }
 
 // subscribeToChannel uses the bridges STAN connection to subscribe based on the config
 // The start position/time and durable name are optional
-func (mq *BridgeConnector) subscribeToChannel(dest *ibmmq.MQObject) (stan.Subscription, error) {
+func (mq *BridgeConnector) subscribeToChannel(conn Connector, dest *ibmmq.MQObject) (stan.Subscription, error) {
 	if mq.bridge.Stan() == nil {
 		return nil, fmt.Errorf("bridge not configured to use NATS streaming")
 	}
 
+	if err := mq.dedup.open(mq.natsConn()); err != nil {
+		return nil, err
+	}
+
 	options := []stan.SubscriptionOption{}
 
 	if mq.config.DurableName != "" {
@@ -447,39 +779,76 @@ func (mq *BridgeConnector) subscribeToChannel(dest *ibmmq.MQObject) (stan.Subscr
 
 	options = append(options, stan.SetManualAckMode())
 
+	if mq.config.MaxInflight > 0 {
+		options = append(options, stan.MaxInflight(mq.config.MaxInflight))
+	}
+
 	sub, err := mq.bridge.Stan().Subscribe(mq.config.Channel, func(msg *stan.Msg) {
-		mq.Lock()
-		defer mq.Unlock()
-		start := time.Now()
+		mq.throttle(len(msg.Data))
+		if mq.dispatchToWorker(msg.Subject, msg.Data, msg) {
+			return
+		}
+		mq.putStanMessage(conn, msg, dest)
+	}, options...)
 
-		qmgrFlag := mq.qMgr
+	return sub, err
+}
 
-		if mq.config.ExcludeHeaders {
-			qmgrFlag = nil
-		}
+// putStanMessage converts a message from streaming and puts it on dest, the message is acked
+// once it is on the queue, or if it can never be put. Locks the connector.
+func (mq *BridgeConnector) putStanMessage(conn Connector, msg *stan.Msg, dest *ibmmq.MQObject) {
+	mq.Lock()
+	defer mq.Unlock()
+	start := time.Now()
 
-		mq.stats.AddMessageIn(int64(len(msg.Data)))
-		mqmd, handle, buffer, err := mq.bridge.NATSToMQMessage(msg.Data, "", qmgrFlag)
-		if err != nil {
-			mq.bridge.Logger().Noticef("message conversion failure, %s, %s", mq.String(), err.Error())
-			return
-		}
-		mq.bridge.Logger().Tracef("%s got decoded stan message with body length %d", mq.String(), len(buffer))
+	qmgrFlag := mq.qMgr
 
-		pmo := ibmmq.NewMQPMO()
-		pmo.Options = ibmmq.MQPMO_NO_SYNCPOINT
-		pmo.OriginalMsgHandle = handle
+	if mq.config.ExcludeHeaders {
+		qmgrFlag = nil
+	}
 
-		err = dest.Put(mqmd, pmo, buffer)
+	mq.stats.AddMessageIn(int64(len(msg.Data)))
+	mqmd, handle, buffer, bridgeMsg, err := mq.bridge.natsToMQMessage(msg.Data, "", qmgrFlag)
+	if err != nil {
+		mq.bridge.Logger().Noticef("message conversion failure, %s, %s", mq.String(), err.Error())
+		mq.recordFailure(ConversionFailure, err)
+		return
+	}
 
-		if err != nil {
-			mq.bridge.Logger().Noticef("MQ put failure, %s, %s", mq.String(), err.Error())
-		} else {
-			msg.Ack()
-			mq.stats.AddMessageOut(int64(len(buffer)))
-			mq.stats.AddRequestTime(time.Since(start))
-		}
-	}, options...)
+	if !mq.checkExpiry(mqmd, stanDeadline(msg.Timestamp, bridgeMsg)) {
+		msg.Ack() // expired messages are discarded
+		return
+	}
 
-	return sub, err
+	dedupKey, claim := mq.checkDuplicate(nil, msg.Sequence, bridgeMsg)
+	switch claim {
+	case dedupDuplicate:
+		msg.Ack() // already put, this is a redelivery
+		return
+	case dedupPending:
+		return // left for redelivery, in case the pending put fails
+	}
+
+	mq.applyPutSettings(mqmd, msg.Subject, bridgeMsg)
+	mq.setReportQueue(mqmd)
+	mq.bridge.Logger().Tracef("%s got decoded stan message with body length %d", mq.String(), len(buffer))
+
+	pmo := ibmmq.NewMQPMO()
+	pmo.Options = ibmmq.MQPMO_NO_SYNCPOINT
+	pmo.OriginalMsgHandle = handle
+
+	err = mq.putMessage(dest, mqmd, pmo, buffer)
+
+	if err != nil {
+		mq.releaseClaim(dedupKey)
+		if mq.putFailed(conn, err) == PutErrorMessage {
+			msg.Ack() // redelivery would fail the same way
+		}
+	} else {
+		mq.confirmClaim(dedupKey)
+		msg.Ack()
+		mq.stats.AddMessageOut(int64(len(buffer)))
+		mq.stats.AddRequestTime(time.Since(start))
+		mq.recordLatency(time.Unix(0, msg.Timestamp))
+	}
 }
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// DefaultControlPrefix is the subject prefix for the control services if the configuration doesn't set one
+const DefaultControlPrefix = "$MQBRIDGE"
+
+// The control services, requests go to <prefix>.<name>.<service>, except ping which every bridge answers
+const (
+	ControlPing       = "PING"
+	ControlStatus     = "STATUS"
+	ControlConnectors = "CONNECTORS"
+	ControlPause      = "PAUSE"
+	ControlResume     = "RESUME"
+	ControlRestart    = "RESTART"
+	ControlReload     = "RELOAD"
+)
+
+// ControlPingResponse describes a bridge, it is the reply to a ping
+type ControlPingResponse struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	StartTime     int64  `json:"start_time"`
+	Connectors    int    `json:"connectors"`
+	MonitoringURL string `json:"monitoring_url,omitempty"`
+	Role          string `json:"role,omitempty"` // leader or standby, if high availability is configured
+}
+
+// ControlReloadResponse is sent once the new configuration has been read, before the bridge restarts
+type ControlReloadResponse struct {
+	Reloading bool `json:"reloading"`
+}
+
+// controlPrefix returns the subject prefix for the control services
+func (bridge *BridgeServer) controlPrefix() string {
+	if bridge.config.Control.Prefix != "" {
+		return bridge.config.Control.Prefix
+	}
+	return DefaultControlPrefix
+}
+
+// startControl subscribes to the control services on the shared NATS connection, if they are configured
+// assumes the running lock is held by the caller
+func (bridge *BridgeServer) startControl() error {
+	name := bridge.config.Control.Name
+	if name == "" {
+		return nil
+	}
+
+	prefix := bridge.controlPrefix()
+
+	if _, err := bridge.nats.Subscribe(prefix+"."+ControlPing, bridge.handlePing); err != nil {
+		return fmt.Errorf("unable to subscribe to the control services, %s", err.Error())
+	}
+
+	if _, err := bridge.nats.Subscribe(prefix+"."+name+".>", bridge.handleControlRequest); err != nil {
+		return fmt.Errorf("unable to subscribe to the control services, %s", err.Error())
+	}
+
+	bridge.logger.Noticef("control services available on %s.%s", prefix, name)
+	return nil
+}
+
+func (bridge *BridgeServer) handlePing(m *nats.Msg) {
+	bridge.runningLock.Lock()
+	ping := ControlPingResponse{
+		Name:          bridge.config.Control.Name,
+		Version:       version,
+		StartTime:     bridge.startTime.Unix(),
+		Connectors:    len(bridge.currentConnectors()),
+		MonitoringURL: bridge.monitoringURL,
+	}
+	if ha := bridge.ha.Stats(); ha != nil {
+		ping.Role = ha.Role
+	}
+	bridge.runningLock.Unlock()
+
+	bridge.respond(m, ping)
+}
+
+// handleControlRequest dispatches requests for <prefix>.<name>.<service>[.<connector id>]
+func (bridge *BridgeServer) handleControlRequest(m *nats.Msg) {
+	service := strings.TrimPrefix(m.Subject, bridge.controlPrefix()+"."+bridge.config.Control.Name+".")
+	id := ""
+
+	if i := strings.Index(service, "."); i != -1 {
+		service, id = service[:i], service[i+1:]
+	}
+
+	bridge.logger.Tracef("control request %s %s", service, id)
+
+	switch {
+	case service == ControlPing && id == "":
+		bridge.handlePing(m)
+	case service == ControlStatus && id == "":
+		bridge.respond(m, bridge.SafeStats())
+	case service == ControlConnectors && id == "":
+		bridge.respond(m, bridge.Connectors())
+	case service == ControlPause && id != "":
+		bridge.respondWithConnector(m, bridge.PauseConnector, id)
+	case service == ControlResume && id != "":
+		bridge.respondWithConnector(m, bridge.ResumeConnector, id)
+	case service == ControlRestart && id != "":
+		bridge.respondWithConnector(m, bridge.RestartConnector, id)
+	case service == ControlReload && id == "":
+		bridge.handleReload(m)
+	default:
+		bridge.respond(m, AdminError{Error: fmt.Sprintf("unknown control request %q", m.Subject)})
+	}
+}
+
+func (bridge *BridgeServer) respondWithConnector(m *nats.Msg, action func(id string) (ConnectorInfo, error), id string) {
+	info, err := action(id)
+	if err != nil {
+		bridge.respond(m, AdminError{Error: err.Error()})
+		return
+	}
+	bridge.respond(m, info)
+}
+
+// handleReload replies once the configuration file has been read, then restarts the bridge, which
+// closes the connection the request arrived on. If the restart fails the error is logged and the bridge
+// stays stopped, like a restart after a sig-hup.
+func (bridge *BridgeServer) handleReload(m *nats.Msg) {
+	config, err := bridge.reloadConfig()
+	if err != nil {
+		bridge.respond(m, AdminError{Error: err.Error()})
+		return
+	}
+
+	bridge.respond(m, ControlReloadResponse{Reloading: true})
+	if nc := bridge.NATS(); nc != nil {
+		nc.Flush() // make sure the reply is sent before the connection is closed
+	}
+
+	go func() {
+		bridge.logger.Noticef("reloading the configuration from %q, restarting", bridge.configFile)
+
+		if err := bridge.restart(config); err != nil {
+			bridge.Logger().Errorf("error restarting bridge, the bridge is stopped, %s", err.Error())
+		}
+	}()
+}
+
+// respond sends the JSON encoded response, requests without a reply subject are ignored
+func (bridge *BridgeServer) respond(m *nats.Msg, response interface{}) {
+	if m.Reply == "" {
+		return
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		bridge.logger.Noticef("unable to encode control response, %s", err.Error())
+		return
+	}
+
+	if err := m.Respond(data); err != nil {
+		bridge.logger.Noticef("unable to respond to control request, %s", err.Error())
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	nst "github.com/nats-io/nats-server/v2/test"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+// startControlTestBridge runs a NATS server and a bridge, without connectors, loaded from a config file
+func startControlTestBridge(t *testing.T, prefix string) (*BridgeServer, *nats.Conn, func()) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	server := nst.RunServer(&opts)
+
+	dir, err := ioutil.TempDir("", "control")
+	require.NoError(t, err)
+
+	configFile := filepath.Join(dir, "bridge.conf")
+	config := fmt.Sprintf("nats: { Servers: [%q] }\ncontrol: { name: \"test\", prefix: %q }\n", server.ClientURL(), prefix)
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0600))
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfigFile(configFile))
+	require.NoError(t, bridge.Start())
+
+	nc, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+
+	return bridge, nc, func() {
+		nc.Close()
+		bridge.Stop()
+		server.Shutdown()
+		os.RemoveAll(dir)
+	}
+}
+
+func controlRequest(t *testing.T, nc *nats.Conn, subject string, response interface{}) {
+	msg, err := nc.Request(subject, nil, 5*time.Second)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(msg.Data, response))
+}
+
+func TestControlServices(t *testing.T) {
+	_, nc, stop := startControlTestBridge(t, "")
+	defer stop()
+
+	ping := ControlPingResponse{}
+	controlRequest(t, nc, "$MQBRIDGE.PING", &ping)
+	require.Equal(t, "test", ping.Name)
+	require.Equal(t, version, ping.Version)
+
+	ping = ControlPingResponse{}
+	controlRequest(t, nc, "$MQBRIDGE.test.PING", &ping)
+	require.Equal(t, "test", ping.Name)
+
+	connectors := []ConnectorInfo{}
+	controlRequest(t, nc, "$MQBRIDGE.test.CONNECTORS", &connectors)
+	require.Empty(t, connectors)
+
+	stats := BridgeStats{}
+	controlRequest(t, nc, "$MQBRIDGE.test.STATUS", &stats)
+	require.True(t, stats.NATS.Connected)
+
+	failure := AdminError{}
+	controlRequest(t, nc, "$MQBRIDGE.test.PAUSE.missing", &failure)
+	require.Contains(t, failure.Error, errConnectorNotFound.Error())
+
+	failure = AdminError{}
+	controlRequest(t, nc, "$MQBRIDGE.test.PAUSE", &failure)
+	require.Contains(t, failure.Error, "unknown control request")
+}
+
+func TestControlReload(t *testing.T) {
+	bridge, nc, stop := startControlTestBridge(t, "OPS.MQ")
+	defer stop()
+
+	before := bridge.SafeStats().StartTime
+	time.Sleep(1100 * time.Millisecond) // start times are in seconds
+
+	reload := ControlReloadResponse{}
+	controlRequest(t, nc, "OPS.MQ.test.RELOAD", &reload)
+	require.True(t, reload.Reloading)
+
+	// the bridge answers again once it has restarted
+	ping := ControlPingResponse{}
+	for i := 0; i < 50 && ping.StartTime <= before; i++ {
+		msg, err := nc.Request("OPS.MQ.PING", nil, 100*time.Millisecond)
+		if err == nil {
+			require.NoError(t, json.Unmarshal(msg.Data, &ping))
+		}
+	}
+	require.True(t, ping.StartTime > before)
+}
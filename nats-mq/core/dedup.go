@@ -0,0 +1,487 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+)
+
+// The ids used to find duplicate messages
+const (
+	dedupByMsgID    = "msgid"
+	dedupByCorrelID = "correlid"
+	dedupByHeader   = "header"
+	dedupByProperty = "property"
+	dedupBySequence = "sequence"
+)
+
+// defaultDedupWindow matches the JetStream default duplicate window
+const defaultDedupWindow = 2 * time.Minute
+
+// defaultDedupMaxEntries is the number of ids the in memory store remembers
+const defaultDedupMaxEntries = 10000
+
+// dedupPendingTimeout is how long a claim waits for its put before another worker, or bridge, can take it over
+// The id is only recorded once the put is confirmed, so a bridge that stops between the two doesn't drop the redelivery.
+const dedupPendingTimeout = 30 * time.Second
+
+// The results of claiming a message id
+const (
+	dedupClaimed   = iota // the message is new, put it then confirm the claim
+	dedupDuplicate        // a message with the id was put
+	dedupPending          // a message with the id is being put, it may still fail
+)
+
+// dedupStore remembers the ids of messages that were put to MQ
+// Claim is atomic, so only one worker or bridge puts a message with a given id. A claim is pending until it is
+// confirmed after the put, or released if the put fails.
+type dedupStore interface {
+	Claim(key string) (int, error)
+	Confirm(key string) error
+	Release(key string) error
+}
+
+// validateDedup checks the deduplication settings for a connector
+func validateDedup(config conf.ConnectorConfig) error {
+	dedup := config.Dedup
+	key := strings.ToLower(dedup.Key)
+
+	if key == "" {
+		return nil
+	}
+
+	if dedup.Window < 0 || dedup.MaxEntries < 0 {
+		return fmt.Errorf("dedup window and maxentries can't be negative")
+	}
+
+	natsSource := config.Type == conf.NATS2Queue || config.Type == conf.NATS2Topic
+	stanSource := config.Type == conf.Stan2Queue || config.Type == conf.Stan2Topic
+
+	if !natsSource && !stanSource {
+		return fmt.Errorf("%s connectors don't put to MQ, deduplication is only for messages headed to MQ", config.Type)
+	}
+
+	switch key {
+	case dedupByMsgID, dedupByCorrelID:
+	case dedupByProperty:
+		if dedup.Name == "" {
+			return fmt.Errorf("deduplication by property requires a name")
+		}
+	case dedupByHeader:
+		if dedup.Name == "" {
+			return fmt.Errorf("deduplication by header requires a name")
+		}
+		if !natsSource {
+			return fmt.Errorf("deduplication by header requires a NATS connector, streaming doesn't have headers")
+		}
+		return nil
+	case dedupBySequence:
+		if !stanSource {
+			return fmt.Errorf("deduplication by sequence requires a streaming connector")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown dedup key %q, expected msgid, correlid, header, property or sequence", dedup.Key)
+	}
+
+	if config.ExcludeHeaders {
+		return fmt.Errorf("deduplication by %s reads the message header, it can't be used with excludeheaders", key)
+	}
+
+	return nil
+}
+
+// deduplicator finds messages headed to MQ that were already put, it is shared by a connector's workers
+type deduplicator struct {
+	sync.Mutex
+
+	key    string
+	name   string
+	bucket string
+	window time.Duration
+	store  dedupStore
+}
+
+// newDeduplicator returns the deduplicator for a connector, nil if the connector doesn't use one
+// The in memory store is created here so it lasts across restarts, key value stores are opened by open.
+func newDeduplicator(config conf.DedupConfig) *deduplicator {
+	if config.Key == "" {
+		return nil
+	}
+
+	d := &deduplicator{
+		key:    strings.ToLower(config.Key),
+		name:   config.Name,
+		bucket: config.Bucket,
+		window: time.Duration(config.Window) * time.Millisecond,
+	}
+
+	if d.window == 0 {
+		d.window = defaultDedupWindow
+	}
+
+	if d.bucket == "" {
+		maxEntries := config.MaxEntries
+		if maxEntries == 0 {
+			maxEntries = defaultDedupMaxEntries
+		}
+		d.store = newMemoryDedupStore(d.window, maxEntries)
+	}
+
+	return d
+}
+
+// open binds the key value store to nc, creating the bucket if it doesn't exist
+func (d *deduplicator) open(nc *nats.Conn) error {
+	if d == nil || d.bucket == "" {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	kv, err := js.KeyValue(d.bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      d.bucket,
+			Description: "nats-mq bridge deduplication",
+			TTL:         d.window,
+		})
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to open dedup bucket %s, %s", d.bucket, err.Error())
+	}
+
+	d.store = newKVDedupStore(kv, d.pendingTimeout())
+	return nil
+}
+
+// pendingTimeout returns how long a claim waits for its put, at most the window
+func (d *deduplicator) pendingTimeout() time.Duration {
+	if d.window < dedupPendingTimeout {
+		return d.window
+	}
+	return dedupPendingTimeout
+}
+
+func (d *deduplicator) getStore() dedupStore {
+	d.Lock()
+	defer d.Unlock()
+	return d.store
+}
+
+// messageKey returns the id used to find duplicates, false is returned if the message doesn't have one
+func (d *deduplicator) messageKey(header nats.Header, sequence uint64, bridgeMsg *message.BridgeMessage) (string, bool) {
+	switch d.key {
+	case dedupByHeader:
+		value := header.Get(d.name)
+		return value, value != ""
+	case dedupBySequence:
+		return strconv.FormatUint(sequence, 10), sequence > 0
+	}
+
+	if bridgeMsg == nil {
+		return "", false
+	}
+
+	switch d.key {
+	case dedupByMsgID:
+		return hexID(bridgeMsg.Header.MsgID)
+	case dedupByCorrelID:
+		return hexID(bridgeMsg.Header.CorrelID)
+	case dedupByProperty:
+		value, ok := bridgeMsg.GetTypedProperty(d.name)
+		return fmt.Sprint(value), ok
+	}
+
+	return "", false
+}
+
+// hexID encodes an MQ id, false is returned if the id is empty or all zeros
+func hexID(id []byte) (string, bool) {
+	for _, b := range id {
+		if b != 0 {
+			return hex.EncodeToString(id), true
+		}
+	}
+	return "", false
+}
+
+// checkDuplicate claims the message's dedup key and returns it with the result of the claim. The key is empty if the
+// message wasn't claimed, otherwise the claim has to be confirmed after the put or released if it fails. Duplicates
+// are counted, store errors are logged and the message is treated as new. Expects the lock to be held.
+func (mq *BridgeConnector) checkDuplicate(header nats.Header, sequence uint64, bridgeMsg *message.BridgeMessage) (string, int) {
+	if mq.dedup == nil {
+		return "", dedupClaimed
+	}
+
+	key, ok := mq.dedup.messageKey(header, sequence, bridgeMsg)
+	if !ok {
+		return "", dedupClaimed
+	}
+
+	store := mq.dedup.getStore()
+	if store == nil {
+		return "", dedupClaimed
+	}
+
+	result, err := store.Claim(key)
+	if err != nil {
+		mq.bridge.Logger().Noticef("unable to check for duplicates on %s, %s", mq.String(), err.Error())
+		return "", dedupClaimed
+	}
+
+	switch result {
+	case dedupDuplicate:
+		mq.stats.AddDuplicate()
+		mq.bridge.Logger().Tracef("%s dropped duplicate message %s", mq.String(), key)
+		return "", result
+	case dedupPending:
+		mq.bridge.Logger().Tracef("%s is already putting a message with id %s", mq.String(), key)
+		return "", result
+	}
+
+	return key, result
+}
+
+// confirmClaim records the key of a message that was put, so its redeliveries are dropped
+func (mq *BridgeConnector) confirmClaim(key string) {
+	if store := mq.claimStore(key); store != nil {
+		if err := store.Confirm(key); err != nil {
+			mq.bridge.Logger().Noticef("unable to record message %s for %s, %s", key, mq.String(), err.Error())
+		}
+	}
+}
+
+// releaseClaim forgets the key of a message that couldn't be put, so its redelivery isn't dropped
+func (mq *BridgeConnector) releaseClaim(key string) {
+	if store := mq.claimStore(key); store != nil {
+		if err := store.Release(key); err != nil {
+			mq.bridge.Logger().Noticef("unable to release message %s for %s, %s", key, mq.String(), err.Error())
+		}
+	}
+}
+
+// claimStore returns the store holding a claim, nil if the message wasn't claimed
+func (mq *BridgeConnector) claimStore(key string) dedupStore {
+	if mq.dedup == nil || key == "" {
+		return nil
+	}
+	return mq.dedup.getStore()
+}
+
+// natsMsgID returns the JetStream message id for an MQ MsgId, false if the connector doesn't set it
+func (mq *BridgeConnector) natsMsgID(msgID []byte) (string, bool) {
+	if !mq.config.MsgIDHeader {
+		return "", false
+	}
+	return hexID(msgID)
+}
+
+// memoryDedupStore keeps ids for the window, dropping the oldest once there are maxEntries
+type memoryDedupStore struct {
+	sync.Mutex
+
+	window     time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	entries map[string]time.Time
+	order   []dedupEntry    // oldest first, may hold stale entries for keys that were added again
+	pending map[string]bool // claimed, waiting for the put
+}
+
+type dedupEntry struct {
+	key   string
+	added time.Time
+}
+
+func newMemoryDedupStore(window time.Duration, maxEntries int) *memoryDedupStore {
+	return &memoryDedupStore{
+		window:     window,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]time.Time{},
+		pending:    map[string]bool{},
+	}
+}
+
+// Claim marks the key pending, unless it was confirmed within the window or is already pending
+func (s *memoryDedupStore) Claim(key string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.pending[key] {
+		return dedupPending, nil
+	}
+
+	if added, ok := s.entries[key]; ok && s.now().Sub(added) < s.window {
+		return dedupDuplicate, nil
+	}
+
+	s.pending[key] = true
+	return dedupClaimed, nil
+}
+
+// Confirm remembers the key for the window, removing expired entries and the oldest entries over the limit
+func (s *memoryDedupStore) Confirm(key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.pending, key)
+
+	now := s.now()
+	s.entries[key] = now
+	s.order = append(s.order, dedupEntry{key: key, added: now})
+
+	for len(s.order) > 0 {
+		oldest := s.order[0]
+		current, ok := s.entries[oldest.key]
+
+		switch {
+		case !ok || !current.Equal(oldest.added):
+			// stale, the key was removed or added again
+		case now.Sub(oldest.added) >= s.window || len(s.entries) > s.maxEntries:
+			delete(s.entries, oldest.key)
+		default:
+			return nil
+		}
+
+		s.order = s.order[1:]
+	}
+
+	return nil
+}
+
+// Release forgets a pending key
+func (s *memoryDedupStore) Release(key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.pending, key)
+	return nil
+}
+
+// The values of keys in the dedup bucket
+const (
+	kvDedupPending = "pending"
+	kvDedupPut     = "put"
+)
+
+// kvDedupStore keeps ids in a NATS key value bucket, the bucket's TTL removes old ids
+// so bridges sharing the bucket drop each other's duplicates. A claim is written as pending and
+// changed to put once the put is confirmed, a pending claim older than the timeout was abandoned,
+// for example by a bridge that stopped, and can be taken over.
+type kvDedupStore struct {
+	sync.Mutex
+
+	kv      nats.KeyValue
+	timeout time.Duration
+	claims  map[string]uint64 // the revisions of this store's pending claims
+}
+
+func newKVDedupStore(kv nats.KeyValue, timeout time.Duration) *kvDedupStore {
+	return &kvDedupStore{
+		kv:      kv,
+		timeout: timeout,
+		claims:  map[string]uint64{},
+	}
+}
+
+// Claim creates the key as pending, or takes over an abandoned pending claim
+func (s *kvDedupStore) Claim(key string) (int, error) {
+	revision, err := s.kv.Create(kvKey(key), []byte(kvDedupPending))
+
+	if errors.Is(err, nats.ErrKeyExists) {
+		var entry nats.KeyValueEntry
+		entry, err = s.kv.Get(kvKey(key))
+
+		switch {
+		case errors.Is(err, nats.ErrKeyNotFound):
+			return dedupPending, nil // released between the create and the get, the redelivery can claim it
+		case err != nil:
+			return dedupClaimed, err
+		case string(entry.Value()) != kvDedupPending:
+			return dedupDuplicate, nil
+		case time.Since(entry.Created()) < s.timeout:
+			return dedupPending, nil
+		}
+
+		revision, err = s.kv.Update(kvKey(key), []byte(kvDedupPending), entry.Revision())
+		if err != nil {
+			return dedupPending, nil // another worker, or bridge, took it over first
+		}
+	}
+
+	if err != nil {
+		return dedupClaimed, err
+	}
+
+	s.Lock()
+	s.claims[key] = revision
+	s.Unlock()
+
+	return dedupClaimed, nil
+}
+
+// Confirm records that the message with the key was put
+func (s *kvDedupStore) Confirm(key string) error {
+	s.Lock()
+	delete(s.claims, key)
+	s.Unlock()
+
+	_, err := s.kv.Put(kvKey(key), []byte(kvDedupPut))
+	return err
+}
+
+// Release deletes a pending claim, unless another worker, or bridge, took it over
+func (s *kvDedupStore) Release(key string) error {
+	s.Lock()
+	revision, ok := s.claims[key]
+	delete(s.claims, key)
+	s.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	err := s.kv.Delete(kvKey(key), nats.LastRevision(revision))
+	if errors.Is(err, nats.ErrKeyExists) {
+		return nil // taken over
+	}
+	return err
+}
+
+// kvKey encodes an id so it only uses characters allowed in key value keys
+func kvKey(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nst "github.com/nats-io/nats-server/v2/test"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDedup(t *testing.T) {
+	require.NoError(t, validateDedup(conf.ConnectorConfig{Type: conf.Queue2NATS}))
+	require.NoError(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Queue, Dedup: conf.DedupConfig{Key: "msgid"}}))
+	require.NoError(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Topic, Dedup: conf.DedupConfig{Key: "header", Name: "Nats-Msg-Id"}, ExcludeHeaders: true}))
+	require.NoError(t, validateDedup(conf.ConnectorConfig{Type: conf.Stan2Queue, Dedup: conf.DedupConfig{Key: "sequence"}, ExcludeHeaders: true}))
+
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.Queue2NATS, Dedup: conf.DedupConfig{Key: "msgid"}}))
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Queue, Dedup: conf.DedupConfig{Key: "bogus"}}))
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Queue, Dedup: conf.DedupConfig{Key: "header"}}))
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.Stan2Queue, Dedup: conf.DedupConfig{Key: "header", Name: "id"}}))
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Queue, Dedup: conf.DedupConfig{Key: "sequence"}}))
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Queue, Dedup: conf.DedupConfig{Key: "msgid"}, ExcludeHeaders: true}))
+	require.Error(t, validateDedup(conf.ConnectorConfig{Type: conf.NATS2Queue, Dedup: conf.DedupConfig{Key: "msgid", Window: -1}}))
+}
+
+func TestDedupMessageKey(t *testing.T) {
+	msg := message.NewBridgeMessage([]byte("hello"))
+	msg.Header.MsgID = []byte{0, 1, 2}
+	msg.SetProperty("id", "abc")
+
+	header := nats.Header{}
+	header.Set(nats.MsgIdHdr, "one")
+
+	key, ok := newDeduplicator(conf.DedupConfig{Key: "msgid"}).messageKey(nil, 0, msg)
+	require.True(t, ok)
+	require.Equal(t, "000102", key)
+
+	_, ok = newDeduplicator(conf.DedupConfig{Key: "correlid"}).messageKey(nil, 0, msg)
+	require.False(t, ok)
+
+	key, ok = newDeduplicator(conf.DedupConfig{Key: "property", Name: "id"}).messageKey(nil, 0, msg)
+	require.True(t, ok)
+	require.Equal(t, "abc", key)
+
+	key, ok = newDeduplicator(conf.DedupConfig{Key: "header", Name: nats.MsgIdHdr}).messageKey(header, 0, nil)
+	require.True(t, ok)
+	require.Equal(t, "one", key)
+
+	key, ok = newDeduplicator(conf.DedupConfig{Key: "sequence"}).messageKey(nil, 22, nil)
+	require.True(t, ok)
+	require.Equal(t, "22", key)
+
+	_, ok = newDeduplicator(conf.DedupConfig{Key: "msgid"}).messageKey(nil, 0, nil)
+	require.False(t, ok)
+}
+
+func TestMemoryDedupStoreWindow(t *testing.T) {
+	now := time.Now()
+	store := newMemoryDedupStore(time.Minute, 10)
+	store.now = func() time.Time { return now }
+
+	result, err := store.Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, store.Confirm("a"))
+
+	result, err = store.Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupDuplicate, result)
+
+	result, _ = store.Claim("b")
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, store.Confirm("b"))
+
+	now = now.Add(time.Minute)
+	result, _ = store.Claim("a")
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, store.Confirm("a"))
+	require.Len(t, store.entries, 1)
+}
+
+func TestMemoryDedupStoreMaxEntries(t *testing.T) {
+	store := newMemoryDedupStore(time.Minute, 2)
+
+	for _, key := range []string{"a", "b"} {
+		result, _ := store.Claim(key)
+		require.Equal(t, dedupClaimed, result)
+		require.NoError(t, store.Confirm(key))
+	}
+
+	result, _ := store.Claim("a")
+	require.Equal(t, dedupDuplicate, result)
+
+	result, _ = store.Claim("c")
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, store.Confirm("c"))
+
+	result, _ = store.Claim("a")
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, store.Confirm("a"))
+	require.Len(t, store.entries, 2)
+}
+
+func TestMemoryDedupStorePending(t *testing.T) {
+	store := newMemoryDedupStore(time.Minute, 10)
+
+	result, _ := store.Claim("a")
+	require.Equal(t, dedupClaimed, result)
+
+	// the put hasn't finished, so the id isn't recorded yet
+	result, _ = store.Claim("a")
+	require.Equal(t, dedupPending, result)
+	require.Empty(t, store.entries)
+
+	require.NoError(t, store.Release("a"))
+
+	result, _ = store.Claim("a")
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, store.Confirm("a"))
+
+	result, _ = store.Claim("a")
+	require.Equal(t, dedupDuplicate, result)
+	require.Empty(t, store.pending)
+}
+
+func TestKVDedupStoreClaim(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	nc, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	d := newDeduplicator(conf.DedupConfig{Key: "msgid", Bucket: "dedup"})
+	require.NoError(t, d.open(nc))
+
+	// a second bridge sharing the bucket
+	other := newDeduplicator(conf.DedupConfig{Key: "msgid", Bucket: "dedup"})
+	require.NoError(t, other.open(nc))
+
+	result, err := d.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupClaimed, result)
+
+	result, err = other.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupPending, result)
+
+	require.NoError(t, d.getStore().Release("a"))
+
+	result, err = other.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupClaimed, result)
+	require.NoError(t, other.getStore().Confirm("a"))
+
+	result, err = d.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupDuplicate, result)
+}
+
+func TestKVDedupStoreAbandonedClaim(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	nc, err := nats.Connect(server.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	d := newDeduplicator(conf.DedupConfig{Key: "msgid", Bucket: "dedup"})
+	require.NoError(t, d.open(nc))
+
+	other := newDeduplicator(conf.DedupConfig{Key: "msgid", Bucket: "dedup"})
+	require.NoError(t, other.open(nc))
+	other.getStore().(*kvDedupStore).timeout = 0
+
+	// the first bridge stops before its put is confirmed
+	result, err := d.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupClaimed, result)
+
+	result, err = other.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupClaimed, result)
+
+	// releasing the abandoned claim leaves the new one alone
+	require.NoError(t, d.getStore().Release("a"))
+	result, err = d.getStore().Claim("a")
+	require.NoError(t, err)
+	require.Equal(t, dedupPending, result)
+}
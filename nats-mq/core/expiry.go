@@ -0,0 +1,114 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/message"
+	nats "github.com/nats-io/nats.go"
+)
+
+// ExpiresHeader is the NATS header used to carry the time a message expires, in RFC3339 format
+const ExpiresHeader = "MQ-Expires"
+
+// MsgTTLHeader is the JetStream per-message TTL header, in seconds
+const MsgTTLHeader = "Nats-TTL"
+
+// expiryDeadline converts an MQ expiry, in tenths of a second, to a time relative to from
+// the zero time is returned for messages that don't expire
+func expiryDeadline(expiry int32, from time.Time) time.Time {
+	if expiry <= 0 {
+		return time.Time{}
+	}
+	return from.Add(time.Duration(expiry) * 100 * time.Millisecond)
+}
+
+// remainingExpiry converts a deadline to an MQ expiry, in tenths of a second, rounding up
+// false is returned if the deadline has passed
+func remainingExpiry(deadline time.Time, now time.Time) (int32, bool) {
+	remaining := deadline.Sub(now)
+
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	tenths := (remaining + 100*time.Millisecond - 1) / (100 * time.Millisecond)
+	return int32(tenths), true
+}
+
+// natsDeadline returns the time a message from NATS expires, using the expires header if it is
+// present, otherwise the expiry in the bridge message header is relative to when the message was received
+func natsDeadline(m *nats.Msg, bridgeMsg *message.BridgeMessage, received time.Time) time.Time {
+	if m.Header != nil {
+		if value := m.Header.Get(ExpiresHeader); value != "" {
+			if deadline, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return deadline
+			}
+		}
+	}
+
+	if bridgeMsg != nil {
+		return expiryDeadline(bridgeMsg.Header.Expiry, received)
+	}
+
+	return time.Time{}
+}
+
+// stanDeadline returns the time a message from streaming expires, relative to when it was stored
+func stanDeadline(timestamp int64, bridgeMsg *message.BridgeMessage) time.Time {
+	if bridgeMsg == nil {
+		return time.Time{}
+	}
+	return expiryDeadline(bridgeMsg.Header.Expiry, time.Unix(0, timestamp))
+}
+
+// expiryHeaders builds the NATS headers for a message from MQ that expires at deadline
+func (mq *BridgeConnector) expiryHeaders(deadline time.Time) nats.Header {
+	header := nats.Header{}
+	header.Set(ExpiresHeader, deadline.UTC().Format(time.RFC3339Nano))
+
+	if mq.config.MsgTTL {
+		seconds := int64((time.Until(deadline) + time.Second - 1) / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		header.Set(MsgTTLHeader, strconv.FormatInt(seconds, 10))
+	}
+
+	return header
+}
+
+// checkExpiry sets the expiry for a message headed to MQ from its deadline, returns false and counts
+// the message if it has already expired. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) checkExpiry(mqmd *ibmmq.MQMD, deadline time.Time) bool {
+	if deadline.IsZero() {
+		return true
+	}
+
+	expiry, ok := remainingExpiry(deadline, time.Now())
+
+	if !ok {
+		mq.stats.AddExpired()
+		mq.bridge.Logger().Tracef("%s discarded message that expired at %s", mq.String(), deadline.Format(time.RFC3339Nano))
+		return false
+	}
+
+	mqmd.Expiry = expiry
+	return true
+}
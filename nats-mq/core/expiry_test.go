@@ -0,0 +1,66 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/message"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryDeadline(t *testing.T) {
+	now := time.Now()
+	require.True(t, expiryDeadline(-1, now).IsZero())
+	require.True(t, expiryDeadline(0, now).IsZero())
+	require.Equal(t, now.Add(1500*time.Millisecond), expiryDeadline(15, now))
+}
+
+func TestRemainingExpiry(t *testing.T) {
+	now := time.Now()
+
+	expiry, ok := remainingExpiry(now.Add(1500*time.Millisecond), now)
+	require.True(t, ok)
+	require.Equal(t, int32(15), expiry)
+
+	expiry, ok = remainingExpiry(now.Add(time.Millisecond), now)
+	require.True(t, ok)
+	require.Equal(t, int32(1), expiry)
+
+	_, ok = remainingExpiry(now.Add(-time.Millisecond), now)
+	require.False(t, ok)
+}
+
+func TestNATSDeadline(t *testing.T) {
+	now := time.Now()
+	deadline := now.Add(time.Minute).UTC()
+
+	m := nats.NewMsg("test")
+	require.True(t, natsDeadline(m, nil, now).IsZero())
+
+	bridgeMsg := message.NewBridgeMessage([]byte("hello"))
+	bridgeMsg.Header.Expiry = 20
+	require.Equal(t, now.Add(2*time.Second), natsDeadline(m, bridgeMsg, now))
+
+	// The header wins
+	m.Header.Set(ExpiresHeader, deadline.Format(time.RFC3339Nano))
+	require.True(t, deadline.Equal(natsDeadline(m, bridgeMsg, now)))
+
+	stored := now.Add(-time.Second)
+	require.Equal(t, stored.Add(2*time.Second).UnixNano(), stanDeadline(stored.UnixNano(), bridgeMsg).UnixNano())
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+)
+
+// The classes of failure counted in the connector statistics
+const (
+	ConversionFailure = "conversion"
+	PutFailure        = "put"
+	PublishFailure    = "publish"
+	CommitFailure     = "commit"
+)
+
+// maxRecentErrors is the number of failures kept in each connector's statistics
+const maxRecentErrors = 10
+
+// mqReason returns the MQ reason code for err, 0 if it isn't, or doesn't wrap, an MQ error
+func mqReason(err error) int32 {
+	var mqret *ibmmq.MQReturn
+	if errors.As(err, &mqret) {
+		return mqret.MQRC
+	}
+	return 0
+}
+
+// recordFailure counts a failure and keeps it with the recent errors, expects the lock to be held
+func (mq *BridgeConnector) recordFailure(kind string, err error) {
+	mq.stats.AddFailure(ErrorStats{
+		Time:   time.Now(),
+		Kind:   kind,
+		Reason: mqReason(err),
+		Error:  err.Error(),
+	})
+}
+
+// backout backs out the current unit of work, counting it if the back out worked, expects the lock to be held
+func (mq *BridgeConnector) backout(qMgr *ibmmq.MQQueueManager) error {
+	if err := qMgr.Back(); err != nil {
+		return err
+	}
+	mq.stats.AddBackout()
+	return nil
+}
+
+// mergeRecentErrors combines lists of recent errors, keeping the newest
+func mergeRecentErrors(lists ...[]ErrorStats) []ErrorStats {
+	merged := []ErrorStats{}
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Time.Before(merged[j].Time)
+	})
+
+	if len(merged) > maxRecentErrors {
+		merged = merged[len(merged)-maxRecentErrors:]
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return merged
+}
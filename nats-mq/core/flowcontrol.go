@@ -0,0 +1,225 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	nats "github.com/nats-io/nats.go"
+)
+
+// flowControlInterval is how often a paused listener checks if NATS has caught up
+const flowControlInterval = 100 * time.Millisecond
+
+// setPendingLimits applies the connector's pending limits to a NATS subscription
+// unset limits use the NATS defaults
+func (mq *BridgeConnector) setPendingLimits(sub *nats.Subscription) error {
+	if mq.config.PendingMsgsLimit == 0 && mq.config.PendingBytesLimit == 0 {
+		return nil
+	}
+
+	msgs := mq.config.PendingMsgsLimit
+	if msgs == 0 {
+		msgs = nats.DefaultSubPendingMsgsLimit
+	}
+
+	bytes := mq.config.PendingBytesLimit
+	if bytes == 0 {
+		bytes = nats.DefaultSubPendingBytesLimit
+	}
+
+	return sub.SetPendingLimits(msgs, bytes)
+}
+
+// registerSubscription tracks the connector for a subscription, so slow consumer errors can be counted
+func (bridge *BridgeServer) registerSubscription(sub *nats.Subscription, mq *BridgeConnector) {
+	bridge.natsLock.Lock()
+	defer bridge.natsLock.Unlock()
+	bridge.subscriptions[sub] = mq
+}
+
+// unsubscribeFromNATS removes the subscription and stops tracking it, expects the lock to be held by the caller
+func (mq *BridgeConnector) unsubscribeFromNATS(sub *nats.Subscription) error {
+	mq.bridge.natsLock.Lock()
+	delete(mq.bridge.subscriptions, sub)
+	mq.bridge.natsLock.Unlock()
+
+	return sub.Unsubscribe()
+}
+
+// slowConsumer counts a slow consumer error against the connector that owns the subscription
+func (bridge *BridgeServer) slowConsumer(sub *nats.Subscription) {
+	bridge.natsLock.Lock()
+	mq, ok := bridge.subscriptions[sub]
+	bridge.natsLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	mq.Lock()
+	mq.stats.AddSlowConsumer()
+	mq.Unlock()
+
+	pending, _, _ := sub.Pending()
+	dropped, _ := sub.Dropped()
+	bridge.logger.Warnf("%s is a slow consumer, %d messages pending, %d dropped", mq.String(), pending, dropped)
+}
+
+// overBufferLimit returns true if the NATS connection has buffered more than the connector allows
+// buffering happens while NATS reconnects, or if the connection can't keep up
+func (mq *BridgeConnector) overBufferLimit() bool {
+	if mq.config.MaxBuffered <= 0 {
+		return false
+	}
+
+	nc := mq.natsConn()
+	if nc == nil {
+		return false
+	}
+
+	buffered, err := nc.Buffered()
+	if err != nil {
+		return false
+	}
+
+	return buffered > mq.config.MaxBuffered
+}
+
+// natsAvailable is used by connectors that publish to NATS to decide if they have to stop
+// with flow control on they keep running while NATS reconnects and pause instead
+func (mq *BridgeConnector) natsAvailable() bool {
+	if mq.config.MaxBuffered <= 0 {
+		return mq.checkNATS()
+	}
+
+	nc := mq.natsConn()
+	return nc != nil && !nc.IsClosed()
+}
+
+// flowControl suspends the MQ callbacks for a listener while NATS is buffering too much
+type flowControl struct {
+	sync.Mutex
+
+	mq        *BridgeConnector
+	qMgr      *ibmmq.MQQueueManager
+	suspended bool
+	stopped   bool
+}
+
+func (mq *BridgeConnector) newFlowControl(qMgr *ibmmq.MQQueueManager) *flowControl {
+	return &flowControl{
+		mq:   mq,
+		qMgr: qMgr,
+	}
+}
+
+// check is called from the MQ callback, after a message is handled, and suspends the callbacks if NATS is
+// buffering too much. Suspending from the callback is allowed by MQ and takes effect when it returns.
+func (fc *flowControl) check() {
+	if !fc.mq.overBufferLimit() {
+		return
+	}
+
+	fc.Lock()
+
+	if fc.suspended || fc.stopped {
+		fc.Unlock()
+		return
+	}
+
+	ctlo := ibmmq.NewMQCTLO()
+	if err := fc.qMgr.Ctl(ibmmq.MQOP_SUSPEND, ctlo); err != nil {
+		fc.Unlock()
+		fc.mq.bridge.Logger().Noticef("error pausing %s, %s", fc.mq.String(), err.Error())
+		return
+	}
+
+	fc.suspended = true
+	fc.Unlock()
+
+	// the connector lock is taken by shutdown before it stops the flow control, so don't hold both
+	fc.mq.pausedForFlowControl()
+
+	go fc.resumeWhenDrained()
+}
+
+func (fc *flowControl) resumeWhenDrained() {
+	for {
+		time.Sleep(flowControlInterval)
+
+		fc.Lock()
+		if fc.stopped {
+			fc.suspended = false
+			fc.Unlock()
+			return // the listener was stopped, there is nothing to resume
+		}
+		fc.Unlock()
+
+		if fc.mq.overBufferLimit() || !fc.mq.checkNATS() {
+			continue
+		}
+
+		fc.Lock()
+		if !fc.stopped {
+			ctlo := ibmmq.NewMQCTLO()
+			if err := fc.qMgr.Ctl(ibmmq.MQOP_RESUME, ctlo); err != nil {
+				fc.mq.bridge.Logger().Noticef("error resuming %s, %s", fc.mq.String(), err.Error())
+			} else {
+				fc.mq.bridge.Logger().Noticef("resumed %s, NATS has caught up", fc.mq.String())
+			}
+		}
+		fc.suspended = false
+		fc.Unlock()
+		return
+	}
+}
+
+// stop keeps a paused listener from being resumed, call before stopping the callbacks
+func (fc *flowControl) stop() {
+	fc.Lock()
+	defer fc.Unlock()
+	fc.stopped = true
+}
+
+// waitForNATS is used by polling listeners, it blocks while NATS is buffering too much or done is closed
+func (mq *BridgeConnector) waitForNATS(done chan bool) {
+	if !mq.overBufferLimit() {
+		return
+	}
+
+	mq.pausedForFlowControl()
+
+	for mq.overBufferLimit() || !mq.checkNATS() {
+		select {
+		case <-done:
+			return
+		case <-time.After(flowControlInterval):
+		}
+	}
+
+	mq.bridge.Logger().Noticef("resumed %s, NATS has caught up", mq.String())
+}
+
+func (mq *BridgeConnector) pausedForFlowControl() {
+	mq.Lock()
+	mq.stats.AddFlowControlPause()
+	mq.Unlock()
+
+	mq.bridge.Logger().Noticef("pausing %s, NATS is buffering more than %d bytes", mq.String(), mq.config.MaxBuffered)
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowConsumerIsCounted(t *testing.T) {
+	bridge := NewBridgeServer()
+	bridge.subscriptions = map[*nats.Subscription]*BridgeConnector{}
+
+	connector := &BridgeConnector{}
+	connector.init(bridge, conf.ConnectorConfig{}, "test")
+
+	sub := &nats.Subscription{}
+	bridge.registerSubscription(sub, connector)
+
+	bridge.natsError(nil, sub, nats.ErrSlowConsumer)
+	bridge.natsError(nil, sub, nats.ErrSlowConsumer)
+	require.Equal(t, int64(2), connector.Stats().SlowConsumers)
+
+	// other subscriptions are ignored
+	bridge.natsError(nil, &nats.Subscription{}, nats.ErrSlowConsumer)
+	require.Equal(t, int64(2), connector.Stats().SlowConsumers)
+}
+
+func TestPendingLimits(t *testing.T) {
+	connect := []conf.ConnectorConfig{
+		{
+			Type:             "NATS2Queue",
+			Subject:          "test",
+			Queue:            "DEV.QUEUE.1",
+			ExcludeHeaders:   true,
+			PendingMsgsLimit: 10,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	connector := tbs.Bridge.connectors[0].(*NATS2QueueConnector)
+
+	connector.Lock()
+	msgs, bytes, err := connector.sub.PendingLimits()
+	connector.Unlock()
+
+	require.NoError(t, err)
+	require.Equal(t, 10, msgs)
+	require.Equal(t, nats.DefaultSubPendingBytesLimit, bytes)
+}
+
+func TestStoppedFlowControlStopsPolling(t *testing.T) {
+	fc := (&BridgeConnector{}).newFlowControl(nil)
+	fc.suspended = true
+	fc.stop()
+
+	done := make(chan struct{})
+	go func() {
+		fc.resumeWhenDrained()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("resumeWhenDrained kept polling after the flow control stopped")
+	}
+	require.False(t, fc.suspended)
+}
@@ -0,0 +1,503 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+)
+
+// The roles a bridge can have when high availability is configured
+const (
+	HALeader  = "leader"
+	HAStandby = "standby"
+)
+
+// defaultHAKey is the lease key if the configuration doesn't set one
+const defaultHAKey = "leader"
+
+// defaultHALeaseTTL is used if the configuration doesn't set a lease ttl
+const defaultHALeaseTTL = 10 * time.Second
+
+// haElector holds, or waits for, the lease that makes a bridge the leader of its group
+// The lease is a key in a bucket whose entries expire after the lease ttl, the leader renews it by updating
+// the key at the revision it last wrote and standbys try to create it, which only works once it is gone.
+type haElector struct {
+	sync.Mutex
+
+	bucket    string
+	key       string
+	name      string
+	ttl       time.Duration
+	heartbeat time.Duration
+
+	kv        nats.KeyValue
+	timer     *reconnectTimer
+	role      string
+	leader    string    // the bridge holding the lease when it was last read
+	revision  uint64    // the revision of the lease this bridge wrote, 0 once another bridge holds it
+	renewed   time.Time // when this bridge last renewed the lease, taken before the update is sent
+	since     time.Time
+	elections int64
+	lastErr   string
+	closed    bool // set when the bridge stops, the lease isn't renewed or taken after that
+}
+
+// newHAElector returns the elector for the bridge, nil if high availability isn't configured
+func newHAElector(config conf.HAConfig, controlName string) *haElector {
+	if config.Bucket == "" {
+		return nil
+	}
+
+	ha := &haElector{
+		bucket:    config.Bucket,
+		key:       config.Key,
+		name:      config.Name,
+		ttl:       time.Duration(config.LeaseTTL) * time.Millisecond,
+		heartbeat: time.Duration(config.Heartbeat) * time.Millisecond,
+		role:      HAStandby,
+		since:     time.Now(),
+	}
+
+	if ha.key == "" {
+		ha.key = defaultHAKey
+	}
+
+	if ha.name == "" {
+		ha.name = controlName
+	}
+
+	if ha.name == "" {
+		host, _ := os.Hostname()
+		ha.name = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	if ha.ttl == 0 {
+		ha.ttl = defaultHALeaseTTL
+	}
+
+	if ha.heartbeat == 0 {
+		ha.heartbeat = ha.ttl / 3
+	}
+
+	return ha
+}
+
+// open binds the elector to the lease bucket, creating the bucket if it doesn't exist
+// An existing bucket keeps the ttl it was created with.
+func (ha *haElector) open(nc *nats.Conn) error {
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	kv, err := js.KeyValue(ha.bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      ha.bucket,
+			Description: "nats-mq bridge leader lease",
+			TTL:         ha.ttl,
+		})
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to open ha bucket %s, %s", ha.bucket, err.Error())
+	}
+
+	ha.Lock()
+	ha.kv = kv
+	ha.Unlock()
+	return nil
+}
+
+// isLeader returns true if this bridge holds the lease, false if high availability isn't configured
+func (ha *haElector) isLeader() bool {
+	if ha == nil {
+		return false
+	}
+
+	ha.Lock()
+	defer ha.Unlock()
+	return ha.role == HALeader
+}
+
+// isStandby returns true if this bridge is waiting for the lease, false if high availability isn't configured
+func (ha *haElector) isStandby() bool {
+	if ha == nil {
+		return false
+	}
+
+	ha.Lock()
+	defer ha.Unlock()
+	return ha.role == HAStandby
+}
+
+// isClosed returns true once the bridge that owns the elector has stopped
+func (ha *haElector) isClosed() bool {
+	ha.Lock()
+	defer ha.Unlock()
+	return ha.closed
+}
+
+// holdsLease returns true if this bridge wrote the lease and renewed it recently enough that it hasn't expired
+func (ha *haElector) holdsLease() bool {
+	ha.Lock()
+	defer ha.Unlock()
+	return !ha.closed && ha.revision != 0 && time.Since(ha.renewed) < ha.ttl
+}
+
+// setRole records a change of role, a closed elector can't become the leader
+func (ha *haElector) setRole(role string) {
+	ha.Lock()
+	defer ha.Unlock()
+
+	if ha.role == role || (ha.closed && role == HALeader) {
+		return
+	}
+
+	ha.role = role
+	ha.since = time.Now()
+
+	if role == HALeader {
+		ha.elections++
+		ha.leader = ha.name
+	} else if ha.leader == ha.name {
+		ha.leader = ""
+	}
+}
+
+// recordError remembers the error, it returns true if it is different from the last one so repeats aren't logged
+func (ha *haElector) recordError(err error) bool {
+	ha.Lock()
+	defer ha.Unlock()
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+
+	changed := msg != ha.lastErr
+	ha.lastErr = msg
+	return changed
+}
+
+// acquire tries to create the lease, a lease this bridge left behind, for example before a quick restart,
+// is taken back, it returns false if another bridge holds the lease
+func (ha *haElector) acquire() (bool, error) {
+	ha.Lock()
+	defer ha.Unlock()
+
+	if ha.closed {
+		return false, nil
+	}
+
+	start := time.Now()
+	revision, err := ha.kv.Create(ha.key, []byte(ha.name))
+
+	if errors.Is(err, nats.ErrKeyExists) {
+		entry, getErr := ha.kv.Get(ha.key)
+
+		if errors.Is(getErr, nats.ErrKeyNotFound) {
+			return false, nil // expired between the create and the get, try again on the next heartbeat
+		}
+
+		if getErr != nil {
+			return false, getErr
+		}
+
+		ha.leader = string(entry.Value())
+
+		if ha.leader != ha.name {
+			return false, nil
+		}
+
+		revision, err = ha.kv.Update(ha.key, []byte(ha.name), entry.Revision())
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	ha.revision = revision
+	ha.renewed = start
+	return true, nil
+}
+
+// renew updates the lease, it returns false once another bridge holds it or if this bridge can't
+// renew it before it expires
+func (ha *haElector) renew() (bool, error) {
+	ha.Lock()
+	defer ha.Unlock()
+
+	if ha.closed {
+		return false, nil
+	}
+
+	start := time.Now()
+	revision, err := ha.kv.Update(ha.key, []byte(ha.name), ha.revision)
+
+	if err == nil {
+		ha.revision = revision
+		ha.renewed = start
+		return true, nil
+	}
+
+	entry, getErr := ha.kv.Get(ha.key)
+
+	switch {
+	case errors.Is(getErr, nats.ErrKeyNotFound):
+		// the lease expired before it was renewed, take it again unless a standby was faster
+		revision, err = ha.kv.Create(ha.key, []byte(ha.name))
+		if err != nil {
+			return false, err
+		}
+		ha.revision = revision
+		ha.renewed = start
+		return true, nil
+	case getErr != nil:
+		// stop before the lease expires, the next heartbeat might be too late
+		return time.Since(ha.renewed)+ha.heartbeat < ha.ttl, err
+	}
+
+	ha.leader = string(entry.Value())
+
+	if ha.leader != ha.name {
+		ha.revision = 0
+		return false, nil
+	}
+
+	// the earlier update was written even though it failed, the lease runs from when it was written
+	ha.revision = entry.Revision()
+	ha.renewed = entry.Created()
+	return true, nil
+}
+
+// release deletes the lease, if this bridge still holds it, so a standby can take over without waiting for it to expire
+// a closed elector stops renewing and campaigning, a renewal in progress finishes before the lease is deleted
+func (ha *haElector) release(close bool) error {
+	ha.Lock()
+	defer ha.Unlock()
+
+	if close {
+		ha.closed = true
+	}
+
+	if ha.kv == nil || ha.revision == 0 {
+		return nil
+	}
+
+	revision := ha.revision
+	ha.revision = 0
+	return ha.kv.Delete(ha.key, nats.LastRevision(revision))
+}
+
+// Stats returns the role of the bridge, nil if high availability isn't configured
+func (ha *haElector) Stats() *HAStats {
+	if ha == nil {
+		return nil
+	}
+
+	ha.Lock()
+	defer ha.Unlock()
+
+	return &HAStats{
+		Role:      ha.role,
+		Name:      ha.name,
+		Leader:    ha.leader,
+		Bucket:    ha.bucket,
+		Key:       ha.key,
+		Since:     ha.since.Unix(),
+		Elections: ha.elections,
+		LastError: ha.lastErr,
+	}
+}
+
+// startHA opens the lease bucket and campaigns for the lease, the connectors only start if this bridge
+// becomes the leader, a heartbeat keeps renewing or campaigning for the lease until the bridge stops
+// assumes the running lock is held by the caller
+func (bridge *BridgeServer) startHA() error {
+	ha := bridge.ha
+
+	if err := ha.open(bridge.NATS()); err != nil {
+		return err
+	}
+
+	bridge.logger.Noticef("high availability is on, %s is campaigning for %s in bucket %s", ha.name, ha.key, ha.bucket)
+
+	won, err := ha.acquire()
+	if err != nil {
+		return fmt.Errorf("unable to campaign for the ha lease, %s", err.Error())
+	}
+
+	if won {
+		ha.setRole(HALeader)
+	}
+
+	// started first so the lease is renewed while the connectors start
+	timer := newReconnectTimer()
+	ha.timer = timer
+
+	go func() {
+		for <-timer.After(ha.heartbeat) {
+			if !bridge.haHeartbeat(ha) {
+				return
+			}
+		}
+	}()
+
+	if won {
+		return bridge.becomeLeader()
+	}
+
+	bridge.logger.Noticef("%s holds the ha lease, %s is a standby", ha.Stats().Leader, ha.name)
+	bridge.haAdvisory(BridgeStandbyAdvisory, nil)
+	return nil
+}
+
+// haHeartbeat renews the lease on the leader, and campaigns for it on a standby, it returns false once
+// the bridge has stopped. The lease is renewed without the running lock, so the leader keeps it while
+// its connectors start or stop.
+func (bridge *BridgeServer) haHeartbeat(ha *haElector) bool {
+	if ha.isLeader() {
+		held, err := ha.renew()
+		if ha.isClosed() {
+			return false
+		}
+
+		if ha.recordError(err) && err != nil {
+			bridge.logger.Warnf("unable to renew the ha lease, %s", err.Error())
+		}
+
+		if !held {
+			bridge.haStepDown(ha, err)
+		}
+
+		return true
+	}
+
+	won, err := ha.acquire()
+	if ha.isClosed() {
+		return false
+	}
+
+	if ha.recordError(err) && err != nil {
+		bridge.logger.Noticef("unable to campaign for the ha lease, %s", err.Error())
+	}
+
+	if won {
+		ha.setRole(HALeader) // so the next heartbeat renews the lease, even if the connectors are still starting
+		go bridge.lead(ha)
+	}
+
+	return true
+}
+
+// lead starts the connectors after the heartbeat won the lease, on its own go routine so the heartbeat
+// isn't held up by the running lock or the connectors
+func (bridge *BridgeServer) lead(ha *haElector) {
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	if !bridge.running || bridge.ha != ha || !ha.isLeader() {
+		return // stopped, or stepped down, before the connectors could start
+	}
+
+	if err := bridge.becomeLeader(); err != nil {
+		bridge.logger.Errorf("unable to start the connectors as the leader, %s", err.Error())
+		bridge.stepDown(err)
+		bridge.releaseLease() // let another bridge try
+	}
+}
+
+// haStepDown steps down after the heartbeat lost the lease, unless the bridge was stopped or restarted
+func (bridge *BridgeServer) haStepDown(ha *haElector, err error) {
+	bridge.runningLock.Lock()
+	defer bridge.runningLock.Unlock()
+
+	if bridge.running && bridge.ha == ha {
+		bridge.stepDown(err)
+	}
+}
+
+// becomeLeader starts the connectors once this bridge holds the lease, they are shut down again if the lease
+// was lost while they started, assumes the running lock is held by the caller
+func (bridge *BridgeServer) becomeLeader() error {
+	ha := bridge.ha
+
+	bridge.logger.Noticef("%s is the leader, starting connectors", ha.name)
+	bridge.haAdvisory(BridgeLeaderAdvisory, nil)
+
+	if err := bridge.startConnectors(); err != nil {
+		return err
+	}
+
+	if !ha.holdsLease() {
+		bridge.stepDown(fmt.Errorf("the ha lease was lost while the connectors started"))
+	}
+
+	return nil
+}
+
+// stepDown shuts down the connectors once this bridge has lost, or given up, the lease, another bridge
+// may already be running them, assumes the running lock is held by the caller
+func (bridge *BridgeServer) stepDown(err error) {
+	ha := bridge.ha
+
+	if ha.isStandby() {
+		return // already stepped down
+	}
+
+	// a standby doesn't restart connectors, so none come back while they are shut down
+	ha.setRole(HAStandby)
+	bridge.stopReconnectTimer()
+
+	bridge.logger.Warnf("%s is no longer the leader, shutting down connectors", ha.name)
+	bridge.stopConnectors()
+
+	bridge.haAdvisory(BridgeStandbyAdvisory, err)
+}
+
+// releaseLease deletes the lease if this bridge holds it, errors are logged
+func (bridge *BridgeServer) releaseLease() {
+	if err := bridge.ha.release(false); err != nil {
+		bridge.logger.Noticef("unable to release the ha lease, %s", err.Error())
+	}
+}
+
+// stopHA stops the heartbeat and releases the lease, the connectors should already be shut down
+// assumes the running lock is held by the caller
+func (bridge *BridgeServer) stopHA() {
+	ha := bridge.ha
+	if ha == nil {
+		return
+	}
+
+	if ha.timer != nil {
+		ha.timer.Cancel()
+		ha.timer = nil
+	}
+
+	if err := ha.release(true); err != nil {
+		bridge.logger.Noticef("unable to release the ha lease, %s", err.Error())
+	}
+	ha.setRole(HAStandby)
+}
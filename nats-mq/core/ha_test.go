@@ -0,0 +1,220 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nst "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+)
+
+func startHATestBridge(t *testing.T, url string, name string) *BridgeServer {
+	config := conf.DefaultBridgeConfig()
+	config.NATS.Servers = []string{url}
+	config.HA = conf.HAConfig{Bucket: "mqbridge_ha", Name: name, LeaseTTL: 600, Heartbeat: 100}
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	require.NoError(t, bridge.Start())
+	return bridge
+}
+
+func haRole(bridge *BridgeServer) string {
+	return bridge.ha.Stats().Role
+}
+
+func TestHAElectorDefaults(t *testing.T) {
+	require.Nil(t, newHAElector(conf.HAConfig{}, "bridge"))
+	require.Nil(t, newHAElector(conf.HAConfig{}, "bridge").Stats())
+	require.False(t, newHAElector(conf.HAConfig{}, "bridge").isStandby())
+
+	ha := newHAElector(conf.HAConfig{Bucket: "ha"}, "bridge")
+	require.Equal(t, defaultHAKey, ha.key)
+	require.Equal(t, "bridge", ha.name)
+	require.Equal(t, defaultHALeaseTTL, ha.ttl)
+	require.Equal(t, defaultHALeaseTTL/3, ha.heartbeat)
+	require.True(t, ha.isStandby())
+
+	ha = newHAElector(conf.HAConfig{Bucket: "ha", Key: "orders", Name: "one", LeaseTTL: 3000, Heartbeat: 500}, "bridge")
+	require.Equal(t, "orders", ha.key)
+	require.Equal(t, "one", ha.name)
+	require.Equal(t, 3*time.Second, ha.ttl)
+	require.Equal(t, 500*time.Millisecond, ha.heartbeat)
+
+	ha = newHAElector(conf.HAConfig{Bucket: "ha"}, "")
+	require.Contains(t, ha.name, fmt.Sprintf("-%d", os.Getpid()))
+
+	ha.setRole(HALeader)
+	stats := ha.Stats()
+	require.Equal(t, HALeader, stats.Role)
+	require.Equal(t, ha.name, stats.Leader)
+	require.Equal(t, int64(1), stats.Elections)
+
+	ha.setRole(HAStandby)
+	require.Empty(t, ha.Stats().Leader)
+}
+
+func TestHAFailover(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	one := startHATestBridge(t, server.ClientURL(), "one")
+	defer one.Stop()
+	require.Equal(t, HALeader, haRole(one))
+
+	two := startHATestBridge(t, server.ClientURL(), "two")
+	defer two.Stop()
+
+	connector := &flakyConnector{stateConnector: stateConnector{id: "standby"}}
+	two.connectorsLock.Lock()
+	two.connectors = []Connector{connector}
+	two.connectorsLock.Unlock()
+
+	// longer than the lease, the leader has to renew it to keep it
+	time.Sleep(time.Second)
+
+	stats := two.ha.Stats()
+	require.Equal(t, HAStandby, stats.Role)
+	require.Equal(t, "one", stats.Leader)
+	require.Equal(t, HALeader, haRole(one))
+
+	_, starts := connector.reconnectStats()
+	require.Equal(t, 0, starts)
+
+	status := two.readiness()
+	require.Equal(t, StatusReady, status.Status)
+	require.Equal(t, HAStandby, status.Role)
+	require.Equal(t, 0, status.Connectors)
+
+	_, err := two.PauseConnector("standby")
+	require.Equal(t, errBridgeStandby, err)
+
+	// stopping the leader releases the lease
+	one.Stop()
+
+	require.Eventually(t, func() bool {
+		return haRole(two) == HALeader
+	}, 2*time.Second, 20*time.Millisecond)
+
+	_, starts = connector.reconnectStats()
+	require.Equal(t, 1, starts)
+	require.Equal(t, int64(1), two.ha.Stats().Elections)
+
+	three := startHATestBridge(t, server.ClientURL(), "three")
+	defer three.Stop()
+	require.Equal(t, HAStandby, haRole(three))
+
+	// a leader that stops renewing loses the lease once it expires
+	two.runningLock.Lock()
+	two.ha.timer.Cancel()
+	two.ha.timer = nil
+	two.runningLock.Unlock()
+
+	require.Eventually(t, func() bool {
+		return haRole(three) == HALeader
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestHAStandbyDoesntRestartConnectors(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	bridge := startHATestBridge(t, server.ClientURL(), "one")
+	defer bridge.Stop()
+	require.Equal(t, HALeader, haRole(bridge))
+
+	// keep the bridge from campaigning again once it steps down
+	bridge.runningLock.Lock()
+	bridge.ha.timer.Cancel()
+	bridge.ha.timer = nil
+	bridge.config.ReconnectInterval = 20
+	bridge.config.ReconnectJitter = 0
+	bridge.runningLock.Unlock()
+
+	connector := &flakyConnector{stateConnector: stateConnector{id: "flaky"}, failures: 100}
+	bridge.connectorsLock.Lock()
+	bridge.connectors = []Connector{connector}
+	bridge.connectorsLock.Unlock()
+
+	// a restart that comes due after the role changed is dropped
+	bridge.ConnectorError(connector, fmt.Errorf("connection broken"))
+	require.True(t, bridge.checkReconnecting())
+	bridge.ha.setRole(HAStandby)
+
+	require.Eventually(t, func() bool {
+		return !bridge.checkReconnecting()
+	}, time.Second, 10*time.Millisecond)
+
+	_, starts := connector.reconnectStats()
+	require.Equal(t, 0, starts)
+
+	// stepping down cancels the restarts that are waiting
+	bridge.ha.setRole(HALeader)
+	bridge.ConnectorError(connector, fmt.Errorf("connection broken"))
+	require.True(t, bridge.checkReconnecting())
+
+	bridge.runningLock.Lock()
+	bridge.stepDown(fmt.Errorf("lost the lease"))
+	bridge.runningLock.Unlock()
+
+	require.Equal(t, HAStandby, haRole(bridge))
+	require.False(t, bridge.checkReconnecting())
+
+	time.Sleep(100 * time.Millisecond)
+	_, starts = connector.reconnectStats()
+	require.Equal(t, 0, starts)
+}
+
+func TestHAStoppedBridgeDoesntTakeTheLease(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	bridge := startHATestBridge(t, server.ClientURL(), "one")
+	require.Equal(t, HALeader, haRole(bridge))
+
+	ha := bridge.ha
+	bridge.Stop()
+
+	// a heartbeat that was already running when the bridge stopped
+	held, err := ha.renew()
+	require.NoError(t, err)
+	require.False(t, held)
+
+	won, err := ha.acquire()
+	require.NoError(t, err)
+	require.False(t, won)
+	require.False(t, ha.holdsLease())
+
+	_, err = ha.kv.Get(ha.key)
+	require.Error(t, err)
+}
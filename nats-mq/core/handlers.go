@@ -16,11 +16,17 @@
 package core
 
 import (
+	"errors"
+
 	nats "github.com/nats-io/nats.go"
 	stan "github.com/nats-io/stan.go"
 )
 
 func (bridge *BridgeServer) natsError(nc *nats.Conn, sub *nats.Subscription, err error) {
+	if sub != nil && errors.Is(err, nats.ErrSlowConsumer) {
+		bridge.slowConsumer(sub)
+		return
+	}
 	bridge.logger.Warnf("nats error %s", err.Error())
 }
 
@@ -34,28 +40,72 @@ func (bridge *BridgeServer) stanConnectionLost(sc stan.Conn, err error) {
 	bridge.stan = nil // we lost stan
 	bridge.natsLock.Unlock()
 
+	bridge.connectionAdvisory(StanDisconnectedAdvisory, "", err)
+
 	bridge.checkConnections()
 }
 
 func (bridge *BridgeServer) natsDisconnected(nc *nats.Conn) {
-	if !bridge.checkRunning() {
+	if !bridge.checkRunning() || nc != bridge.NATS() {
 		return
 	}
 	bridge.logger.Warnf("nats disconnected")
+	bridge.connectionAdvisory(NATSDisconnectedAdvisory, "", nil) // sent once the connection is back
 	bridge.checkConnections()
 }
 
 func (bridge *BridgeServer) natsReconnected(nc *nats.Conn) {
 	bridge.logger.Warnf("nats reconnected")
+
+	if bridge.checkRunning() && nc == bridge.NATS() {
+		bridge.connectionAdvisory(NATSReconnectedAdvisory, "", nil)
+	}
 }
 
+// natsClosed stops the bridge, unless the connection was replaced when the bridge restarted
 func (bridge *BridgeServer) natsClosed(nc *nats.Conn) {
-	if bridge.checkRunning() {
+	if bridge.checkRunning() && nc == bridge.NATS() {
 		bridge.logger.Errorf("nats connection closed, shutting down bridge")
 		go bridge.Stop()
 	}
 }
 
+// natsConnectionDisconnected returns the disconnect handler for a named connection
+func (bridge *BridgeServer) natsConnectionDisconnected(name string) nats.ConnHandler {
+	return func(nc *nats.Conn) {
+		if !bridge.checkRunning() || nc != bridge.NATSConnection(name) {
+			return
+		}
+		bridge.logger.Warnf("nats connection %s disconnected", name)
+		bridge.connectionAdvisory(NATSDisconnectedAdvisory, name, nil)
+		bridge.checkConnections()
+	}
+}
+
+// natsConnectionReconnected returns the reconnect handler for a named connection
+func (bridge *BridgeServer) natsConnectionReconnected(name string) nats.ConnHandler {
+	return func(nc *nats.Conn) {
+		bridge.logger.Warnf("nats connection %s reconnected", name)
+
+		if bridge.checkRunning() && nc == bridge.NATSConnection(name) {
+			bridge.connectionAdvisory(NATSReconnectedAdvisory, name, nil)
+		}
+	}
+}
+
+// natsConnectionClosed returns the closed handler for a named connection, unlike the shared
+// connection, the bridge keeps running and tries to reconnect
+func (bridge *BridgeServer) natsConnectionClosed(name string) nats.ConnHandler {
+	return func(nc *nats.Conn) {
+		if !bridge.checkRunning() || nc != bridge.NATSConnection(name) {
+			return
+		}
+		bridge.logger.Errorf("nats connection %s closed, will try to reconnect", name)
+		bridge.connectionAdvisory(NATSClosedAdvisory, name, nil)
+		bridge.checkConnections()
+	}
+}
+
 func (bridge *BridgeServer) natsDiscoveredServers(nc *nats.Conn) {
 	bridge.logger.Debugf("discovered servers: %v\n", nc.DiscoveredServers())
 	bridge.logger.Debugf("known servers: %v\n", nc.Servers())
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"net/http"
+)
+
+// The readiness endpoints, /healthz is the liveness check
+const (
+	ReadyzPath          = "/readyz"
+	ConnectorReadyzPath = ReadyzPath + "/{id}"
+)
+
+// The components checked for readiness
+const (
+	NATSComponent      = "nats"
+	StanComponent      = "stan"
+	ConnectorComponent = "connector"
+)
+
+// Readiness statuses
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// ReadinessStatus is returned by /readyz, it lists the components that are down
+type ReadinessStatus struct {
+	Status     string            `json:"status"`
+	Running    int               `json:"running"`        // connectors that are running
+	Connectors int               `json:"connectors"`     // connectors that should be running, paused connectors aren't included
+	Required   int               `json:"required"`       // running connectors required by the ready percent
+	Role       string            `json:"role,omitempty"` // leader or standby, if high availability is configured
+	Failing    []ComponentHealth `json:"failing"`
+}
+
+// ComponentHealth describes a connection or connector
+type ComponentHealth struct {
+	Component string `json:"component"`
+	Name      string `json:"name,omitempty"` // the named NATS connection, or the connector's description
+	ID        string `json:"id,omitempty"`   // connectors only
+	State     string `json:"state,omitempty"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+}
+
+// connectorHealth checks that the connector is running and that the connections it needs are up
+func connectorHealth(connector Connector) ComponentHealth {
+	stats := connector.Stats()
+	health := ComponentHealth{
+		Component: ConnectorComponent,
+		Name:      stats.Name,
+		ID:        stats.ID,
+		State:     stats.State,
+		Healthy:   stats.State == ConnectorRunning,
+	}
+
+	if health.Healthy {
+		if err := connector.CheckConnections(); err != nil {
+			health.Healthy = false
+			health.Error = err.Error()
+		}
+	} else if stats.Reconnect != nil {
+		health.Error = stats.Reconnect.LastError
+	} else if n := len(stats.RecentErrors); n > 0 && stats.State == ConnectorStopped {
+		health.Error = stats.RecentErrors[n-1].Error
+	}
+
+	return health
+}
+
+// readiness checks the NATS and streaming connections and the connectors, the bridge is ready if the
+// shared NATS connection, and streaming if it is configured, are up and enough connectors are running,
+// connectors aren't checked on a standby
+func (bridge *BridgeServer) readiness() ReadinessStatus {
+	status := ReadinessStatus{
+		Status:  StatusReady,
+		Failing: []ComponentHealth{},
+	}
+
+	if !bridge.CheckNATS() {
+		status.Status = StatusNotReady
+		status.Failing = append(status.Failing, ComponentHealth{Component: NATSComponent, Error: "disconnected"})
+	}
+
+	for _, nc := range bridge.config.NATSConnections {
+		if !bridge.CheckNATSConnection(nc.Name) {
+			// named connections only affect readiness through the connectors that use them
+			status.Failing = append(status.Failing, ComponentHealth{Component: NATSComponent, Name: nc.Name, Error: "disconnected"})
+		}
+	}
+
+	if bridge.config.STAN.ClusterID != "" && !bridge.CheckStan() {
+		status.Status = StatusNotReady
+		status.Failing = append(status.Failing, ComponentHealth{Component: StanComponent, Error: "disconnected"})
+	}
+
+	if ha := bridge.ha.Stats(); ha != nil {
+		status.Role = ha.Role
+
+		if ha.Role == HAStandby {
+			return status // the connectors only run on the leader, a standby is ready once it can take over
+		}
+	}
+
+	for _, c := range bridge.currentConnectors() {
+		health := connectorHealth(c)
+
+		if health.State == ConnectorPaused {
+			continue // paused on purpose
+		}
+
+		status.Connectors++
+
+		if health.Healthy {
+			status.Running++
+			continue
+		}
+
+		status.Failing = append(status.Failing, health)
+	}
+
+	percent := bridge.config.Monitoring.ReadyPercent
+	if percent == 0 {
+		percent = 100
+	}
+
+	// round up, so that 50% of 3 connectors requires 2
+	status.Required = (status.Connectors*percent + 99) / 100
+
+	if status.Running < status.Required {
+		status.Status = StatusNotReady
+	}
+
+	return status
+}
+
+// HandleReadyz returns the readiness of the bridge, with status 200 if it is ready and 503 if it isn't
+func (bridge *BridgeServer) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	bridge.statsLock.Lock()
+	bridge.httpReqStats[ReadyzPath]++
+	bridge.statsLock.Unlock()
+
+	status := bridge.readiness()
+
+	code := http.StatusOK
+	if status.Status != StatusReady {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSONResponse(w, code, status)
+}
+
+// HandleConnectorReadyz returns the health of a single connector, with status 200 if it is running,
+// 503 if it isn't and 404 if there is no connector with the id
+func (bridge *BridgeServer) HandleConnectorReadyz(w http.ResponseWriter, r *http.Request) {
+	bridge.statsLock.Lock()
+	bridge.httpReqStats[ReadyzPath]++
+	bridge.statsLock.Unlock()
+
+	connector := bridge.findConnector(r.PathValue("id"))
+	if connector == nil {
+		writeAdminError(w, errConnectorNotFound)
+		return
+	}
+
+	health := connectorHealth(connector)
+
+	code := http.StatusOK
+	if !health.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSONResponse(w, code, health)
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nst "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+)
+
+// stateConnector is a connector that only reports a state, for testing readiness
+type stateConnector struct {
+	id    string
+	state string
+}
+
+func (c *stateConnector) Start() error                       { return nil }
+func (c *stateConnector) Shutdown() error                    { return nil }
+func (c *stateConnector) Pause() error                       { return nil }
+func (c *stateConnector) Resume() error                      { return nil }
+func (c *stateConnector) Paused() bool                       { return c.state == ConnectorPaused }
+func (c *stateConnector) CheckConnections() error            { return nil }
+func (c *stateConnector) SetReconnect(stats *ReconnectStats) {}
+func (c *stateConnector) String() string                     { return "test connector " + c.id }
+func (c *stateConnector) ID() string                         { return c.id }
+
+func (c *stateConnector) Stats() ConnectorStats {
+	return ConnectorStats{ID: c.id, Name: c.String(), State: c.state}
+}
+
+func getHealth(t *testing.T, url string, response interface{}) int {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, response))
+	return resp.StatusCode
+}
+
+func TestReadinessPolicy(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	config := conf.DefaultBridgeConfig()
+	config.NATS.Servers = []string{server.ClientURL()}
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	bridge.connectorsLock.Lock()
+	bridge.connectors = []Connector{
+		&stateConnector{id: "one", state: ConnectorRunning},
+		&stateConnector{id: "two", state: ConnectorRunning},
+		&stateConnector{id: "three", state: ConnectorStopped},
+		&stateConnector{id: "four", state: ConnectorPaused},
+	}
+	bridge.connectorsLock.Unlock()
+
+	status := bridge.readiness()
+	require.Equal(t, StatusNotReady, status.Status)
+	require.Equal(t, 2, status.Running)
+	require.Equal(t, 3, status.Connectors)
+	require.Equal(t, 3, status.Required)
+	require.Len(t, status.Failing, 1)
+	require.Equal(t, "three", status.Failing[0].ID)
+	require.Equal(t, ConnectorStopped, status.Failing[0].State)
+
+	bridge.config.Monitoring.ReadyPercent = 50
+	status = bridge.readiness()
+	require.Equal(t, StatusReady, status.Status)
+	require.Equal(t, 2, status.Required)
+	require.Len(t, status.Failing, 1)
+
+	bridge.config.Monitoring.ReadyPercent = 70
+	require.Equal(t, StatusNotReady, bridge.readiness().Status)
+}
+
+func TestReadinessEndpoints(t *testing.T) {
+	opts := nst.DefaultTestOptions
+	opts.Port = -1
+	server := nst.RunServer(&opts)
+	defer server.Shutdown()
+
+	config := conf.DefaultBridgeConfig()
+	config.NATS.Servers = []string{server.ClientURL()}
+	config.NATS.MaxReconnects = -1
+	config.NATS.ReconnectWait = 100
+	config.Monitoring.HTTPPort = -1
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	root := bridge.GetMonitoringRootURL()
+
+	status := ReadinessStatus{}
+	require.Equal(t, http.StatusOK, getHealth(t, root+"readyz", &status))
+	require.Equal(t, StatusReady, status.Status)
+	require.Empty(t, status.Failing)
+
+	failure := AdminError{}
+	require.Equal(t, http.StatusNotFound, getHealth(t, root+"readyz/missing", &failure))
+
+	bridge.connectorsLock.Lock()
+	bridge.connectors = []Connector{
+		&stateConnector{id: "one", state: ConnectorRunning},
+		&stateConnector{id: "two", state: ConnectorStopped},
+	}
+	bridge.connectorsLock.Unlock()
+
+	health := ComponentHealth{}
+	require.Equal(t, http.StatusOK, getHealth(t, root+"readyz/one", &health))
+	require.True(t, health.Healthy)
+	require.Equal(t, ConnectorRunning, health.State)
+
+	health = ComponentHealth{}
+	require.Equal(t, http.StatusServiceUnavailable, getHealth(t, root+"readyz/two", &health))
+	require.False(t, health.Healthy)
+	require.Equal(t, ConnectorStopped, health.State)
+
+	bridge.connectorsLock.Lock()
+	bridge.connectors = []Connector{}
+	bridge.connectorsLock.Unlock()
+
+	// the bridge isn't ready while NATS is down, but it is still alive
+	server.Shutdown()
+
+	require.Eventually(t, func() bool {
+		status = ReadinessStatus{}
+		return getHealth(t, root+"readyz", &status) == http.StatusServiceUnavailable
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, StatusNotReady, status.Status)
+	require.Len(t, status.Failing, 1)
+	require.Equal(t, NATSComponent, status.Failing[0].Component)
+
+	resp, err := http.Get(root + "healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stats := bridge.SafeStats()
+	require.GreaterOrEqual(t, stats.HTTPRequests[ReadyzPath], int64(5))
+}
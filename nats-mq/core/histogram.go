@@ -78,6 +78,33 @@ func (h *Histogram) Add(n float64) {
 	h.Bins = append(h.Bins, Bin{Count: 1, Value: n})
 }
 
+// Merge adds the bins from other to the histogram, other is not changed
+func (h *Histogram) Merge(other *Histogram) {
+	defer h.trim()
+
+	for _, bin := range other.Bins {
+		h.Total += uint64(bin.Count)
+		h.insert(bin)
+	}
+}
+
+// insert adds a bin, keeping the bins sorted by value
+func (h *Histogram) insert(bin Bin) {
+	for i := range h.Bins {
+		if h.Bins[i].Value == bin.Value {
+			h.Bins[i].Count += bin.Count
+			return
+		}
+
+		if h.Bins[i].Value > bin.Value {
+			h.Bins = append(h.Bins[:i], append([]Bin{bin}, h.Bins[i:]...)...)
+			return
+		}
+	}
+
+	h.Bins = append(h.Bins, bin)
+}
+
 // Quantile returns the value for the bin at the provided quantile
 // This is "approximate" in the since that the bin may straddle the quantile value
 func (h *Histogram) Quantile(q float64) float64 {
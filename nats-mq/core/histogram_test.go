@@ -58,3 +58,27 @@ func TestHistogramTrim(t *testing.T) {
 
 	require.Equal(t, 10, len(h.Bins))
 }
+
+func TestHistogramMerge(t *testing.T) {
+	h := NewHistogram(160)
+	first := NewHistogram(160)
+	second := NewHistogram(160)
+	for i, val := range testData {
+		h.Add(float64(val))
+
+		if i%2 == 0 {
+			first.Add(float64(val))
+		} else {
+			second.Add(float64(val))
+		}
+	}
+
+	merged := NewHistogram(160)
+	merged.Merge(first)
+	merged.Merge(second)
+
+	require.Equal(t, h.Count(), merged.Count())
+	require.True(t, approx(merged.Quantile(0.5), h.Quantile(0.5)))
+	require.True(t, approx(merged.Mean(), h.Mean()))
+	require.True(t, len(merged.Bins) <= 160)
+}
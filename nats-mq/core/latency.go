@@ -0,0 +1,59 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// TimestampHeader is the NATS header used to carry the time a message was first put or published,
+// in RFC3339 format. Connectors that put to MQ measure latency from it.
+const TimestampHeader = "MQ-Timestamp"
+
+// natsTimestamp returns the time in a NATS message's timestamp header, the zero time if it doesn't have one
+func natsTimestamp(m *nats.Msg) time.Time {
+	if m.Header == nil {
+		return time.Time{}
+	}
+
+	value := m.Header.Get(TimestampHeader)
+	if value == "" {
+		return time.Time{}
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return timestamp
+}
+
+// recordLatency adds the time since origin to the connector's latency, messages without an origin
+// are skipped. Clocks can disagree, so negative latencies are counted as 0. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) recordLatency(origin time.Time) {
+	if origin.IsZero() {
+		return
+	}
+
+	latency := time.Since(origin)
+	if latency < 0 {
+		latency = 0
+	}
+
+	mq.stats.AddLatency(latency)
+}
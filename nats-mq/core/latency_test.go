@@ -0,0 +1,55 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSTimestamp(t *testing.T) {
+	now := time.Now().UTC()
+
+	msg := &nats.Msg{Header: nats.Header{}}
+	msg.Header.Set(TimestampHeader, now.Format(time.RFC3339Nano))
+	require.True(t, now.Equal(natsTimestamp(msg)))
+
+	require.True(t, natsTimestamp(&nats.Msg{}).IsZero())
+
+	msg.Header.Set(TimestampHeader, "yesterday")
+	require.True(t, natsTimestamp(msg).IsZero())
+}
+
+func TestRecordLatency(t *testing.T) {
+	connector := &BridgeConnector{}
+	connector.init(NewBridgeServer(), conf.ConnectorConfig{}, "test")
+
+	connector.recordLatency(time.Time{})
+	require.Equal(t, int64(0), connector.stats.LatencyCount)
+
+	connector.recordLatency(time.Now().Add(-time.Second))
+	require.Equal(t, int64(1), connector.stats.LatencyCount)
+	require.True(t, connector.stats.LatencyAverage >= float64(time.Second.Nanoseconds()))
+
+	// clocks that disagree don't make the latency negative
+	connector.recordLatency(time.Now().Add(time.Hour))
+	require.Equal(t, int64(2), connector.stats.LatencyCount)
+	require.True(t, connector.stats.LatencyAverage > 0)
+}
@@ -0,0 +1,383 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPrefix is added to the name of every metric
+const metricsPrefix = "nats_mq_"
+
+// metricsContentType is the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// connectorMetric exports a single value from the connector statistics
+type connectorMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func(s ConnectorStats) float64
+}
+
+var connectorMetrics = []connectorMetric{
+	{"connector_connected", "1 if the connector is connected", "gauge", func(s ConnectorStats) float64 { return boolMetric(s.Connected) }},
+	{"connector_connects_total", "Number of times the connector connected", "counter", func(s ConnectorStats) float64 { return float64(s.Connects) }},
+	{"connector_disconnects_total", "Number of times the connector disconnected", "counter", func(s ConnectorStats) float64 { return float64(s.Disconnects) }},
+	{"connector_bytes_in_total", "Bytes received by the connector", "counter", func(s ConnectorStats) float64 { return float64(s.BytesIn) }},
+	{"connector_bytes_out_total", "Bytes sent by the connector", "counter", func(s ConnectorStats) float64 { return float64(s.BytesOut) }},
+	{"connector_msgs_in_total", "Messages received by the connector", "counter", func(s ConnectorStats) float64 { return float64(s.MessagesIn) }},
+	{"connector_msgs_out_total", "Messages sent by the connector", "counter", func(s ConnectorStats) float64 { return float64(s.MessagesOut) }},
+	{"connector_expired_total", "Messages discarded because they expired", "counter", func(s ConnectorStats) float64 { return float64(s.Expired) }},
+	{"connector_slow_consumers_total", "Slow consumer errors on the connector's NATS subscription", "counter", func(s ConnectorStats) float64 { return float64(s.SlowConsumers) }},
+	{"connector_flow_control_pauses_total", "Times the connector stopped reading from MQ for flow control", "counter", func(s ConnectorStats) float64 { return float64(s.Paused) }},
+	{"connector_duplicates_total", "Messages dropped because they were already put", "counter", func(s ConnectorStats) float64 { return float64(s.Duplicates) }},
+	{"connector_conversion_failures_total", "Messages that couldn't be converted", "counter", func(s ConnectorStats) float64 { return float64(s.ConversionFailures) }},
+	{"connector_put_failures_total", "Failed puts to MQ", "counter", func(s ConnectorStats) float64 { return float64(s.PutFailures) }},
+	{"connector_mq_reconnects_total", "Connections the MQ client reconnected on its own", "counter", func(s ConnectorStats) float64 { return float64(s.MQReconnects) }},
+	{"connector_put_retries_total", "Puts to MQ retried after a transient failure", "counter", func(s ConnectorStats) float64 { return float64(s.PutRetries) }},
+	{"connector_publish_failures_total", "Failed publishes to NATS or streaming", "counter", func(s ConnectorStats) float64 { return float64(s.PublishFailures) }},
+	{"connector_commit_failures_total", "Failed MQ commits", "counter", func(s ConnectorStats) float64 { return float64(s.CommitFailures) }},
+	{"connector_backouts_total", "Messages backed out to MQ", "counter", func(s ConnectorStats) float64 { return float64(s.Backouts) }},
+	{"connector_reconnect_attempts", "Failed attempts to restart the connector since it stopped", "gauge", func(s ConnectorStats) float64 { return float64(reconnectStats(s).Attempts) }},
+	{"connector_next_reconnect_timestamp_seconds", "Time of the next attempt to restart the connector, 0 if there isn't one", "gauge", func(s ConnectorStats) float64 { return timestampMetric(reconnectStats(s).NextAttempt) }},
+	{"connector_failed", "1 if the bridge gave up restarting the connector", "gauge", func(s ConnectorStats) float64 { return boolMetric(reconnectStats(s).Failed) }},
+}
+
+// rateLimitMetrics are only exported for connectors with a rate limit
+var rateLimitMetrics = []struct {
+	name  string
+	help  string
+	kind  string
+	value func(s *RateLimitStats) float64
+}{
+	{"throttled", "1 if messages are waiting for the rate limit", "gauge", func(s *RateLimitStats) float64 { return boolMetric(s.Throttled) }},
+	{"throttled_msgs_total", "Messages that waited for the rate limit", "counter", func(s *RateLimitStats) float64 { return float64(s.ThrottledMessages) }},
+	{"throttle_wait_seconds_total", "Time messages waited for the rate limit", "counter", func(s *RateLimitStats) float64 { return float64(s.Wait) / 1e9 }},
+}
+
+// queueMetrics are only exported for connectors with a queue monitor
+var queueMetrics = []struct {
+	name  string
+	help  string
+	kind  string
+	value func(s *QueueStats) float64
+}{
+	{"queue_depth", "Messages on the connector's MQ queue", "gauge", func(s *QueueStats) float64 { return float64(s.Depth) }},
+	{"queue_open_input_count", "Handles open for input on the connector's MQ queue", "gauge", func(s *QueueStats) float64 { return float64(s.OpenInputCount) }},
+	{"queue_oldest_msg_age_seconds", "Age of the oldest message on the connector's MQ queue", "gauge", func(s *QueueStats) float64 { return float64(s.OldestMessageAge) / 1e3 }},
+	{"queue_pending_msgs", "Messages pending on the connector's NATS subscription", "gauge", func(s *QueueStats) float64 { return float64(s.PendingMsgs) }},
+	{"queue_pending_bytes", "Bytes pending on the connector's NATS subscription", "gauge", func(s *QueueStats) float64 { return float64(s.PendingBytes) }},
+	{"queue_alerts_total", "Queue alerts published by the connector", "counter", func(s *QueueStats) float64 { return float64(s.Alerts) }},
+}
+
+// targetMetrics are only exported for connectors with targets
+var targetMetrics = []struct {
+	name  string
+	help  string
+	kind  string
+	value func(s TargetStats) float64
+}{
+	{"target_healthy", "1 if the queue manager target is in the rotation", "gauge", func(s TargetStats) float64 { return boolMetric(s.Healthy) }},
+	{"target_msgs_out_total", "Messages put to the queue manager target", "counter", func(s TargetStats) float64 { return float64(s.MessagesOut) }},
+	{"target_bytes_out_total", "Bytes put to the queue manager target", "counter", func(s TargetStats) float64 { return float64(s.BytesOut) }},
+	{"target_put_failures_total", "Failed puts to the queue manager target", "counter", func(s TargetStats) float64 { return float64(s.PutFailures) }},
+}
+
+// HandleMetrics returns the statistics in the Prometheus text format
+func (bridge *BridgeServer) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	bridge.statsLock.Lock()
+	bridge.httpReqStats[MetricsPath]++
+	bridge.statsLock.Unlock()
+
+	stats := bridge.stats()
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.WriteHeader(http.StatusOK)
+	writeMetrics(w, stats)
+}
+
+// metricsWriter writes metric families, each family's samples have to be written together
+type metricsWriter struct {
+	*bufio.Writer
+}
+
+func (mw metricsWriter) family(name string, help string, kind string) {
+	fmt.Fprintf(mw, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(mw, "# TYPE %s%s %s\n", metricsPrefix, name, kind)
+}
+
+// sample writes one value, labels are name value pairs
+func (mw metricsWriter) sample(name string, value float64, labels ...string) {
+	mw.WriteString(metricsPrefix)
+	mw.WriteString(name)
+
+	if len(labels) > 0 {
+		mw.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				mw.WriteByte(',')
+			}
+			fmt.Fprintf(mw, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		mw.WriteByte('}')
+	}
+
+	mw.WriteByte(' ')
+	mw.WriteString(formatMetric(value))
+	mw.WriteByte('\n')
+}
+
+// writeMetrics writes the bridge, connection and connector statistics
+func writeMetrics(w io.Writer, stats BridgeStats) {
+	mw := metricsWriter{bufio.NewWriter(w)}
+	defer mw.Flush()
+
+	mw.family("start_time_seconds", "Start time of the bridge since the unix epoch", "gauge")
+	mw.sample("start_time_seconds", float64(stats.StartTime))
+
+	if stats.Startup != nil {
+		mw.family("startup_failed_connectors", "Connectors that couldn't start when the bridge started", "gauge")
+		mw.sample("startup_failed_connectors", float64(len(stats.Startup.Failed)))
+	}
+
+	if stats.HA != nil {
+		mw.family("ha_leader", "1 if the bridge holds the high availability lease", "gauge")
+		mw.sample("ha_leader", boolMetric(stats.HA.Role == HALeader), "name", stats.HA.Name)
+		mw.family("ha_elections_total", "Times the bridge became the high availability leader", "counter")
+		mw.sample("ha_elections_total", float64(stats.HA.Elections), "name", stats.HA.Name)
+	}
+
+	mw.family("http_requests_total", "Monitoring requests by path", "counter")
+	paths := []string{}
+	for path := range stats.HTTPRequests {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		mw.sample("http_requests_total", float64(stats.HTTPRequests[path]), "path", path)
+	}
+
+	writeNATSMetrics(mw, stats)
+
+	if stats.Stan != nil {
+		mw.family("stan_connected", "1 if the streaming connection is connected", "gauge")
+		mw.sample("stan_connected", boolMetric(stats.Stan.Connected), "cluster_id", stats.Stan.ClusterID, "client_id", stats.Stan.ClientID)
+	}
+
+	writePoolMetrics(mw, stats.MQPool)
+	writeConnectorMetrics(mw, stats.Connections)
+
+	if stats.RateLimit != nil {
+		for _, m := range rateLimitMetrics {
+			mw.family("bridge_"+m.name, m.help, m.kind)
+			mw.sample("bridge_"+m.name, m.value(stats.RateLimit))
+		}
+	}
+}
+
+// writeNATSMetrics writes the shared and named NATS connections, the shared connection has an empty name
+func writeNATSMetrics(mw metricsWriter, stats BridgeStats) {
+	connections := append([]NATSConnectionStats{stats.NATS}, stats.NATSConnections...)
+
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value func(s NATSConnectionStats) float64
+	}{
+		{"nats_connected", "1 if the NATS connection is connected", "gauge", func(s NATSConnectionStats) float64 { return boolMetric(s.Connected) }},
+		{"nats_reconnects_total", "Number of times the NATS connection reconnected", "counter", func(s NATSConnectionStats) float64 { return float64(s.Reconnects) }},
+		{"nats_msgs_in_total", "Messages received on the NATS connection", "counter", func(s NATSConnectionStats) float64 { return float64(s.MessagesIn) }},
+		{"nats_msgs_out_total", "Messages sent on the NATS connection", "counter", func(s NATSConnectionStats) float64 { return float64(s.MessagesOut) }},
+		{"nats_bytes_in_total", "Bytes received on the NATS connection", "counter", func(s NATSConnectionStats) float64 { return float64(s.BytesIn) }},
+		{"nats_bytes_out_total", "Bytes sent on the NATS connection", "counter", func(s NATSConnectionStats) float64 { return float64(s.BytesOut) }},
+	}
+
+	for _, m := range metrics {
+		mw.family(m.name, m.help, m.kind)
+		for _, nc := range connections {
+			mw.sample(m.name, m.value(nc), "connection", nc.Name, "account", nc.Account)
+		}
+	}
+}
+
+// writePoolMetrics writes the queue manager connection pool statistics
+func writePoolMetrics(mw metricsWriter, pool []QueueManagerPoolStats) {
+	if len(pool) == 0 {
+		return
+	}
+
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value func(s QueueManagerPoolStats) float64
+	}{
+		{"mq_pool_connections", "Open queue manager connections", "gauge", func(s QueueManagerPoolStats) float64 { return float64(s.Connections) }},
+		{"mq_pool_shared_connections", "Open connections shared by put connectors", "gauge", func(s QueueManagerPoolStats) float64 { return float64(s.Shared) }},
+		{"mq_pool_connectors", "Connectors using the pool's connections", "gauge", func(s QueueManagerPoolStats) float64 { return float64(s.Connectors) }},
+		{"mq_pool_connects_total", "Connections the pool has opened", "counter", func(s QueueManagerPoolStats) float64 { return float64(s.Connects) }},
+		{"mq_pool_rejected_total", "Connections refused because the pool was full", "counter", func(s QueueManagerPoolStats) float64 { return float64(s.Rejected) }},
+		{"mq_pool_health_check_failures_total", "Shared connections that failed a health check", "counter", func(s QueueManagerPoolStats) float64 { return float64(s.HealthCheckFailures) }},
+	}
+
+	for _, m := range metrics {
+		mw.family(m.name, m.help, m.kind)
+		for _, s := range pool {
+			mw.sample(m.name, m.value(s), "queue_manager", s.QueueManager, "connection_name", s.ConnectionName, "channel", s.ChannelName)
+		}
+	}
+}
+
+// writeConnectorMetrics writes the connector counters, and the request times and latency as summaries
+func writeConnectorMetrics(mw metricsWriter, connectors []ConnectorStats) {
+	if len(connectors) == 0 {
+		return
+	}
+
+	labels := func(s ConnectorStats, extra ...string) []string {
+		return append([]string{"id", s.ID, "name", s.Name, "type", s.Type}, extra...)
+	}
+
+	for _, m := range connectorMetrics {
+		mw.family(m.name, m.help, m.kind)
+		for _, s := range connectors {
+			mw.sample(m.name, m.value(s), labels(s)...)
+		}
+	}
+
+	writeSummary(mw, "connector_request_seconds", "Time taken to move a message across the bridge", connectors, labels,
+		func(s ConnectorStats) ([]float64, float64, int64) {
+			return []float64{s.Quintile50, s.Quintile75, s.Quintile90, s.Quintile95}, s.MovingAverage, s.RequestCount
+		})
+
+	writeSummary(mw, "connector_latency_seconds", "Time from a message being put or published to the bridge delivering it", connectors, labels,
+		func(s ConnectorStats) ([]float64, float64, int64) {
+			return []float64{s.Latency50, s.Latency75, s.Latency90, s.Latency95}, s.LatencyAverage, s.LatencyCount
+		})
+
+	for _, m := range rateLimitMetrics {
+		first := true
+		for _, s := range connectors {
+			if s.RateLimit == nil {
+				continue
+			}
+			if first {
+				mw.family("connector_"+m.name, m.help, m.kind)
+				first = false
+			}
+			mw.sample("connector_"+m.name, m.value(s.RateLimit), labels(s)...)
+		}
+	}
+
+	for _, m := range queueMetrics {
+		first := true
+		for _, s := range connectors {
+			if s.Queue == nil {
+				continue
+			}
+			if first {
+				mw.family("connector_"+m.name, m.help, m.kind)
+				first = false
+			}
+			mw.sample("connector_"+m.name, m.value(s.Queue), labels(s, "queue", s.Queue.Queue)...)
+		}
+	}
+
+	for _, m := range targetMetrics {
+		first := true
+		for _, s := range connectors {
+			for _, target := range s.Targets {
+				if first {
+					mw.family("connector_"+m.name, m.help, m.kind)
+					first = false
+				}
+				mw.sample("connector_"+m.name, m.value(target), labels(s, "target", strconv.Itoa(target.Target), "queue_manager", target.QueueManager)...)
+			}
+		}
+	}
+}
+
+// summaryQuantiles are the quantiles kept by the connector statistics
+var summaryQuantiles = []string{"0.5", "0.75", "0.9", "0.95"}
+
+// writeSummary writes a summary from the quantiles, average and count of a connector statistic in nanoseconds
+func writeSummary(mw metricsWriter, name string, help string, connectors []ConnectorStats, labels func(s ConnectorStats, extra ...string) []string,
+	values func(s ConnectorStats) ([]float64, float64, int64)) {
+	mw.family(name, help, "summary")
+	for _, s := range connectors {
+		quantiles, average, count := values(s)
+
+		for i, q := range summaryQuantiles {
+			value := math.NaN()
+			if count > 0 {
+				value = quantiles[i] / 1e9
+			}
+			mw.sample(name, value, labels(s, "quantile", q)...)
+		}
+
+		mw.sample(name+"_sum", average*float64(count)/1e9, labels(s)...)
+		mw.sample(name+"_count", float64(count), labels(s)...)
+	}
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func timestampMetric(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+// reconnectStats returns the connector's reconnect state, or an empty one if it isn't waiting to be restarted
+func reconnectStats(s ConnectorStats) ReconnectStats {
+	if s.Reconnect == nil {
+		return ReconnectStats{}
+	}
+	return *s.Reconnect
+}
+
+func formatMetric(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value, backslashes, quotes and new lines have to be escaped
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	connector := NewConnectorStats()
+	connector.Name = `Queue:"A" to NATS:b`
+	connector.ID = "one"
+	connector.Type = "Queue2NATS"
+	connector.Connected = true
+	connector.AddMessageIn(10)
+	connector.AddMessageOut(12)
+	connector.AddRequestTime(2 * time.Millisecond)
+	connector.AddLatency(3 * time.Second)
+	connector.UpdateQuintiles()
+	connector.Queue = &QueueStats{Queue: "A", Depth: 7, OldestMessageAge: 2500}
+
+	idle := NewConnectorStats()
+	idle.ID = "two"
+	idle.Type = "NATS2Queue"
+	idle.RateLimit = &RateLimitStats{MessagesPerSecond: 10, ThrottledMessages: 3, Wait: 1500000000}
+	idle.Reconnect = &ReconnectStats{Attempts: 3, NextAttempt: time.Unix(2000, 500000000)}
+	idle.Targets = []TargetStats{{Target: 0, QueueManager: "QM1", Healthy: true}, {Target: 1, QueueManager: "QM2"}}
+
+	stats := BridgeStats{
+		StartTime:    1000,
+		Connections:  []ConnectorStats{connector, idle},
+		NATS:         NATSConnectionStats{Connected: true, Reconnects: 2},
+		HTTPRequests: map[string]int64{MetricsPath: 1, VarzPath: 0},
+		Startup:      &StartupStats{Connectors: 3, Started: 2, Failed: []StartupFailure{{ID: "three"}}},
+		HA:           &HAStats{Role: HALeader, Name: "one", Elections: 2},
+	}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, stats)
+	metrics := buf.String()
+
+	lines := func(prefix string) []string {
+		found := []string{}
+		for _, line := range strings.Split(metrics, "\n") {
+			if strings.HasPrefix(line, prefix) {
+				found = append(found, line)
+			}
+		}
+		return found
+	}
+
+	require.Equal(t, []string{`nats_mq_http_requests_total{path="/metrics"} 1`, `nats_mq_http_requests_total{path="/varz"} 0`}, lines("nats_mq_http_requests_total"))
+	require.Equal(t, []string{`nats_mq_nats_connected{connection="",account=""} 1`}, lines("nats_mq_nats_connected"))
+	require.Equal(t, []string{`nats_mq_nats_reconnects_total{connection="",account=""} 2`}, lines("nats_mq_nats_reconnects_total"))
+	require.Equal(t, []string{
+		`nats_mq_connector_msgs_in_total{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS"} 1`,
+		`nats_mq_connector_msgs_in_total{id="two",name="",type="NATS2Queue"} 0`,
+	}, lines("nats_mq_connector_msgs_in_total"))
+	require.Equal(t, []string{
+		`nats_mq_connector_request_seconds{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS",quantile="0.5"} 0.002`,
+		`nats_mq_connector_request_seconds{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS",quantile="0.75"} 0.002`,
+		`nats_mq_connector_request_seconds{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS",quantile="0.9"} 0.002`,
+		`nats_mq_connector_request_seconds{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS",quantile="0.95"} 0.002`,
+		`nats_mq_connector_request_seconds_sum{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS"} 0.002`,
+		`nats_mq_connector_request_seconds_count{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS"} 1`,
+		`nats_mq_connector_request_seconds{id="two",name="",type="NATS2Queue",quantile="0.5"} NaN`,
+		`nats_mq_connector_request_seconds{id="two",name="",type="NATS2Queue",quantile="0.75"} NaN`,
+		`nats_mq_connector_request_seconds{id="two",name="",type="NATS2Queue",quantile="0.9"} NaN`,
+		`nats_mq_connector_request_seconds{id="two",name="",type="NATS2Queue",quantile="0.95"} NaN`,
+		`nats_mq_connector_request_seconds_sum{id="two",name="",type="NATS2Queue"} 0`,
+		`nats_mq_connector_request_seconds_count{id="two",name="",type="NATS2Queue"} 0`,
+	}, lines("nats_mq_connector_request_seconds"))
+	require.Equal(t, []string{
+		`nats_mq_connector_latency_seconds_count{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS"} 1`,
+		`nats_mq_connector_latency_seconds_count{id="two",name="",type="NATS2Queue"} 0`,
+	}, lines("nats_mq_connector_latency_seconds_count"))
+	require.Equal(t, []string{`nats_mq_connector_throttle_wait_seconds_total{id="two",name="",type="NATS2Queue"} 1.5`}, lines("nats_mq_connector_throttle_wait_seconds_total"))
+	require.Len(t, lines("# TYPE nats_mq_connector_throttled_msgs_total counter"), 1)
+	require.Equal(t, []string{`nats_mq_connector_queue_depth{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS",queue="A"} 7`}, lines("nats_mq_connector_queue_depth"))
+	require.Equal(t, []string{`nats_mq_connector_queue_oldest_msg_age_seconds{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS",queue="A"} 2.5`}, lines("nats_mq_connector_queue_oldest_msg_age_seconds"))
+	require.Equal(t, []string{
+		`nats_mq_connector_reconnect_attempts{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS"} 0`,
+		`nats_mq_connector_reconnect_attempts{id="two",name="",type="NATS2Queue"} 3`,
+	}, lines("nats_mq_connector_reconnect_attempts"))
+	require.Equal(t, []string{
+		`nats_mq_connector_next_reconnect_timestamp_seconds{id="one",name="Queue:\"A\" to NATS:b",type="Queue2NATS"} 0`,
+		`nats_mq_connector_next_reconnect_timestamp_seconds{id="two",name="",type="NATS2Queue"} 2000.5`,
+	}, lines("nats_mq_connector_next_reconnect_timestamp_seconds"))
+	require.Equal(t, []string{
+		`nats_mq_connector_target_healthy{id="two",name="",type="NATS2Queue",target="0",queue_manager="QM1"} 1`,
+		`nats_mq_connector_target_healthy{id="two",name="",type="NATS2Queue",target="1",queue_manager="QM2"} 0`,
+	}, lines("nats_mq_connector_target_healthy"))
+	require.Equal(t, []string{`nats_mq_startup_failed_connectors 1`}, lines("nats_mq_startup_failed_connectors"))
+	require.Equal(t, []string{`nats_mq_ha_leader{name="one"} 1`}, lines("nats_mq_ha_leader"))
+	require.Equal(t, []string{`nats_mq_ha_elections_total{name="one"} 2`}, lines("nats_mq_ha_elections_total"))
+	require.Empty(t, lines("nats_mq_stan_connected"))
+	require.Empty(t, lines("nats_mq_bridge_throttled"))
+}
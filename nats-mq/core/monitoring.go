@@ -24,6 +24,8 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	nats "github.com/nats-io/nats.go"
 )
 
 // HTTP endpoints
@@ -31,6 +33,7 @@ const (
 	RootPath    = "/"
 	VarzPath    = "/varz"
 	HealthzPath = "/healthz"
+	MetricsPath = "/metrics"
 )
 
 // startMonitoring starts the HTTP or HTTPs server if needed.
@@ -61,6 +64,12 @@ func (bridge *BridgeServer) startMonitoring() error {
 		RootPath:    0,
 		VarzPath:    0,
 		HealthzPath: 0,
+		ReadyzPath:  0,
+		MetricsPath: 0,
+	}
+
+	if config.AdminToken != "" {
+		bridge.httpReqStats[AdminPath] = 0
 	}
 
 	var (
@@ -116,6 +125,10 @@ func (bridge *BridgeServer) startMonitoring() error {
 	mux.HandleFunc(RootPath, bridge.HandleRoot)
 	mux.HandleFunc(VarzPath, bridge.HandleVarz)
 	mux.HandleFunc(HealthzPath, bridge.HandleHealthz)
+	mux.HandleFunc("GET "+ReadyzPath, bridge.HandleReadyz)
+	mux.HandleFunc("GET "+ConnectorReadyzPath, bridge.HandleConnectorReadyz)
+	mux.HandleFunc(MetricsPath, bridge.HandleMetrics)
+	bridge.registerAdminHandlers(mux)
 
 	// Do not set a WriteTimeout because it could cause cURL/browser
 	// to return empty response or unable to display page if the
@@ -185,6 +198,8 @@ func (bridge *BridgeServer) HandleRoot(w http.ResponseWriter, r *http.Request) {
     <br/>
 		<a href=/varz>varz</a><br/>
 		<a href=/healthz>healthz</a><br/>
+		<a href=/readyz>readyz</a><br/>
+		<a href=/metrics>metrics</a><br/>
     <br/>
   </body>
 </html>`)
@@ -210,7 +225,7 @@ func (bridge *BridgeServer) HandleVarz(w http.ResponseWriter, r *http.Request) {
 	w.Write(varzJSON)
 }
 
-// HandleHealthz returns status 200.
+// HandleHealthz returns status 200, it is the liveness check, /readyz checks the connections and connectors.
 func (bridge *BridgeServer) HandleHealthz(w http.ResponseWriter, r *http.Request) {
 	bridge.statsLock.Lock()
 	bridge.httpReqStats[HealthzPath]++
@@ -229,12 +244,38 @@ func (bridge *BridgeServer) stats() BridgeStats {
 	stats.UpTime = now.Sub(bridge.startTime).String()
 	stats.ServerTime = now.Unix()
 
-	for _, connector := range bridge.connectors {
+	for _, connector := range bridge.currentConnectors() {
 		cstats := connector.Stats()
 		cstats.UpdateQuintiles()
 		stats.Connections = append(stats.Connections, cstats)
 	}
 
+	stats.NATS = natsConnectionStats(bridge.NATS())
+	stats.NATS.Account = bridge.config.NATS.Account
+
+	if bridge.config.STAN.ClusterID != "" {
+		stats.Stan = &StreamingStats{
+			ClusterID: bridge.config.STAN.ClusterID,
+			ClientID:  bridge.config.STAN.ClientID,
+			Connected: bridge.CheckStan(),
+		}
+	}
+
+	for _, config := range bridge.config.NATSConnections {
+		nstats := natsConnectionStats(bridge.NATSConnection(config.Name))
+		nstats.Name = config.Name
+		nstats.Account = config.Account
+		stats.NATSConnections = append(stats.NATSConnections, nstats)
+	}
+
+	if bridge.mqPool != nil {
+		stats.MQPool = bridge.mqPool.Stats()
+	}
+
+	stats.RateLimit = bridge.limiter.Stats()
+	stats.Startup = bridge.startup
+	stats.HA = bridge.ha.Stats()
+
 	stats.HTTPRequests = map[string]int64{}
 
 	bridge.statsLock.Lock()
@@ -246,6 +287,24 @@ func (bridge *BridgeServer) stats() BridgeStats {
 	return stats
 }
 
+// natsConnectionStats returns the statistics for a NATS connection, nc can be nil
+func natsConnectionStats(nc *nats.Conn) NATSConnectionStats {
+	nstats := NATSConnectionStats{}
+
+	if nc != nil {
+		s := nc.Stats()
+		nstats.Connected = nc.IsConnected()
+		nstats.URL = nc.ConnectedUrl()
+		nstats.Reconnects = s.Reconnects
+		nstats.MessagesIn = s.InMsgs
+		nstats.MessagesOut = s.OutMsgs
+		nstats.BytesIn = s.InBytes
+		nstats.BytesOut = s.OutBytes
+	}
+
+	return nstats
+}
+
 // SafeStats grabs the lock then calls stats(), useful for tests
 func (bridge *BridgeServer) SafeStats() BridgeStats {
 	bridge.runningLock.Lock()
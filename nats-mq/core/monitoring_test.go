@@ -55,6 +55,7 @@ func TestMonitoringPages(t *testing.T) {
 	html := string(contents)
 	require.True(t, strings.Contains(html, "/varz"))
 	require.True(t, strings.Contains(html, "/healthz"))
+	require.True(t, strings.Contains(html, "/metrics"))
 
 	response, err = client.Get(tbs.Bridge.GetMonitoringRootURL() + "healthz")
 	require.NoError(t, err)
@@ -87,6 +88,19 @@ func TestMonitoringPages(t *testing.T) {
 	require.Equal(t, int64(0), bridgeStats.Connections[0].MessagesOut)
 	require.Equal(t, int64(0), bridgeStats.Connections[0].BytesIn)
 	require.Equal(t, int64(0), bridgeStats.Connections[0].BytesOut)
+	require.Equal(t, "NATS2Queue", bridgeStats.Connections[0].Type)
+	require.True(t, bridgeStats.NATS.Connected)
+
+	response, err = client.Get(tbs.Bridge.GetMonitoringRootURL() + "metrics")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, metricsContentType, response.Header.Get("Content-Type"))
+	contents, err = ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	metrics := string(contents)
+	require.True(t, strings.Contains(metrics, `nats_mq_http_requests_total{path="/varz"} 1`))
+	require.True(t, strings.Contains(metrics, `nats_mq_connector_connected{id="`+bridgeStats.Connections[0].ID+`"`))
+	require.True(t, strings.Contains(metrics, `nats_mq_nats_connected{connection="",account=""} 1`))
 }
 
 func TestHealthzWithTLS(t *testing.T) {
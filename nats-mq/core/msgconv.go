@@ -83,6 +83,7 @@ func mapHeaderToMQMD(header *message.BridgeHeader) *ibmmq.MQMD {
 	mqmd := ibmmq.NewMQMD()
 
 	/* some fields shouldn't be copied, they aren't user editable
+	the expiry is set by the connector from the remaining lifetime
 	mqmd.Version = header.Version
 	mqmd.MsgType = header.MsgType
 	mqmd.Expiry = header.Expiry
@@ -190,7 +191,7 @@ func (bridge *BridgeServer) MQToNATSMessage(mqmd *ibmmq.MQMD, handle ibmmq.MQMes
 	}
 
 	if replyQ != "" && replyQMgr != "" {
-		connectTo, ok := bridge.replyToInfo["Q:"+replyQ+"@"+replyQMgr]
+		connectTo, ok := bridge.lookupReplyInfo("Q:" + replyQ + "@" + replyQMgr)
 
 		if ok {
 			if connectTo.Subject != "" {
@@ -231,14 +232,21 @@ func (bridge *BridgeServer) MQToNATSMessage(mqmd *ibmmq.MQMD, handle ibmmq.MQMes
 // The returned byte array just bytes from MQ, and is not an encoded BridgeMessage
 // Header fields that are byte arrays are padded, "\x00" added, on conversion from BridgeMessage.Header
 func (bridge *BridgeServer) NATSToMQMessage(data []byte, replyTo string, qmgr *ibmmq.MQQueueManager) (*ibmmq.MQMD, ibmmq.MQMessageHandle, []byte, error) {
+	mqmd, handle, body, _, err := bridge.natsToMQMessage(data, replyTo, qmgr)
+	return mqmd, handle, body, err
+}
+
+// natsToMQMessage is the implementation of NATSToMQMessage, it also returns the decoded
+// BridgeMessage, which will be nil if the qmgr is nil
+func (bridge *BridgeServer) natsToMQMessage(data []byte, replyTo string, qmgr *ibmmq.MQQueueManager) (*ibmmq.MQMD, ibmmq.MQMessageHandle, []byte, *message.BridgeMessage, error) {
 	replyQ := ""
 	replyQMgr := ""
 
 	if replyTo != "" {
-		connectTo, ok := bridge.replyToInfo["S:"+replyTo]
+		connectTo, ok := bridge.lookupReplyInfo("S:" + replyTo)
 
 		if !ok {
-			connectTo, ok = bridge.replyToInfo["C:"+replyTo]
+			connectTo, ok = bridge.lookupReplyInfo("C:" + replyTo)
 		}
 
 		if ok && connectTo.Queue != "" {
@@ -255,22 +263,22 @@ func (bridge *BridgeServer) NATSToMQMessage(data []byte, replyTo string, qmgr *i
 			mqmd.ReplyToQMgr = replyQMgr
 		}
 
-		return mqmd, EmptyHandle, data, nil
+		return mqmd, EmptyHandle, data, nil, nil
 	}
 
 	// Can't have nil data for encoded message, could have for empty plain message
 	if data == nil {
-		return nil, EmptyHandle, nil, fmt.Errorf("tried to convert empty message to BridgeMessage")
+		return nil, EmptyHandle, nil, nil, fmt.Errorf("tried to convert empty message to BridgeMessage")
 	}
 
 	mqMsg, err := message.DecodeBridgeMessage(data)
 
 	if err != nil {
-		return nil, EmptyHandle, nil, err
+		return nil, EmptyHandle, nil, nil, err
 	}
 
 	if mqMsg.Header.ReplyToChannel != "" {
-		connectTo, ok := bridge.replyToInfo["C:"+mqMsg.Header.ReplyToChannel]
+		connectTo, ok := bridge.lookupReplyInfo("C:" + mqMsg.Header.ReplyToChannel)
 		if ok && connectTo.Queue != "" {
 			replyQ = connectTo.Queue
 			replyQMgr = connectTo.MQ.QueueManager
@@ -280,7 +288,7 @@ func (bridge *BridgeServer) NATSToMQMessage(data []byte, replyTo string, qmgr *i
 	handle, err := bridge.mapPropertiesToHandle(mqMsg, qmgr)
 
 	if err != nil {
-		return nil, EmptyHandle, nil, err
+		return nil, EmptyHandle, nil, nil, err
 	}
 
 	mqmd := mapHeaderToMQMD(&mqMsg.Header)
@@ -290,5 +298,5 @@ func (bridge *BridgeServer) NATSToMQMessage(data []byte, replyTo string, qmgr *i
 		mqmd.ReplyToQMgr = replyQMgr
 	}
 
-	return mqmd, handle, mqMsg.Body, nil
+	return mqmd, handle, mqMsg.Body, mqMsg, nil
 }
@@ -43,32 +43,35 @@ func (mq *NATS2QueueConnector) Start() error {
 	mq.Lock()
 	defer mq.Unlock()
 
-	if !mq.bridge.CheckNATS() {
+	if !mq.checkNATS() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
-	if err != nil {
+	if len(mq.config.Targets) > 0 {
+		if err := mq.startTargets(mq); err != nil {
+			return err
+		}
+	} else if err := mq.openQueue(); err != nil {
 		return err
 	}
 
-	// Create the Object Descriptor that allows us to give the queue name
-	qObject, err := mq.connectToQueue(mq.config.Queue, ibmmq.MQOO_OUTPUT)
-
-	if err != nil {
+	if err := mq.startReportListener(mq); err != nil {
 		return err
 	}
 
-	mq.queue = qObject
-
-	sub, err := mq.subscribeToNATS(mq.config.Subject, mq.config.NatsQueue, mq.queue)
+	sub, err := mq.subscribeToNATS(mq, mq.config.Subject, mq.config.NatsQueue, mq.queue)
 	if err != nil {
 		return err
 	}
 	mq.sub = sub
 
+	if err := mq.startQueueMonitor(mq, mq.sub); err != nil {
+		_ = mq.teardown() // don't leave the listener running, the restart sets it up again
+		return err
+	}
+
 	mq.stats.AddConnect()
 	mq.bridge.Logger().Tracef("opened and reading %s", mq.config.Queue)
 	mq.bridge.Logger().Noticef("started connection %s", mq.String())
@@ -76,6 +79,28 @@ func (mq *NATS2QueueConnector) Start() error {
 	return nil
 }
 
+// openQueue connects to MQ, opens the queue and starts the workers, for a connector without targets
+// Expects the lock to be held by the caller.
+func (mq *NATS2QueueConnector) openQueue() error {
+	err := mq.connectToSharedMQ(mq)
+	if err != nil {
+		return err
+	}
+
+	// Create the Object Descriptor that allows us to give the queue name
+	qObject, err := mq.connectToQueue(mq.config.Queue, ibmmq.MQOO_OUTPUT)
+
+	if err != nil {
+		return err
+	}
+
+	mq.queue = qObject
+
+	return mq.startPutWorkers(mq, func(w *connectorWorker) (*ibmmq.MQObject, error) {
+		return w.connectToQueue(mq.config.Queue, ibmmq.MQOO_OUTPUT)
+	})
+}
+
 // Shutdown the connector
 func (mq *NATS2QueueConnector) Shutdown() error {
 	mq.Lock()
@@ -84,11 +109,23 @@ func (mq *NATS2QueueConnector) Shutdown() error {
 
 	mq.bridge.Logger().Noticef("shutting down connection %s", mq.String())
 
+	return mq.teardown()
+}
+
+// teardown stops and closes everything Start set up, expects the lock to be held by the caller
+func (mq *NATS2QueueConnector) teardown() error {
+	mq.stopQueueMonitor(mq)
+
+	mq.stopReportListener(mq)
+
 	if mq.sub != nil {
-		mq.sub.Unsubscribe()
+		mq.unsubscribeFromNATS(mq.sub)
 		mq.sub = nil
 	}
 
+	mq.stopWorkers(mq)
+	mq.stopTargets(mq)
+
 	var err error
 
 	queue := mq.queue
@@ -99,17 +136,26 @@ func (mq *NATS2QueueConnector) Shutdown() error {
 	}
 
 	if mq.qMgr != nil {
-		_ = mq.qMgr.Disc()
-		mq.qMgr = nil
+		_ = mq.disconnectFromMQ(mq)
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return err // ignore the disconnect error
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *NATS2QueueConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *NATS2QueueConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *NATS2QueueConnector) CheckConnections() error {
-	if !mq.bridge.CheckNATS() {
+	if !mq.checkNATS() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 	return nil
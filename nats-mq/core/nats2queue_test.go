@@ -17,12 +17,14 @@ package core
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
 	"github.com/nats-io/nats-mq/message"
 	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -211,3 +213,138 @@ func TestSendOnNatsQueueReceiveOnQueue(t *testing.T) {
 	require.Equal(t, int64(0), connStats.Disconnects)
 	require.True(t, connStats.Connected)
 }
+
+func TestExpiredMessageOnNatsIsDiscarded(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+	msg := "hello world"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "NATS2Queue",
+			Subject:        subject,
+			Queue:          queue,
+			ExcludeHeaders: true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	expired := nats.NewMsg(subject)
+	expired.Data = []byte("expired")
+	expired.Header.Set(ExpiresHeader, time.Now().Add(-time.Second).Format(time.RFC3339Nano))
+	err = tbs.NC.PublishMsg(expired)
+	require.NoError(t, err)
+
+	live := nats.NewMsg(subject)
+	live.Data = []byte(msg)
+	live.Header.Set(ExpiresHeader, time.Now().Add(time.Minute).Format(time.RFC3339Nano))
+	err = tbs.NC.PublishMsg(live)
+	require.NoError(t, err)
+
+	mqmd, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, msg, string(data))
+	require.True(t, mqmd.Expiry > 0)
+	require.True(t, mqmd.Expiry <= 600)
+
+	stats := tbs.Bridge.SafeStats()
+	connStats := stats.Connections[0]
+	require.Equal(t, int64(2), connStats.MessagesIn)
+	require.Equal(t, int64(1), connStats.MessagesOut)
+	require.Equal(t, int64(1), connStats.Expired)
+}
+
+func TestDuplicateMessageOnNatsIsDropped(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "NATS2Queue",
+			Subject:        subject,
+			Queue:          queue,
+			ExcludeHeaders: true,
+			Dedup: conf.DedupConfig{
+				Key:  "header",
+				Name: nats.MsgIdHdr,
+			},
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	for _, body := range []string{"one", "duplicate", "two"} {
+		msg := nats.NewMsg(subject)
+		msg.Data = []byte(body)
+		msg.Header.Set(nats.MsgIdHdr, body)
+		if body == "duplicate" {
+			msg.Header.Set(nats.MsgIdHdr, "one")
+		}
+		err = tbs.NC.PublishMsg(msg)
+		require.NoError(t, err)
+	}
+
+	_, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, "one", string(data))
+
+	_, _, data, err = tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, "two", string(data))
+
+	stats := tbs.Bridge.SafeStats()
+	connStats := stats.Connections[0]
+	require.Equal(t, int64(3), connStats.MessagesIn)
+	require.Equal(t, int64(2), connStats.MessagesOut)
+	require.Equal(t, int64(1), connStats.Duplicates)
+}
+
+func TestNATSToQueueWithWorkersKeepsSubjectOrder(t *testing.T) {
+	queue := "DEV.QUEUE.1"
+	count := 30
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "NATS2Queue",
+			Subject:        "ordered.>",
+			Queue:          queue,
+			ExcludeHeaders: true,
+			Workers:        3,
+			OrderBy:        "subject",
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	for i := 0; i < count; i++ {
+		err = tbs.NC.Publish("ordered.one", []byte(fmt.Sprintf("%d", i)))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < count; i++ {
+		_, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("%d", i), string(data))
+	}
+
+	stats := tbs.Bridge.SafeStats()
+	connStats := stats.Connections[0]
+	require.Equal(t, int64(count), connStats.MessagesOut)
+	require.Len(t, connStats.Workers, 3)
+
+	busy := 0
+	for _, w := range connStats.Workers {
+		require.True(t, w.Connected)
+		if w.MessagesOut > 0 {
+			busy++
+		}
+	}
+	require.Equal(t, 1, busy)
+}
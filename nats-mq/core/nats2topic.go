@@ -43,13 +43,13 @@ func (mq *NATS2TopicConnector) Start() error {
 	mq.Lock()
 	defer mq.Unlock()
 
-	if !mq.bridge.CheckNATS() {
+	if !mq.checkNATS() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
+	err := mq.connectToSharedMQ(mq)
 	if err != nil {
 		return err
 	}
@@ -63,12 +63,28 @@ func (mq *NATS2TopicConnector) Start() error {
 
 	mq.topic = topicObject
 
-	sub, err := mq.subscribeToNATS(mq.config.Subject, mq.config.NatsQueue, mq.topic)
+	err = mq.startPutWorkers(mq, func(w *connectorWorker) (*ibmmq.MQObject, error) {
+		return w.connectToTopic(mq.config.Topic)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := mq.startReportListener(mq); err != nil {
+		return err
+	}
+
+	sub, err := mq.subscribeToNATS(mq, mq.config.Subject, mq.config.NatsQueue, mq.topic)
 	if err != nil {
 		return err
 	}
 	mq.sub = sub
 
+	if err := mq.startQueueMonitor(mq, mq.sub); err != nil {
+		_ = mq.teardown() // don't leave the listener running, the restart sets it up again
+		return err
+	}
+
 	mq.stats.AddConnect()
 	mq.bridge.Logger().Tracef("opened and reading %s", mq.config.Topic)
 	mq.bridge.Logger().Noticef("started connection %s", mq.String())
@@ -84,11 +100,22 @@ func (mq *NATS2TopicConnector) Shutdown() error {
 
 	mq.bridge.Logger().Noticef("shutting down connection %s", mq.String())
 
+	return mq.teardown()
+}
+
+// teardown stops and closes everything Start set up, expects the lock to be held by the caller
+func (mq *NATS2TopicConnector) teardown() error {
+	mq.stopQueueMonitor(mq)
+
+	mq.stopReportListener(mq)
+
 	if mq.sub != nil {
-		mq.sub.Unsubscribe()
+		mq.unsubscribeFromNATS(mq.sub)
 		mq.sub = nil
 	}
 
+	mq.stopWorkers(mq)
+
 	var err error
 
 	topic := mq.topic
@@ -99,17 +126,26 @@ func (mq *NATS2TopicConnector) Shutdown() error {
 	}
 
 	if mq.qMgr != nil {
-		_ = mq.qMgr.Disc()
-		mq.qMgr = nil
+		_ = mq.disconnectFromMQ(mq)
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return err // ignore the disconnect error
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *NATS2TopicConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *NATS2TopicConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *NATS2TopicConnector) CheckConnections() error {
-	if !mq.bridge.CheckNATS() {
+	if !mq.checkNATS() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 	return nil
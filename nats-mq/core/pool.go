@@ -0,0 +1,319 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+)
+
+// pooledConnection is a queue manager connection and the connectors using it
+type pooledConnection struct {
+	config   conf.MQConfig
+	instance string // the connection name, or channel definition table, used to connect
+	qMgr     *ibmmq.MQQueueManager
+	shared   bool
+	broken   bool
+	owners   map[Connector]bool
+}
+
+// poolEntry tracks the connections and statistics for a single queue manager configuration
+type poolEntry struct {
+	connections []*pooledConnection
+	stats       QueueManagerPoolStats
+}
+
+// QueueManagerPool shares queue manager connections between connectors
+// Shared connections are only used for puts outside of a unit of work, connectors that
+// get messages, or use syncpoint, get an exclusive connection. MQ limits a connection
+// to a single thread at a time, shared connections are created with MQCNO_HANDLE_SHARE_BLOCK so
+// that connectors wait for each other.
+type QueueManagerPool struct {
+	sync.Mutex
+
+	bridge  *BridgeServer
+	config  conf.MQPoolConfig
+	entries map[string]*poolEntry // keyed by mqConfigKey
+	byQMgr  map[*ibmmq.MQQueueManager]*pooledConnection
+
+	healthTimer *reconnectTimer
+}
+
+// NewQueueManagerPool creates an empty pool
+func NewQueueManagerPool(bridge *BridgeServer, config conf.MQPoolConfig) *QueueManagerPool {
+	return &QueueManagerPool{
+		bridge:  bridge,
+		config:  config,
+		entries: map[string]*poolEntry{},
+		byQMgr:  map[*ibmmq.MQQueueManager]*pooledConnection{},
+	}
+}
+
+// entry returns the entry for a configuration, creating it if necessary, expects the lock to be held
+func (pool *QueueManagerPool) entry(config conf.MQConfig) *poolEntry {
+	key := mqConfigKey(config)
+	entry, ok := pool.entries[key]
+
+	if !ok {
+		connectionName := strings.Join(mqInstances(config), ",")
+		if config.CCDTURL != "" {
+			connectionName = config.CCDTURL
+		}
+
+		entry = &poolEntry{
+			stats: QueueManagerPoolStats{
+				QueueManager:   config.QueueManager,
+				ConnectionName: connectionName,
+				ChannelName:    config.ChannelName,
+			},
+		}
+		pool.entries[key] = entry
+	}
+
+	return entry
+}
+
+// Acquire returns a connection for the owner, if shared is true an existing connection may be returned
+// Every connection returned by Acquire should be passed to Release.
+func (pool *QueueManagerPool) Acquire(config conf.MQConfig, shared bool, owner Connector) (*ibmmq.MQQueueManager, error) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	shared = shared && !pool.config.DisableSharing
+	entry := pool.entry(config)
+
+	var leastUsed *pooledConnection
+
+	if shared {
+		for _, c := range entry.connections {
+			if !c.shared || c.broken {
+				continue
+			}
+
+			if leastUsed == nil || len(c.owners) < len(leastUsed.owners) {
+				leastUsed = c
+			}
+		}
+
+		if leastUsed != nil && (pool.config.MaxShared <= 0 || len(leastUsed.owners) < pool.config.MaxShared) {
+			leastUsed.owners[owner] = true
+			return leastUsed.qMgr, nil
+		}
+	}
+
+	if pool.config.MaxConnections > 0 && len(entry.connections) >= pool.config.MaxConnections {
+		// Go over the sharing limit rather than fail
+		if leastUsed != nil {
+			leastUsed.owners[owner] = true
+			return leastUsed.qMgr, nil
+		}
+
+		entry.stats.Rejected++
+		return nil, fmt.Errorf("connection pool for queue manager %s at %s is full, %d connections are in use", config.QueueManager, entry.stats.ConnectionName, len(entry.connections))
+	}
+
+	var options int32
+	if shared {
+		options = ibmmq.MQCNO_HANDLE_SHARE_BLOCK
+	}
+
+	qMgr, instance, err := connectToQueueManager(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &pooledConnection{
+		config:   config,
+		instance: instance,
+		qMgr:     qMgr,
+		shared:   shared,
+		owners:   map[Connector]bool{owner: true},
+	}
+
+	entry.connections = append(entry.connections, c)
+	entry.stats.Connects++
+	pool.byQMgr[qMgr] = c
+
+	return qMgr, nil
+}
+
+// Instance returns the connection name, or channel definition table, a pooled connection used to connect
+func (pool *QueueManagerPool) Instance(qMgr *ibmmq.MQQueueManager) string {
+	pool.Lock()
+	defer pool.Unlock()
+
+	if c, ok := pool.byQMgr[qMgr]; ok {
+		return c.instance
+	}
+	return ""
+}
+
+// Release removes the owner from a connection, the connection is disconnected once it has no owners
+func (pool *QueueManagerPool) Release(qMgr *ibmmq.MQQueueManager, owner Connector) error {
+	pool.Lock()
+	defer pool.Unlock()
+
+	c, ok := pool.byQMgr[qMgr]
+
+	if !ok {
+		return fmt.Errorf("queue manager connection is not in the pool")
+	}
+
+	delete(c.owners, owner)
+
+	if len(c.owners) > 0 {
+		return nil
+	}
+
+	pool.remove(c)
+	return c.qMgr.Disc()
+}
+
+// remove takes a connection out of the pool, expects the lock to be held
+func (pool *QueueManagerPool) remove(c *pooledConnection) {
+	delete(pool.byQMgr, c.qMgr)
+
+	entry := pool.entries[mqConfigKey(c.config)]
+	for i, other := range entry.connections {
+		if other == c {
+			entry.connections = append(entry.connections[:i], entry.connections[i+1:]...)
+			break
+		}
+	}
+}
+
+// Close disconnects any remaining connections and stops the health checks
+func (pool *QueueManagerPool) Close() {
+	pool.stopHealthChecks()
+
+	pool.Lock()
+	defer pool.Unlock()
+
+	for qMgr, c := range pool.byQMgr {
+		qMgr.Disc() // ignore the error, we are shutting down
+		pool.remove(c)
+	}
+}
+
+// checkQueueManager makes a round trip to the queue manager by opening it for inquire
+func checkQueueManager(qMgr *ibmmq.MQQueueManager) error {
+	mqod := ibmmq.NewMQOD()
+	mqod.ObjectType = ibmmq.MQOT_Q_MGR
+
+	obj, err := qMgr.Open(mqod, ibmmq.MQOO_INQUIRE)
+	if err != nil {
+		return err
+	}
+
+	return obj.Close(0)
+}
+
+// startHealthChecks checks the shared connections on the configured interval
+// exclusive connections are in use by consumers, which see connection errors themselves
+func (pool *QueueManagerPool) startHealthChecks() {
+	if pool.config.HealthCheckInterval <= 0 {
+		return
+	}
+
+	pool.Lock()
+	timer := newReconnectTimer()
+	pool.healthTimer = timer
+	pool.Unlock()
+
+	go func() {
+		for {
+			if !<-timer.After(time.Duration(pool.config.HealthCheckInterval) * time.Millisecond) {
+				return
+			}
+			pool.checkHealth()
+		}
+	}()
+}
+
+func (pool *QueueManagerPool) stopHealthChecks() {
+	pool.Lock()
+	defer pool.Unlock()
+
+	if pool.healthTimer != nil {
+		pool.healthTimer.Cancel()
+		pool.healthTimer = nil
+	}
+}
+
+// checkHealth marks broken shared connections so they aren't handed out again, and restarts the connectors
+// using them, they will get a new connection when they restart
+func (pool *QueueManagerPool) checkHealth() {
+	owners := map[Connector]error{}
+
+	pool.Lock()
+	for _, entry := range pool.entries {
+		for _, c := range entry.connections {
+			if !c.shared || c.broken {
+				continue
+			}
+
+			if err := checkQueueManager(c.qMgr); err != nil {
+				c.broken = true
+				entry.stats.HealthCheckFailures++
+
+				for owner := range c.owners {
+					owners[owner] = err
+				}
+			}
+		}
+	}
+	pool.Unlock()
+
+	for owner, err := range owners {
+		pool.bridge.ConnectorError(owner, fmt.Errorf("shared queue manager connection failed health check, %s", err.Error()))
+	}
+}
+
+// Stats returns the statistics for each queue manager configuration in the pool
+func (pool *QueueManagerPool) Stats() []QueueManagerPoolStats {
+	pool.Lock()
+	defer pool.Unlock()
+
+	stats := []QueueManagerPoolStats{}
+
+	for _, entry := range pool.entries {
+		s := entry.stats
+		s.Connections = len(entry.connections)
+		s.Instances = map[string]int{}
+
+		for _, c := range entry.connections {
+			if c.instance != "" {
+				s.Instances[c.instance]++ // MQ doesn't say which instance it picked from a list
+			}
+
+			if c.shared {
+				s.Shared++
+			} else {
+				s.Exclusive++
+			}
+			s.Connectors += len(c.owners)
+		}
+
+		stats = append(stats, s)
+	}
+
+	return stats
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutConnectorsShareQueueManagerConnection(t *testing.T) {
+	queue := "DEV.QUEUE.1"
+	other := "DEV.QUEUE.2"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "NATS2Queue",
+			Subject:        "one",
+			Queue:          queue,
+			ExcludeHeaders: true,
+		},
+		{
+			Type:           "NATS2Queue",
+			Subject:        "two",
+			Queue:          other,
+			ExcludeHeaders: true,
+		},
+		{
+			Type:           "Queue2NATS",
+			Subject:        "three",
+			Queue:          other,
+			ExcludeHeaders: true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	err = tbs.NC.Publish("one", []byte("hello"))
+	require.NoError(t, err)
+
+	_, _, data, err := tbs.GetMessageFromQueue(queue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	stats := tbs.Bridge.SafeStats()
+	require.Len(t, stats.MQPool, 1)
+
+	pool := stats.MQPool[0]
+	require.Equal(t, tbs.GetQueueManagerName(), pool.QueueManager)
+	require.Equal(t, 2, pool.Connections)
+	require.Equal(t, 1, pool.Shared)
+	require.Equal(t, 1, pool.Exclusive)
+	require.Equal(t, 3, pool.Connectors)
+}
+
+func TestQueueManagerPoolLimit(t *testing.T) {
+	tbs, err := StartTestEnvironment([]conf.ConnectorConfig{})
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	pool := NewQueueManagerPool(tbs.Bridge, conf.MQPoolConfig{
+		MaxConnections: 1,
+	})
+	defer pool.Close()
+
+	config := conf.MQConfig{
+		ConnectionName: tbs.MQServer.AppHostPort,
+		ChannelName:    "DEV.APP.SVRCONN",
+		QueueManager:   tbs.GetQueueManagerName(),
+	}
+
+	one := &NATS2QueueConnector{}
+	two := &NATS2QueueConnector{}
+	three := &Queue2NATSConnector{}
+
+	qMgr, err := pool.Acquire(config, true, one)
+	require.NoError(t, err)
+
+	// over the share limit is allowed when the pool is full
+	shared, err := pool.Acquire(config, true, two)
+	require.NoError(t, err)
+	require.Equal(t, qMgr, shared)
+
+	_, err = pool.Acquire(config, false, three)
+	require.Error(t, err)
+
+	require.NoError(t, pool.Release(qMgr, one))
+	require.NoError(t, pool.Release(shared, two))
+
+	exclusive, err := pool.Acquire(config, false, three)
+	require.NoError(t, err)
+	require.NoError(t, pool.Release(exclusive, three))
+
+	stats := pool.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, int64(1), stats[0].Rejected)
+	require.Equal(t, int64(2), stats[0].Connects)
+	require.Equal(t, 0, stats[0].Connections)
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+)
+
+// The classes of put failure, based on the MQ reason code
+const (
+	PutErrorRetryable  = "retryable"  // the put may work if it is tried again, like a full queue
+	PutErrorMessage    = "message"    // the message can't be put, other messages may be fine
+	PutErrorConnection = "connection" // the connection or object handle is broken, the connector restarts
+)
+
+// defaultPutRetryWait is used when a connector has put retries but no wait
+const defaultPutRetryWait = 100 * time.Millisecond
+
+// maxPutRetryTime limits the total wait for a put's retries, the connector is locked while it waits
+// so shutdowns and the other messages wait too
+const maxPutRetryTime = 10 * time.Second
+
+// validatePutRetries checks the put retry settings for a connector
+func validatePutRetries(config conf.ConnectorConfig) error {
+	if config.PutRetries < 0 {
+		return fmt.Errorf("putretries %d is invalid, expected a positive number", config.PutRetries)
+	}
+
+	if config.PutRetryWait < 0 {
+		return fmt.Errorf("putretrywait %d is invalid, expected a positive number", config.PutRetryWait)
+	}
+
+	wait := time.Duration(config.PutRetryWait) * time.Millisecond
+	if wait == 0 {
+		wait = defaultPutRetryWait
+	}
+
+	if limit := int(maxPutRetryTime / wait); config.PutRetries > limit {
+		return fmt.Errorf("putretries %d is too many with a putretrywait of %s, the retries can wait at most %s", config.PutRetries, wait, maxPutRetryTime)
+	}
+
+	return nil
+}
+
+// classifyPutError returns the class of a put failure, errors without an MQ reason code only fail the message
+func classifyPutError(err error) string {
+	switch mqReason(err) {
+	case ibmmq.MQRC_CONNECTION_BROKEN, ibmmq.MQRC_Q_MGR_NOT_AVAILABLE, ibmmq.MQRC_Q_MGR_QUIESCING,
+		ibmmq.MQRC_Q_MGR_STOPPING, ibmmq.MQRC_CONNECTION_QUIESCING, ibmmq.MQRC_CONNECTION_STOPPING,
+		ibmmq.MQRC_HCONN_ERROR, ibmmq.MQRC_HOBJ_ERROR, ibmmq.MQRC_RECONNECT_FAILED,
+		ibmmq.MQRC_CALL_INTERRUPTED, ibmmq.MQRC_OBJECT_CHANGED, ibmmq.MQRC_Q_DELETED:
+		return PutErrorConnection
+	case ibmmq.MQRC_Q_FULL, ibmmq.MQRC_Q_SPACE_NOT_AVAILABLE, ibmmq.MQRC_PUT_INHIBITED,
+		ibmmq.MQRC_RESOURCE_PROBLEM, ibmmq.MQRC_STORAGE_NOT_AVAILABLE, ibmmq.MQRC_SYNCPOINT_LIMIT_REACHED:
+		return PutErrorRetryable
+	default:
+		return PutErrorMessage
+	}
+}
+
+// putRetryWait returns the wait between put retries
+func (mq *BridgeConnector) putRetryWait() time.Duration {
+	if mq.config.PutRetryWait == 0 {
+		return defaultPutRetryWait
+	}
+	return time.Duration(mq.config.PutRetryWait) * time.Millisecond
+}
+
+// putMessage puts buffer on dest, retrying transient failures up to the configured number of times
+// The connector stays locked while it waits, so later messages wait behind the retried one.
+func (mq *BridgeConnector) putMessage(dest *ibmmq.MQObject, mqmd *ibmmq.MQMD, pmo *ibmmq.MQPMO, buffer []byte) error {
+	err := dest.Put(mqmd, pmo, buffer)
+
+	for retry := 0; err != nil && retry < mq.config.PutRetries && classifyPutError(err) == PutErrorRetryable; retry++ {
+		mq.bridge.Logger().Tracef("retrying put for %s, %s", mq.String(), err.Error())
+		mq.stats.AddPutRetry()
+		time.Sleep(mq.putRetryWait())
+		err = dest.Put(mqmd, pmo, buffer)
+	}
+
+	return err
+}
+
+// putFailed records a failed put and returns its class, connection failures restart conn, conn
+// can be nil if the caller handles them. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) putFailed(conn Connector, err error) string {
+	class := classifyPutError(err)
+
+	mq.bridge.Logger().Noticef("MQ put failure, %s, %s error, %s", mq.String(), class, err.Error())
+	mq.recordFailure(PutFailure, err)
+
+	if class == PutErrorConnection && conn != nil {
+		go mq.bridge.ConnectorError(conn, err) // run in a go routine so the caller can finish and unlock
+	}
+
+	return class
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func mqError(reason int32) error {
+	return &ibmmq.MQReturn{MQCC: ibmmq.MQCC_FAILED, MQRC: reason}
+}
+
+func TestClassifyPutError(t *testing.T) {
+	require.Equal(t, PutErrorConnection, classifyPutError(mqError(ibmmq.MQRC_CONNECTION_BROKEN)))
+	require.Equal(t, PutErrorConnection, classifyPutError(mqError(ibmmq.MQRC_Q_MGR_NOT_AVAILABLE)))
+	require.Equal(t, PutErrorConnection, classifyPutError(fmt.Errorf("wrapped, %w", mqError(ibmmq.MQRC_HCONN_ERROR))))
+	require.Equal(t, PutErrorRetryable, classifyPutError(mqError(ibmmq.MQRC_Q_FULL)))
+	require.Equal(t, PutErrorRetryable, classifyPutError(mqError(ibmmq.MQRC_PUT_INHIBITED)))
+	require.Equal(t, PutErrorMessage, classifyPutError(mqError(ibmmq.MQRC_MSG_TOO_BIG_FOR_Q)))
+	require.Equal(t, PutErrorMessage, classifyPutError(fmt.Errorf("not an mq error")))
+}
+
+func TestValidatePutRetries(t *testing.T) {
+	require.NoError(t, validatePutRetries(conf.ConnectorConfig{}))
+	require.NoError(t, validatePutRetries(conf.ConnectorConfig{PutRetries: 3, PutRetryWait: 50}))
+	require.Error(t, validatePutRetries(conf.ConnectorConfig{PutRetries: -1}))
+	require.Error(t, validatePutRetries(conf.ConnectorConfig{PutRetryWait: -1}))
+	require.NoError(t, validatePutRetries(conf.ConnectorConfig{PutRetries: 100}))
+	require.Error(t, validatePutRetries(conf.ConnectorConfig{PutRetries: 101}))
+	require.Error(t, validatePutRetries(conf.ConnectorConfig{PutRetries: 2, PutRetryWait: 6000}))
+}
+
+func TestPutRetryWait(t *testing.T) {
+	mq := &BridgeConnector{}
+	require.Equal(t, defaultPutRetryWait, mq.putRetryWait())
+	mq.config.PutRetryWait = 250
+	require.Equal(t, 250*time.Millisecond, mq.putRetryWait())
+}
+
+func TestPutFailureRestartsConnector(t *testing.T) {
+	connector := &flakyConnector{stateConnector: stateConnector{id: "put", state: ConnectorRunning}, failures: 100}
+	bridge := startReconnectTestBridge(t, connector)
+	defer bridge.stopReconnectTimer()
+
+	mq := &BridgeConnector{bridge: bridge, stats: NewConnectorStats()}
+
+	require.Equal(t, PutErrorMessage, mq.putFailed(connector, mqError(ibmmq.MQRC_MSG_TOO_BIG_FOR_Q)))
+	require.Equal(t, PutErrorRetryable, mq.putFailed(connector, mqError(ibmmq.MQRC_Q_FULL)))
+	require.False(t, bridge.checkReconnecting())
+
+	require.Equal(t, PutErrorConnection, mq.putFailed(connector, mqError(ibmmq.MQRC_CONNECTION_BROKEN)))
+	require.Eventually(t, bridge.checkReconnecting, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, int64(3), mq.stats.PutFailures)
+	require.Equal(t, ibmmq.MQRC_CONNECTION_BROKEN, mq.stats.RecentErrors[2].Reason)
+}
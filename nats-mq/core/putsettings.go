@@ -0,0 +1,210 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+)
+
+// noPersistence marks a put setting that doesn't change the persistence
+const noPersistence = int32(-1)
+
+// putSettings holds the parsed persistence, priority, expiry and format for puts to MQ
+// zero values, and noPersistence, leave the MQMD unchanged
+type putSettings struct {
+	persistence int32
+	priority    int32
+	expiry      int32 // in tenths of a second, like the MQMD
+	format      string
+}
+
+// putRule is the parsed version of a conf.PutRule
+type putRule struct {
+	subject  string
+	property string
+	value    string
+	settings putSettings
+}
+
+func parsePersistence(persistence string) (int32, error) {
+	switch strings.ToLower(persistence) {
+	case "":
+		return noPersistence, nil
+	case "queue":
+		return ibmmq.MQPER_PERSISTENCE_AS_Q_DEF, nil
+	case "persistent":
+		return ibmmq.MQPER_PERSISTENT, nil
+	case "nonpersistent":
+		return ibmmq.MQPER_NOT_PERSISTENT, nil
+	default:
+		return noPersistence, fmt.Errorf("unknown persistence %q, expected persistent, nonpersistent or queue", persistence)
+	}
+}
+
+func parsePutSettings(persistence string, priority int, expiry int, format string) (putSettings, error) {
+	settings := putSettings{
+		format: format,
+	}
+
+	p, err := parsePersistence(persistence)
+	if err != nil {
+		return settings, err
+	}
+	settings.persistence = p
+
+	if priority < 0 || priority > 9 {
+		return settings, fmt.Errorf("priority %d is out of range, expected 1-9, or 0 for the queue's default", priority)
+	}
+	settings.priority = int32(priority)
+
+	if expiry < 0 {
+		return settings, fmt.Errorf("expiry %d is invalid, expected a positive number of milliseconds", expiry)
+	}
+
+	// MQ expiry is in tenths of a second, round up so short expiries aren't lost
+	settings.expiry = int32((expiry + 99) / 100)
+
+	if len(format) > int(ibmmq.MQ_FORMAT_LENGTH) {
+		return settings, fmt.Errorf("format %q is longer than %d characters", format, ibmmq.MQ_FORMAT_LENGTH)
+	}
+
+	return settings, nil
+}
+
+// newPutSettings parses the put defaults and rules from a connector configuration
+func newPutSettings(config conf.ConnectorConfig) (putSettings, []putRule, error) {
+	defaults, err := parsePutSettings(config.Persistence, config.Priority, config.Expiry, config.Format)
+	if err != nil {
+		return defaults, nil, err
+	}
+
+	rules := []putRule{}
+	for _, r := range config.PutRules {
+		settings, err := parsePutSettings(r.Persistence, r.Priority, r.Expiry, r.Format)
+		if err != nil {
+			return defaults, nil, fmt.Errorf("invalid put rule for subject %q and property %q, %s", r.Subject, r.Property, err.Error())
+		}
+		rules = append(rules, putRule{
+			subject:  r.Subject,
+			property: r.Property,
+			value:    r.Value,
+			settings: settings,
+		})
+	}
+
+	return defaults, rules, nil
+}
+
+// merge returns a copy of the settings with the non-zero values from override
+func (s putSettings) merge(override putSettings) putSettings {
+	if override.persistence != noPersistence {
+		s.persistence = override.persistence
+	}
+	if override.priority != 0 {
+		s.priority = override.priority
+	}
+	if override.expiry != 0 {
+		s.expiry = override.expiry
+	}
+	if override.format != "" {
+		s.format = override.format
+	}
+	return s
+}
+
+// apply updates the MQMD, priority, expiry and format are only set if the message didn't set them
+func (s putSettings) apply(mqmd *ibmmq.MQMD) {
+	if s.persistence != noPersistence {
+		mqmd.Persistence = s.persistence
+	}
+
+	if s.priority != 0 && mqmd.Priority == ibmmq.MQPRI_PRIORITY_AS_Q_DEF {
+		mqmd.Priority = s.priority
+	}
+
+	if s.expiry != 0 && mqmd.Expiry == ibmmq.MQEI_UNLIMITED {
+		mqmd.Expiry = s.expiry
+	}
+
+	if s.format != "" && strings.TrimSpace(mqmd.Format) == "" {
+		mqmd.Format = s.format
+	}
+}
+
+// matches checks the rule against the subject and message, msg is nil if headers are excluded
+func (r putRule) matches(subject string, msg *message.BridgeMessage) bool {
+	if r.subject != "" && !subjectMatches(r.subject, subject) {
+		return false
+	}
+
+	if r.property == "" {
+		return true
+	}
+
+	if msg == nil {
+		return false
+	}
+
+	value, ok := msg.GetTypedProperty(r.property)
+
+	if !ok {
+		return false
+	}
+
+	return r.value == "" || fmt.Sprint(value) == r.value
+}
+
+// subjectMatches checks a subject against a pattern that may contain the * and > wildcards
+func subjectMatches(pattern string, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+
+		if i >= len(subjectTokens) {
+			return false
+		}
+
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// applyPutSettings sets the persistence, priority, expiry and format on a message headed to MQ
+// using the connector defaults and the first matching put rule
+func (mq *BridgeConnector) applyPutSettings(mqmd *ibmmq.MQMD, subject string, msg *message.BridgeMessage) {
+	settings := mq.putDefaults
+
+	for _, rule := range mq.putRules {
+		if rule.matches(subject, msg) {
+			settings = settings.merge(rule.settings)
+			break
+		}
+	}
+
+	settings.apply(mqmd)
+}
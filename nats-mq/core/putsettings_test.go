@@ -0,0 +1,113 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	require.True(t, subjectMatches("a.b.c", "a.b.c"))
+	require.True(t, subjectMatches("a.*.c", "a.b.c"))
+	require.True(t, subjectMatches("a.>", "a.b.c"))
+	require.True(t, subjectMatches(">", "a"))
+	require.False(t, subjectMatches("a.>", "a"))
+	require.False(t, subjectMatches("a.*", "a.b.c"))
+	require.False(t, subjectMatches("a.b.c", "a.b"))
+	require.False(t, subjectMatches("a.b", "a.c"))
+}
+
+func TestInvalidPutSettings(t *testing.T) {
+	_, _, err := newPutSettings(conf.ConnectorConfig{Persistence: "sometimes"})
+	require.Error(t, err)
+
+	_, _, err = newPutSettings(conf.ConnectorConfig{Priority: 10})
+	require.Error(t, err)
+
+	_, _, err = newPutSettings(conf.ConnectorConfig{Expiry: -1})
+	require.Error(t, err)
+
+	_, _, err = newPutSettings(conf.ConnectorConfig{
+		PutRules: []conf.PutRule{{Format: "TOOLONGFORMAT"}},
+	})
+	require.Error(t, err)
+}
+
+func TestPutDefaultsAndRules(t *testing.T) {
+	config := conf.ConnectorConfig{
+		Persistence: "persistent",
+		Priority:    4,
+		Expiry:      1050,
+		Format:      ibmmq.MQFMT_STRING,
+		PutRules: []conf.PutRule{
+			{
+				Subject:     "fast.>",
+				Persistence: "nonpersistent",
+			},
+			{
+				Property: "urgent",
+				Value:    "true",
+				Priority: 9,
+			},
+		},
+	}
+
+	connector := &BridgeConnector{}
+	connector.init(nil, config, "test")
+
+	mqmd := ibmmq.NewMQMD()
+	connector.applyPutSettings(mqmd, "slow", nil)
+	require.Equal(t, ibmmq.MQPER_PERSISTENT, mqmd.Persistence)
+	require.Equal(t, int32(4), mqmd.Priority)
+	require.Equal(t, int32(11), mqmd.Expiry)
+	require.Equal(t, ibmmq.MQFMT_STRING, mqmd.Format)
+
+	mqmd = ibmmq.NewMQMD()
+	connector.applyPutSettings(mqmd, "fast.one", nil)
+	require.Equal(t, ibmmq.MQPER_NOT_PERSISTENT, mqmd.Persistence)
+	require.Equal(t, int32(4), mqmd.Priority)
+
+	msg := message.NewBridgeMessage([]byte("hello"))
+	msg.SetProperty("urgent", true)
+	mqmd = ibmmq.NewMQMD()
+	connector.applyPutSettings(mqmd, "slow", msg)
+	require.Equal(t, ibmmq.MQPER_PERSISTENT, mqmd.Persistence)
+	require.Equal(t, int32(9), mqmd.Priority)
+
+	// The property rule can't match without headers
+	mqmd = ibmmq.NewMQMD()
+	connector.applyPutSettings(mqmd, "slow", nil)
+	require.Equal(t, int32(4), mqmd.Priority)
+
+	// Values set by the message win over the defaults
+	mqmd = ibmmq.NewMQMD()
+	mqmd.Priority = 2
+	mqmd.Format = "CUSTOM"
+	connector.applyPutSettings(mqmd, "slow", nil)
+	require.Equal(t, int32(2), mqmd.Priority)
+	require.Equal(t, "CUSTOM", mqmd.Format)
+
+	// Priority 0 is a real priority, only the queue default is replaced
+	mqmd = ibmmq.NewMQMD()
+	mqmd.Priority = 0
+	connector.applyPutSettings(mqmd, "slow", nil)
+	require.Equal(t, int32(0), mqmd.Priority)
+}
@@ -42,30 +42,45 @@ func (mq *Queue2NATSConnector) Start() error {
 	mq.Lock()
 	defer mq.Unlock()
 
-	if !mq.bridge.CheckNATS() {
+	if !mq.checkNATS() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
-	if err != nil {
-		return err
-	}
+	if mq.config.Workers > 1 {
+		// each worker has its own connection and listener, the connector doesn't need one
+		err := mq.startGetWorkers(mq, mq.config.Queue, func(w *connectorWorker) NATSCallback {
+			return w.natsMessageHandler
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		err := mq.connectToMQ(mq)
+		if err != nil {
+			return err
+		}
 
-	// Create the Object Descriptor that allows us to give the queue name
-	qObject, err := mq.connectToQueue(mq.config.Queue, ibmmq.MQOO_INPUT_SHARED)
-	if err != nil {
-		return err
-	}
+		// Create the Object Descriptor that allows us to give the queue name
+		qObject, err := mq.connectToQueue(mq.config.Queue, ibmmq.MQOO_INPUT_SHARED)
+		if err != nil {
+			return err
+		}
 
-	mq.queue = qObject
+		mq.queue = qObject
+
+		cb, err := mq.setUpListener(mq.queue, mq.natsMessageHandler, mq)
+		if err != nil {
+			return err
+		}
+		mq.shutdownCB = cb
+	}
 
-	cb, err := mq.setUpListener(mq.queue, mq.natsMessageHandler, mq)
-	if err != nil {
+	if err := mq.startQueueMonitor(mq, nil); err != nil {
+		_ = mq.teardown() // don't leave the listener running, the restart sets it up again
 		return err
 	}
-	mq.shutdownCB = cb
 
 	mq.stats.AddConnect()
 	mq.bridge.Logger().Tracef("opened and reading %s", mq.config.Queue)
@@ -82,6 +97,13 @@ func (mq *Queue2NATSConnector) Shutdown() error {
 
 	mq.bridge.Logger().Noticef("shutting down connection %s", mq.String())
 
+	return mq.teardown()
+}
+
+// teardown stops and closes everything Start set up, expects the lock to be held by the caller
+func (mq *Queue2NATSConnector) teardown() error {
+	mq.stopQueueMonitor(mq)
+
 	if mq.shutdownCB != nil {
 		if err := mq.shutdownCB(); err != nil {
 			mq.bridge.Logger().Noticef("error stopping listener for %s, %s", mq.String(), err.Error())
@@ -89,6 +111,8 @@ func (mq *Queue2NATSConnector) Shutdown() error {
 		mq.shutdownCB = nil
 	}
 
+	mq.stopWorkers(mq)
+
 	queue := mq.queue
 	mq.queue = nil
 
@@ -99,19 +123,28 @@ func (mq *Queue2NATSConnector) Shutdown() error {
 	}
 
 	if mq.qMgr != nil {
-		if err := mq.qMgr.Disc(); err != nil {
+		if err := mq.disconnectFromMQ(mq); err != nil {
 			mq.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", mq.String(), err.Error())
 		}
-		mq.qMgr = nil
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return nil // ignore the disconnect error
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *Queue2NATSConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *Queue2NATSConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *Queue2NATSConnector) CheckConnections() error {
-	if !mq.bridge.CheckNATS() {
+	if !mq.natsAvailable() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 	return nil
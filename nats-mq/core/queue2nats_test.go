@@ -17,6 +17,7 @@ package core
 
 import (
 	"bytes"
+	"encoding/hex"
 	"testing"
 	"time"
 
@@ -143,6 +144,85 @@ func TestSendOnQueueReceiveOnNatsMQMD(t *testing.T) {
 	require.True(t, connStats.Connected)
 }
 
+func TestQueue2NATSSetsMsgIDHeader(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+	id := bytes.Repeat([]byte{1}, int(ibmmq.MQ_MSG_ID_LENGTH))
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "Queue2NATS",
+			Subject:        subject,
+			Queue:          queue,
+			ExcludeHeaders: true,
+			MsgIDHeader:    true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	done := make(chan string)
+
+	sub, err := tbs.NC.Subscribe(subject, func(msg *nats.Msg) {
+		done <- msg.Header.Get(nats.MsgIdHdr)
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	mqmd := ibmmq.NewMQMD()
+	mqmd.MsgId = id
+	err = tbs.PutMessageOnQueue(queue, mqmd, []byte("hello world"))
+	require.NoError(t, err)
+
+	timer := time.NewTimer(3 * time.Second)
+	go func() {
+		<-timer.C
+		done <- ""
+	}()
+
+	received := <-done
+	require.Equal(t, hex.EncodeToString(id), received)
+}
+
+func TestQueue2NATSSetsTimestampHeader(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:            "Queue2NATS",
+			Subject:         subject,
+			Queue:           queue,
+			ExcludeHeaders:  true,
+			TimestampHeader: true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	sub, err := tbs.NC.SubscribeSync(subject)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	before := time.Now().Add(-time.Second) // MQ put times are in hundredths of a second, and the clocks can differ
+	err = tbs.PutMessageOnQueue(queue, ibmmq.NewMQMD(), []byte("hello world"))
+	require.NoError(t, err)
+
+	msg, err := sub.NextMsg(3 * time.Second)
+	require.NoError(t, err)
+
+	timestamp := natsTimestamp(msg)
+	require.False(t, timestamp.IsZero())
+	require.True(t, timestamp.After(before))
+
+	stats := tbs.Bridge.SafeStats().Connections[0]
+	require.Equal(t, int64(1), stats.LatencyCount)
+}
+
 func TestSimpleSendOnQueueReceiveOnNatsWithTLS(t *testing.T) {
 	subject := "test"
 	queue := "DEV.QUEUE.1"
@@ -231,3 +311,40 @@ func TestSimpleSendOnQueueReceiveOnNatsPolling(t *testing.T) {
 	require.Equal(t, int64(0), connStats.Disconnects)
 	require.True(t, connStats.Connected)
 }
+
+func TestQueue2NATSDeliveryReport(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+	reportQueue := "DEV.QUEUE.2"
+	msg := "hello world"
+	id := bytes.Repeat([]byte{1}, int(ibmmq.MQ_MSG_ID_LENGTH))
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:            "Queue2NATS",
+			Subject:         subject,
+			Queue:           queue,
+			ExcludeHeaders:  true,
+			GenerateReports: true,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	mqmd := ibmmq.NewMQMD()
+	mqmd.MsgId = id
+	mqmd.Report = ibmmq.MQRO_COD_WITH_FULL_DATA
+	mqmd.ReplyToQ = reportQueue
+	mqmd.ReplyToQMgr = tbs.GetQueueManagerName()
+	err = tbs.PutMessageOnQueue(queue, mqmd, []byte(msg))
+	require.NoError(t, err)
+
+	reportMD, _, data, err := tbs.GetMessageFromQueue(reportQueue, 5000)
+	require.NoError(t, err)
+	require.Equal(t, ibmmq.MQMT_REPORT, reportMD.MsgType)
+	require.Equal(t, ibmmq.MQFB_COD, reportMD.Feedback)
+	require.ElementsMatch(t, id, reportMD.CorrelId)
+	require.Equal(t, msg, string(data))
+}
@@ -48,24 +48,39 @@ func (mq *Queue2STANConnector) Start() error {
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
-	if err != nil {
-		return err
-	}
+	if mq.config.Workers > 1 {
+		// each worker has its own connection and listener, the connector doesn't need one
+		err := mq.startGetWorkers(mq, mq.config.Queue, func(w *connectorWorker) NATSCallback {
+			return w.stanMessageHandler
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		err := mq.connectToMQ(mq)
+		if err != nil {
+			return err
+		}
 
-	// Create the Object Descriptor that allows us to give the queue name
-	qObject, err := mq.connectToQueue(mq.config.Queue, ibmmq.MQOO_INPUT_SHARED)
-	if err != nil {
-		return err
-	}
+		// Create the Object Descriptor that allows us to give the queue name
+		qObject, err := mq.connectToQueue(mq.config.Queue, ibmmq.MQOO_INPUT_SHARED)
+		if err != nil {
+			return err
+		}
 
-	mq.queue = qObject
+		mq.queue = qObject
+
+		cb, err := mq.setUpListener(mq.queue, mq.stanMessageHandler, mq)
+		if err != nil {
+			return err
+		}
+		mq.shutdownCB = cb
+	}
 
-	cb, err := mq.setUpListener(mq.queue, mq.stanMessageHandler, mq)
-	if err != nil {
+	if err := mq.startQueueMonitor(mq, nil); err != nil {
+		_ = mq.teardown() // don't leave the listener running, the restart sets it up again
 		return err
 	}
-	mq.shutdownCB = cb
 
 	mq.stats.AddConnect()
 	mq.bridge.Logger().Tracef("opened and reading %s", mq.config.Queue)
@@ -81,6 +96,13 @@ func (mq *Queue2STANConnector) Shutdown() error {
 
 	mq.bridge.Logger().Noticef("shutting down connection %s", mq.String())
 
+	return mq.teardown()
+}
+
+// teardown stops and closes everything Start set up, expects the lock to be held by the caller
+func (mq *Queue2STANConnector) teardown() error {
+	mq.stopQueueMonitor(mq)
+
 	if mq.shutdownCB != nil {
 		if err := mq.shutdownCB(); err != nil {
 			mq.bridge.Logger().Noticef("error stopping listener for %s, %s", mq.String(), err.Error())
@@ -88,6 +110,8 @@ func (mq *Queue2STANConnector) Shutdown() error {
 		mq.shutdownCB = nil
 	}
 
+	mq.stopWorkers(mq)
+
 	queue := mq.queue
 	mq.queue = nil
 
@@ -100,16 +124,25 @@ func (mq *Queue2STANConnector) Shutdown() error {
 
 	if mq.qMgr != nil {
 		mq.bridge.Logger().Noticef("shutting down qmgr")
-		if err := mq.qMgr.Disc(); err != nil {
+		if err := mq.disconnectFromMQ(mq); err != nil {
 			mq.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", mq.String(), err.Error())
 		}
-		mq.qMgr = nil
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return nil
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *Queue2STANConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *Queue2STANConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *Queue2STANConnector) CheckConnections() error {
 	if !mq.bridge.CheckStan() {
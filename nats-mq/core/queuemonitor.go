@@ -0,0 +1,321 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+)
+
+// The alerts published by the queue monitor
+const (
+	DepthAlert = "depth"
+	AgeAlert   = "age"
+)
+
+// QueueAlert is published to a connector's alert subject when its queue crosses a threshold, and
+// again, with active set to false, when it drops back below the threshold
+type QueueAlert struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Queue     string    `json:"queue,omitempty"`
+	Alert     string    `json:"alert"`
+	Active    bool      `json:"active"`
+	Value     int64     `json:"value"`
+	Threshold int64     `json:"threshold"`
+	Time      time.Time `json:"time"`
+}
+
+// validateMonitor checks the queue monitor settings for a connector
+func validateMonitor(config conf.ConnectorConfig) error {
+	monitor := config.Monitor
+
+	if monitor.Interval < 0 || monitor.DepthThreshold < 0 || monitor.AgeThreshold < 0 {
+		return fmt.Errorf("monitor interval and thresholds can't be negative")
+	}
+
+	if monitor.Interval == 0 {
+		return nil
+	}
+
+	switch config.Type {
+	case conf.Topic2NATS, conf.Topic2Stan, conf.Stan2Topic:
+		return fmt.Errorf("%s connectors don't have a queue or NATS subscription to monitor", config.Type)
+	}
+
+	if (monitor.DepthThreshold > 0 || monitor.AgeThreshold > 0) && monitor.AlertSubject == "" {
+		return fmt.Errorf("monitor thresholds require an alertsubject")
+	}
+
+	return nil
+}
+
+// queueMonitor checks a connector's queue, on its own queue manager connection, and its NATS subscription
+// Consumers can't share their connection while MQ is calling them back, so the monitor has its own.
+type queueMonitor struct {
+	sync.Mutex
+
+	mq       *BridgeConnector
+	conn     Connector
+	config   conf.QueueMonitorConfig
+	interval time.Duration
+
+	qMgr  *ibmmq.MQQueueManager
+	queue *ibmmq.MQObject
+	sub   pendingSubscription
+	timer *reconnectTimer
+
+	stats  QueueStats
+	alerts map[string]bool
+}
+
+// pendingSubscription is a NATS or streaming subscription, both count the messages waiting for the callback
+type pendingSubscription interface {
+	Pending() (int, int, error)
+}
+
+// startQueueMonitor starts checking the connector's queue, if it has one, and sub, which can be nil
+// Expects the lock to be held by the caller.
+func (mq *BridgeConnector) startQueueMonitor(conn Connector, sub pendingSubscription) error {
+	if mq.config.Monitor.Interval <= 0 {
+		return nil
+	}
+
+	mq.stopQueueMonitor(conn)
+
+	monitor := &queueMonitor{
+		mq:       mq,
+		conn:     conn,
+		config:   mq.config.Monitor,
+		interval: time.Duration(mq.config.Monitor.Interval) * time.Millisecond,
+		sub:      sub,
+		timer:    newReconnectTimer(),
+		alerts:   map[string]bool{},
+		stats: QueueStats{
+			Queue: mq.config.Queue,
+		},
+	}
+
+	if mq.config.Queue != "" {
+		qMgr, err := mq.bridge.QueueManagerPool().Acquire(mq.config.MQ, false, conn)
+		if err != nil {
+			return err
+		}
+		monitor.qMgr = qMgr
+
+		mqod := ibmmq.NewMQOD()
+		mqod.ObjectType = ibmmq.MQOT_Q
+		mqod.ObjectName = mq.config.Queue
+
+		queue, err := qMgr.Open(mqod, ibmmq.MQOO_INQUIRE|ibmmq.MQOO_BROWSE|ibmmq.MQOO_FAIL_IF_QUIESCING)
+		if err != nil {
+			mq.bridge.QueueManagerPool().Release(qMgr, conn)
+			return err
+		}
+		monitor.queue = &queue
+	}
+
+	mq.monitor = monitor
+	go monitor.run()
+
+	mq.bridge.Logger().Tracef("monitoring %s every %s", mq.String(), monitor.interval)
+	return nil
+}
+
+// stopQueueMonitor stops the checks and releases the monitor's connection, expects the lock to be held
+func (mq *BridgeConnector) stopQueueMonitor(conn Connector) {
+	monitor := mq.monitor
+	if monitor == nil {
+		return
+	}
+	mq.monitor = nil
+
+	monitor.Lock()
+	defer monitor.Unlock()
+
+	monitor.timer.Cancel()
+
+	if monitor.queue != nil {
+		if err := monitor.queue.Close(0); err != nil {
+			mq.bridge.Logger().Noticef("error closing monitored queue for %s, %s", mq.String(), err.Error())
+		}
+		monitor.queue = nil
+	}
+
+	if monitor.qMgr != nil {
+		if err := mq.bridge.QueueManagerPool().Release(monitor.qMgr, conn); err != nil {
+			mq.bridge.Logger().Noticef("error disconnecting monitor for %s, %s", mq.String(), err.Error())
+		}
+		monitor.qMgr = nil
+	}
+}
+
+// queueStats returns the results of the last check, nil if the connector isn't monitored
+func (mq *BridgeConnector) queueStats() *QueueStats {
+	if mq.monitor == nil {
+		return nil
+	}
+
+	mq.monitor.Lock()
+	defer mq.monitor.Unlock()
+
+	stats := mq.monitor.stats
+	return &stats
+}
+
+func (monitor *queueMonitor) run() {
+	monitor.check()
+
+	for {
+		if ok := <-monitor.timer.After(monitor.interval); !ok {
+			return
+		}
+		monitor.check()
+	}
+}
+
+// check inquires the queue and subscription, then publishes any alerts
+func (monitor *queueMonitor) check() {
+	monitor.Lock()
+	defer monitor.Unlock()
+
+	if monitor.queue == nil && monitor.sub == nil {
+		return // stopped
+	}
+
+	now := time.Now()
+	stats := monitor.stats
+	stats.Checked = now
+	stats.Error = ""
+
+	if monitor.queue != nil {
+		if err := inquireQueue(monitor.queue, &stats, now); err != nil {
+			stats.Error = err.Error()
+		}
+	}
+
+	if monitor.sub != nil {
+		msgs, bytes, err := monitor.sub.Pending()
+		if err == nil {
+			stats.PendingMsgs = msgs
+			stats.PendingBytes = bytes
+		}
+	}
+
+	if stats.Error != "" && monitor.stats.Error == "" {
+		monitor.mq.bridge.Logger().Noticef("unable to check the queue for %s, %s", monitor.mq.String(), stats.Error)
+	}
+
+	monitor.stats = stats
+
+	if stats.Error != "" {
+		return
+	}
+
+	depth := int64(stats.PendingMsgs)
+	if monitor.queue != nil {
+		depth = int64(stats.Depth)
+	}
+
+	monitor.alert(DepthAlert, depth, int64(monitor.config.DepthThreshold), now)
+	monitor.alert(AgeAlert, stats.OldestMessageAge, int64(monitor.config.AgeThreshold), now)
+}
+
+// inquireQueue reads the depth and open input count, and browses the first message for its age
+func inquireQueue(queue *ibmmq.MQObject, stats *QueueStats, now time.Time) error {
+	values, err := queue.Inq([]int32{ibmmq.MQIA_CURRENT_Q_DEPTH, ibmmq.MQIA_OPEN_INPUT_COUNT})
+	if err != nil {
+		return err
+	}
+
+	stats.Depth, _ = values[ibmmq.MQIA_CURRENT_Q_DEPTH].(int32)
+	stats.OpenInputCount, _ = values[ibmmq.MQIA_OPEN_INPUT_COUNT].(int32)
+	stats.OldestMessageAge = 0
+
+	if stats.Depth == 0 {
+		return nil
+	}
+
+	mqmd := ibmmq.NewMQMD()
+	gmo := ibmmq.NewMQGMO()
+	gmo.Options = ibmmq.MQGMO_BROWSE_FIRST | ibmmq.MQGMO_NO_WAIT | ibmmq.MQGMO_ACCEPT_TRUNCATED_MSG | ibmmq.MQGMO_FAIL_IF_QUIESCING
+
+	_, err = queue.Get(mqmd, gmo, make([]byte, 0))
+	if err != nil {
+		mqret, ok := err.(*ibmmq.MQReturn)
+		if !ok {
+			return err
+		}
+
+		switch mqret.MQRC {
+		case ibmmq.MQRC_NO_MSG_AVAILABLE:
+			return nil // the queue emptied since the inquire
+		case ibmmq.MQRC_TRUNCATED_MSG_ACCEPTED:
+		default:
+			return err
+		}
+	}
+
+	if !mqmd.PutDateTime.IsZero() {
+		if age := now.Sub(mqmd.PutDateTime); age > 0 {
+			stats.OldestMessageAge = int64(age / time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+// alert publishes an alert when value reaches the threshold, and when it drops back below it
+// expects the monitor's lock to be held
+func (monitor *queueMonitor) alert(kind string, value int64, threshold int64, now time.Time) {
+	if threshold <= 0 {
+		return
+	}
+
+	active := value >= threshold
+	if active == monitor.alerts[kind] {
+		return
+	}
+	monitor.alerts[kind] = active
+
+	if active {
+		monitor.stats.Alerts++
+		monitor.mq.bridge.Logger().Noticef("%s %s %d reached the alert threshold %d", monitor.mq.String(), kind, value, threshold)
+	}
+
+	data, err := json.Marshal(QueueAlert{
+		ID:        monitor.mq.ID(),
+		Name:      monitor.mq.String(),
+		Queue:     monitor.stats.Queue,
+		Alert:     kind,
+		Active:    active,
+		Value:     value,
+		Threshold: threshold,
+		Time:      now,
+	})
+	if err != nil {
+		return
+	}
+
+	if err := monitor.mq.natsConn().Publish(monitor.config.AlertSubject, data); err != nil {
+		monitor.mq.bridge.Logger().Noticef("unable to publish %s alert for %s, %s", kind, monitor.mq.String(), err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMonitor(t *testing.T) {
+	require.NoError(t, validateMonitor(conf.ConnectorConfig{Type: conf.Topic2NATS}))
+	require.NoError(t, validateMonitor(conf.ConnectorConfig{Type: conf.Queue2NATS, Monitor: conf.QueueMonitorConfig{Interval: 1000}}))
+	require.NoError(t, validateMonitor(conf.ConnectorConfig{Type: conf.NATS2Topic, Monitor: conf.QueueMonitorConfig{Interval: 1000, DepthThreshold: 10, AlertSubject: "alerts"}}))
+
+	require.Error(t, validateMonitor(conf.ConnectorConfig{Type: conf.Queue2NATS, Monitor: conf.QueueMonitorConfig{Interval: -1}}))
+	require.Error(t, validateMonitor(conf.ConnectorConfig{Type: conf.Topic2NATS, Monitor: conf.QueueMonitorConfig{Interval: 1000}}))
+	require.Error(t, validateMonitor(conf.ConnectorConfig{Type: conf.Stan2Topic, Monitor: conf.QueueMonitorConfig{Interval: 1000}}))
+	require.Error(t, validateMonitor(conf.ConnectorConfig{Type: conf.Queue2NATS, Monitor: conf.QueueMonitorConfig{Interval: 1000, AgeThreshold: 500}}))
+}
+
+func TestQueueMonitorAlertsOnlyOnChange(t *testing.T) {
+	connector := &BridgeConnector{}
+	connector.init(NewBridgeServer(), conf.ConnectorConfig{}, "test")
+
+	monitor := &queueMonitor{
+		mq:     connector,
+		alerts: map[string]bool{},
+	}
+
+	now := time.Now()
+	monitor.alert(DepthAlert, 5, 10, now)
+	require.False(t, monitor.alerts[DepthAlert])
+	require.Equal(t, int64(0), monitor.stats.Alerts)
+
+	monitor.alert(DepthAlert, 10, 10, now)
+	monitor.alert(DepthAlert, 15, 10, now)
+	require.True(t, monitor.alerts[DepthAlert])
+	require.Equal(t, int64(1), monitor.stats.Alerts)
+
+	monitor.alert(DepthAlert, 3, 10, now)
+	require.False(t, monitor.alerts[DepthAlert])
+
+	monitor.alert(DepthAlert, 11, 10, now)
+	require.Equal(t, int64(2), monitor.stats.Alerts)
+
+	// no threshold, no alerts
+	monitor.alert(AgeAlert, 1000, 0, now)
+	require.False(t, monitor.alerts[AgeAlert])
+}
+
+func TestQueueDepthAlert(t *testing.T) {
+	subject := "test"
+	queue := "DEV.QUEUE.1"
+	alerts := "alerts"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:           "NATS2Queue",
+			Subject:        subject,
+			Queue:          queue,
+			ExcludeHeaders: true,
+			Monitor: conf.QueueMonitorConfig{
+				Interval:       100,
+				DepthThreshold: 2,
+				AlertSubject:   alerts,
+			},
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	sub, err := tbs.NC.SubscribeSync(alerts)
+	require.NoError(t, err)
+
+	require.NoError(t, tbs.NC.Publish(subject, []byte("one")))
+	require.NoError(t, tbs.NC.Publish(subject, []byte("two")))
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+
+	alert := QueueAlert{}
+	require.NoError(t, json.Unmarshal(msg.Data, &alert))
+	require.Equal(t, DepthAlert, alert.Alert)
+	require.Equal(t, queue, alert.Queue)
+	require.True(t, alert.Active)
+	require.Equal(t, int64(2), alert.Value)
+
+	stats := tbs.Bridge.SafeStats().Connections[0]
+	require.NotNil(t, stats.Queue)
+	require.Equal(t, int32(2), stats.Queue.Depth)
+	require.Equal(t, int64(1), stats.Queue.Alerts)
+
+	for i := 0; i < 2; i++ {
+		_, _, _, err = tbs.GetMessageFromQueue(queue, 5000)
+		require.NoError(t, err)
+	}
+
+	msg, err = sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(msg.Data, &alert))
+	require.False(t, alert.Active)
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package core
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+)
+
+// scheduleCheckInterval is how often a rate limiter looks for a change in its schedule
+const scheduleCheckInterval = time.Second
+
+// rateLimiter is a pair of token buckets, one for messages and one for bytes, each holding up
+// to a second's worth of tokens. Tokens are taken before a message is moved, when a bucket runs
+// out the caller waits until it has refilled. A nil rate limiter never waits.
+type rateLimiter struct {
+	sync.Mutex
+
+	config conf.RateLimitConfig
+	now    func() time.Time
+
+	msgsPerSec  int
+	bytesPerSec int
+	msgs        float64
+	bytes       float64
+	last        time.Time
+	checked     time.Time
+
+	throttled    int64
+	waited       time.Duration
+	waitingUntil time.Time
+}
+
+// newRateLimiter returns a rate limiter for the config, or nil if the config doesn't set any limits
+func newRateLimiter(config conf.RateLimitConfig) *rateLimiter {
+	if !config.Enabled() {
+		return nil
+	}
+
+	rl := &rateLimiter{
+		config: config,
+		now:    time.Now,
+	}
+	rl.setLimits(rl.now())
+	return rl
+}
+
+// setLimits loads the limits for now from the schedule, the buckets start full
+func (rl *rateLimiter) setLimits(now time.Time) {
+	rl.msgsPerSec, rl.bytesPerSec = rl.config.Limits(now)
+	rl.msgs = float64(rl.msgsPerSec)
+	rl.bytes = float64(rl.bytesPerSec)
+	rl.last = now
+	rl.checked = now
+}
+
+// refill adds the tokens earned since the last call, expects the lock to be held
+func (rl *rateLimiter) refill(now time.Time) {
+	if now.Sub(rl.checked) >= scheduleCheckInterval {
+		rl.checked = now
+		if msgs, bytes := rl.config.Limits(now); msgs != rl.msgsPerSec || bytes != rl.bytesPerSec {
+			rl.setLimits(now)
+			return
+		}
+	}
+
+	elapsed := now.Sub(rl.last).Seconds()
+	rl.last = now
+
+	if elapsed <= 0 {
+		return
+	}
+
+	rl.msgs = math.Min(float64(rl.msgsPerSec), rl.msgs+elapsed*float64(rl.msgsPerSec))
+	rl.bytes = math.Min(float64(rl.bytesPerSec), rl.bytes+elapsed*float64(rl.bytesPerSec))
+}
+
+// reserve takes the tokens for a message with size bytes and returns how long the caller has to wait
+// before moving it. Buckets can go negative, so waiting callers are served in order.
+func (rl *rateLimiter) reserve(size int) time.Duration {
+	if rl == nil {
+		return 0
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	rl.refill(now)
+
+	var wait time.Duration
+
+	if rl.msgsPerSec > 0 {
+		rl.msgs--
+		if rl.msgs < 0 {
+			wait = secondsToDuration(-rl.msgs / float64(rl.msgsPerSec))
+		}
+	}
+
+	if rl.bytesPerSec > 0 {
+		rl.bytes -= float64(size)
+		if rl.bytes < 0 {
+			if bw := secondsToDuration(-rl.bytes / float64(rl.bytesPerSec)); bw > wait {
+				wait = bw
+			}
+		}
+	}
+
+	if wait > 0 {
+		rl.throttled++
+		rl.waited += wait
+		if until := now.Add(wait); until.After(rl.waitingUntil) {
+			rl.waitingUntil = until
+		}
+	}
+
+	return wait
+}
+
+// Stats returns the current limits and throttle state, nil if there is no limiter
+func (rl *rateLimiter) Stats() *RateLimitStats {
+	if rl == nil {
+		return nil
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	stats := &RateLimitStats{
+		MessagesPerSecond: rl.msgsPerSec,
+		BytesPerSecond:    rl.bytesPerSec,
+		ThrottledMessages: rl.throttled,
+		Wait:              rl.waited.Nanoseconds(),
+	}
+
+	if remaining := rl.waitingUntil.Sub(rl.now()); remaining > 0 {
+		stats.Throttled = true
+		stats.CurrentWait = remaining.Nanoseconds()
+	}
+
+	return stats
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// throttle blocks until the connector's and the bridge's rate limits let a message with size bytes through
+// The connector's lock should not be held, so monitoring can read the statistics while it waits.
+func (mq *BridgeConnector) throttle(size int) {
+	wait := mq.limiter.reserve(size)
+
+	if global := mq.bridge.limiter.reserve(size); global > wait {
+		wait = global
+	}
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoRateLimit(t *testing.T) {
+	rl := newRateLimiter(conf.RateLimitConfig{})
+	require.Nil(t, rl)
+	require.Equal(t, time.Duration(0), rl.reserve(100))
+	require.Nil(t, rl.Stats())
+}
+
+func TestMessageRateLimit(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.Local)
+	rl := newRateLimiter(conf.RateLimitConfig{MessagesPerSecond: 10})
+	rl.now = func() time.Time { return now }
+	rl.setLimits(now)
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, time.Duration(0), rl.reserve(1))
+	}
+
+	require.Equal(t, 100*time.Millisecond, rl.reserve(1))
+	require.Equal(t, 200*time.Millisecond, rl.reserve(1))
+
+	stats := rl.Stats()
+	require.True(t, stats.Throttled)
+	require.Equal(t, int64(2), stats.ThrottledMessages)
+	require.Equal(t, (300 * time.Millisecond).Nanoseconds(), stats.Wait)
+	require.Equal(t, (200 * time.Millisecond).Nanoseconds(), stats.CurrentWait)
+
+	now = now.Add(500 * time.Millisecond)
+	require.Equal(t, time.Duration(0), rl.reserve(1))
+	require.False(t, rl.Stats().Throttled)
+}
+
+func TestByteRateLimit(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.Local)
+	rl := newRateLimiter(conf.RateLimitConfig{BytesPerSecond: 1000})
+	rl.now = func() time.Time { return now }
+	rl.setLimits(now)
+
+	require.Equal(t, time.Duration(0), rl.reserve(1000))
+	require.Equal(t, 500*time.Millisecond, rl.reserve(500))
+
+	// the bucket never holds more than a second of bytes
+	now = now.Add(10 * time.Second)
+	require.Equal(t, time.Duration(0), rl.reserve(1000))
+	require.Equal(t, time.Second, rl.reserve(1000))
+}
+
+func TestScheduledRateLimit(t *testing.T) {
+	now := time.Date(2019, 6, 1, 8, 59, 59, 0, time.Local)
+	rl := newRateLimiter(conf.RateLimitConfig{
+		MessagesPerSecond: 100,
+		Schedule: []conf.RateLimitWindow{
+			{Start: "09:00", End: "17:00", MessagesPerSecond: 1},
+		},
+	})
+	rl.now = func() time.Time { return now }
+	rl.setLimits(now)
+	require.Equal(t, 100, rl.Stats().MessagesPerSecond)
+
+	now = now.Add(time.Second)
+	require.Equal(t, time.Duration(0), rl.reserve(1))
+	require.Equal(t, 1, rl.Stats().MessagesPerSecond)
+	require.Equal(t, time.Second, rl.reserve(1))
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"math/rand"
+	"time"
+)
+
+// reconnectState tracks the bridge's attempts to restart a connector that was shut down by an error
+type reconnectState struct {
+	connector Connector
+	attempts  int // failed attempts to restart the connector
+	next      time.Time
+	err       error // the error that shut the connector down, or the last failed attempt
+	failed    bool  // true once the bridge has given up
+}
+
+func (state *reconnectState) stats() *ReconnectStats {
+	stats := &ReconnectStats{
+		Attempts:    state.attempts,
+		NextAttempt: state.next,
+		Failed:      state.failed,
+	}
+
+	if state.err != nil {
+		stats.LastError = state.err.Error()
+	}
+
+	return stats
+}
+
+// reconnectDelay returns the wait before the next attempt, the reconnect interval doubles after every failed
+// attempt up to the maximum, then jitter spreads the attempts of connectors that failed at the same time
+func (bridge *BridgeServer) reconnectDelay(attempts int) time.Duration {
+	delay := time.Duration(bridge.config.ReconnectInterval) * time.Millisecond
+	max := time.Duration(bridge.config.MaxReconnectInterval) * time.Millisecond
+
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max && max >= time.Duration(bridge.config.ReconnectInterval)*time.Millisecond {
+		delay = max
+	}
+
+	if jitter := int64(delay) * int64(bridge.config.ReconnectJitter) / 100; jitter > 0 {
+		delay += time.Duration(rand.Int63n(2*jitter+1) - jitter)
+	}
+
+	return delay
+}
+
+// scheduleReconnect hands a connector that was shut down by err to the reconnect timer
+// expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) scheduleReconnect(connector Connector, err error) {
+	state := &reconnectState{
+		connector: connector,
+		err:       err,
+	}
+	bridge.reconnect[connector.ID()] = state
+	bridge.scheduleNextAttempt(state)
+}
+
+// retryReconnect schedules another attempt after a failed one, or marks the connector failed
+// if it has used all of its attempts, expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) retryReconnect(state *reconnectState, err error) {
+	state.attempts++
+	state.err = err
+
+	if max := bridge.config.MaxReconnectAttempts; max > 0 && state.attempts >= max {
+		state.failed = true
+		state.next = time.Time{}
+		state.connector.SetReconnect(state.stats())
+
+		bridge.logger.Errorf("giving up on connector %s after %d attempts to restart it, %s", state.connector.String(), state.attempts, err.Error())
+		bridge.connectorAdvisory(ConnectorFailedAdvisory, state.connector, err)
+		return
+	}
+
+	bridge.scheduleNextAttempt(state)
+}
+
+// scheduleNextAttempt picks the time of the next attempt and makes sure the timer will wake up for it
+// expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) scheduleNextAttempt(state *reconnectState) {
+	delay := bridge.reconnectDelay(state.attempts)
+	state.next = time.Now().Add(delay)
+	state.connector.SetReconnect(state.stats())
+
+	bridge.logger.Noticef("will try to restart connector %s in %s", state.connector.String(), delay.Round(time.Millisecond))
+	bridge.reconnectAdvisory(state.connector, state.err, delay)
+	bridge.ensureReconnectTimer()
+}
+
+// clearReconnect forgets the reconnect state for a connector that is running, paused or removed
+// expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) clearReconnect(id string) {
+	if state, ok := bridge.reconnect[id]; ok {
+		state.connector.SetReconnect(nil)
+		delete(bridge.reconnect, id)
+	}
+}
+
+// nextReconnect returns the time of the earliest attempt the timer should wake up for, if no connectors are
+// waiting the timer still runs once, after the reconnect interval, to bring the NATS and streaming connections back
+// expects the reconnect lock to be held by the caller
+func (bridge *BridgeServer) nextReconnect() time.Time {
+	next := time.Now().Add(time.Duration(bridge.config.ReconnectInterval) * time.Millisecond)
+
+	for _, state := range bridge.reconnect {
+		if !state.failed && state.next.Before(next) {
+			next = state.next
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyConnector fails to start until it has been started a number of times
+type flakyConnector struct {
+	stateConnector
+
+	sync.Mutex
+	failures  int
+	starts    int
+	reconnect *ReconnectStats
+}
+
+func (c *flakyConnector) Start() error {
+	c.Lock()
+	defer c.Unlock()
+	c.starts++
+	if c.starts <= c.failures {
+		return fmt.Errorf("start %d failed", c.starts)
+	}
+	c.state = ConnectorRunning
+	return nil
+}
+
+func (c *flakyConnector) SetReconnect(stats *ReconnectStats) {
+	c.Lock()
+	defer c.Unlock()
+	c.reconnect = stats
+}
+
+func (c *flakyConnector) reconnectStats() (*ReconnectStats, int) {
+	c.Lock()
+	defer c.Unlock()
+	return c.reconnect, c.starts
+}
+
+func startReconnectTestBridge(t *testing.T, connector Connector) *BridgeServer {
+	config := conf.DefaultBridgeConfig()
+	config.ReconnectInterval = 20
+	config.MaxReconnectInterval = 80
+	config.ReconnectJitter = 0
+	config.MaxReconnectAttempts = 3
+
+	bridge := NewBridgeServer()
+	require.NoError(t, bridge.LoadConfig(config))
+	bridge.running = true
+	bridge.reconnect = map[string]*reconnectState{}
+	bridge.connectors = []Connector{connector}
+	return bridge
+}
+
+func TestReconnectDelay(t *testing.T) {
+	bridge := NewBridgeServer()
+	bridge.config.ReconnectInterval = 1000
+	bridge.config.MaxReconnectInterval = 5000
+
+	require.Equal(t, time.Second, bridge.reconnectDelay(0))
+	require.Equal(t, 2*time.Second, bridge.reconnectDelay(1))
+	require.Equal(t, 4*time.Second, bridge.reconnectDelay(2))
+	require.Equal(t, 5*time.Second, bridge.reconnectDelay(3))
+	require.Equal(t, 5*time.Second, bridge.reconnectDelay(100))
+
+	bridge.config.MaxReconnectInterval = 0
+	require.Equal(t, time.Second, bridge.reconnectDelay(5))
+
+	bridge.config.MaxReconnectInterval = 500 // less than the interval, no backoff
+	require.Equal(t, time.Second, bridge.reconnectDelay(5))
+
+	bridge.config.MaxReconnectInterval = 5000
+	bridge.config.ReconnectJitter = 20
+	for i := 0; i < 100; i++ {
+		delay := bridge.reconnectDelay(1)
+		require.True(t, delay >= 1600*time.Millisecond && delay <= 2400*time.Millisecond, delay.String())
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	connector := &flakyConnector{stateConnector: stateConnector{id: "flaky", state: ConnectorStopped}, failures: 100}
+	bridge := startReconnectTestBridge(t, connector)
+	defer bridge.stopReconnectTimer()
+
+	bridge.ConnectorError(connector, fmt.Errorf("connection broken"))
+
+	stats, _ := connector.reconnectStats()
+	require.NotNil(t, stats)
+	require.Equal(t, 0, stats.Attempts)
+	require.Equal(t, "connection broken", stats.LastError)
+	require.False(t, stats.NextAttempt.IsZero())
+	require.True(t, bridge.checkReconnecting())
+
+	require.Eventually(t, func() bool {
+		stats, _ := connector.reconnectStats()
+		return stats.Failed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stats, starts := connector.reconnectStats()
+	require.Equal(t, 3, starts)
+	require.Equal(t, 3, stats.Attempts)
+	require.True(t, stats.NextAttempt.IsZero())
+	require.Equal(t, "start 3 failed", stats.LastError)
+	require.False(t, bridge.checkReconnecting())
+
+	// failed connectors aren't restarted, or shut down again
+	time.Sleep(200 * time.Millisecond)
+	_, starts = connector.reconnectStats()
+	require.Equal(t, 3, starts)
+
+	bridge.ConnectorError(connector, fmt.Errorf("another error"))
+	stats, _ = connector.reconnectStats()
+	require.True(t, stats.Failed)
+}
+
+func TestReconnectBacksOff(t *testing.T) {
+	connector := &flakyConnector{stateConnector: stateConnector{id: "flaky", state: ConnectorStopped}, failures: 2}
+	bridge := startReconnectTestBridge(t, connector)
+	defer bridge.stopReconnectTimer()
+
+	start := time.Now()
+	bridge.ConnectorError(connector, fmt.Errorf("connection broken"))
+
+	require.Eventually(t, func() bool {
+		stats, _ := connector.reconnectStats()
+		return stats == nil
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// 20ms, then 40ms, then 80ms
+	require.True(t, time.Since(start) >= 140*time.Millisecond, time.Since(start).String())
+
+	_, starts := connector.reconnectStats()
+	require.Equal(t, 3, starts)
+	require.False(t, bridge.checkReconnecting())
+}
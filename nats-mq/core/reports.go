@@ -0,0 +1,219 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	nats "github.com/nats-io/nats.go"
+)
+
+// ReportFeedbackConversionFailure is the feedback in exception reports for messages the bridge couldn't convert
+const ReportFeedbackConversionFailure = ibmmq.MQFB_APPL_FIRST
+
+// ReportFeedbackPublishFailure is the feedback in exception reports for messages NATS or streaming rejected
+const ReportFeedbackPublishFailure = ibmmq.MQFB_APPL_FIRST + 1
+
+// reportDataLength is the amount of data included in a report "with data", as defined by MQ
+const reportDataLength = 100
+
+// reportData returns the message data to include in a report based on the report options
+// withData and withFullData are the MQRO values for the report type, i.e. MQRO_COD_WITH_DATA
+func reportData(report int32, withData int32, withFullData int32, data []byte) []byte {
+	if report&withFullData == withFullData {
+		return data
+	}
+
+	if report&withData == withData {
+		if len(data) > reportDataLength {
+			return data[:reportDataLength]
+		}
+		return data
+	}
+
+	return []byte{}
+}
+
+// isPermanentPublishError returns true if publishing the message will never succeed
+func isPermanentPublishError(err error) bool {
+	return errors.Is(err, nats.ErrMaxPayload) || errors.Is(err, nats.ErrBadSubject) || errors.Is(err, nats.ErrInvalidMsg)
+}
+
+// putReport puts a report message for md on its reply to queue
+// syncpoint determines if the report is part of the current unit of work
+func (mq *BridgeConnector) putReport(qMgr *ibmmq.MQQueueManager, md *ibmmq.MQMD, feedback int32, data []byte, syncpoint bool) error {
+	if md.ReplyToQ == "" {
+		return fmt.Errorf("report requested without a reply to queue")
+	}
+
+	reportMD := ibmmq.NewMQMD()
+	reportMD.MsgType = ibmmq.MQMT_REPORT
+	reportMD.Feedback = feedback
+	reportMD.Format = md.Format
+	reportMD.Encoding = md.Encoding
+	reportMD.CodedCharSetId = md.CodedCharSetId
+	reportMD.Priority = md.Priority
+	reportMD.Persistence = md.Persistence
+	reportMD.ReplyToQMgr = qMgr.Name
+
+	pmo := ibmmq.NewMQPMO()
+	if syncpoint {
+		pmo.Options = ibmmq.MQPMO_SYNCPOINT
+	} else {
+		pmo.Options = ibmmq.MQPMO_NO_SYNCPOINT
+	}
+
+	if md.Report&ibmmq.MQRO_PASS_MSG_ID != 0 {
+		reportMD.MsgId = md.MsgId
+	} else {
+		pmo.Options |= ibmmq.MQPMO_NEW_MSG_ID
+	}
+
+	if md.Report&ibmmq.MQRO_PASS_CORREL_ID != 0 {
+		reportMD.CorrelId = md.CorrelId
+	} else {
+		reportMD.CorrelId = md.MsgId
+	}
+
+	if md.Report&ibmmq.MQRO_PASS_DISCARD_AND_EXPIRY != 0 {
+		reportMD.Expiry = md.Expiry
+		reportMD.Report = md.Report & ibmmq.MQRO_DISCARD_MSG
+	}
+
+	mqod := ibmmq.NewMQOD()
+	mqod.ObjectType = ibmmq.MQOT_Q
+	mqod.ObjectName = md.ReplyToQ
+	mqod.ObjectQMgrName = md.ReplyToQMgr
+
+	return qMgr.Put1(mqod, reportMD, pmo, data)
+}
+
+// sendDeliveryReport puts a COD report if the message requested one and reports are enabled
+// the report is part of the unit of work that delivers the message
+func (mq *BridgeConnector) sendDeliveryReport(qMgr *ibmmq.MQQueueManager, md *ibmmq.MQMD, data []byte) {
+	if !mq.config.GenerateReports || md.Report&ibmmq.MQRO_COD == 0 {
+		return
+	}
+
+	data = reportData(md.Report, ibmmq.MQRO_COD_WITH_DATA, ibmmq.MQRO_COD_WITH_FULL_DATA, data)
+
+	if err := mq.putReport(qMgr, md, ibmmq.MQFB_COD, data, true); err != nil {
+		mq.bridge.Logger().Noticef("failed to put delivery report for %s, %s", mq.String(), err.Error())
+	}
+}
+
+// handlePermanentFailure backs out a message that can't be delivered, putting an exception report if
+// the message requested one and reports are enabled. If the message asked to be discarded
+// the report is committed with the get, removing the message from the queue.
+func (mq *BridgeConnector) handlePermanentFailure(qMgr *ibmmq.MQQueueManager, md *ibmmq.MQMD, feedback int32, data []byte) error {
+	if !mq.config.GenerateReports || md.Report&ibmmq.MQRO_EXCEPTION == 0 {
+		return mq.backout(qMgr)
+	}
+
+	data = reportData(md.Report, ibmmq.MQRO_EXCEPTION_WITH_DATA, ibmmq.MQRO_EXCEPTION_WITH_FULL_DATA, data)
+
+	if md.Report&ibmmq.MQRO_DISCARD_MSG != 0 {
+		if err := mq.putReport(qMgr, md, feedback, data, true); err != nil {
+			mq.bridge.Logger().Noticef("failed to put exception report for %s, %s", mq.String(), err.Error())
+			return mq.backout(qMgr)
+		}
+		mq.bridge.Logger().Noticef("discarding undeliverable message on %s", mq.String())
+		return qMgr.Cmit()
+	}
+
+	// Only report the first failure, the message will be redelivered after the back out
+	if md.BackoutCount == 0 {
+		if err := mq.putReport(qMgr, md, feedback, data, false); err != nil {
+			mq.bridge.Logger().Noticef("failed to put exception report for %s, %s", mq.String(), err.Error())
+		}
+	}
+
+	return mq.backout(qMgr)
+}
+
+// startReportListener opens the report queue, on its own queue manager connection, and publishes
+// incoming reports to the report subject. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) startReportListener(conn Connector) error {
+	if mq.config.ReportQueue == "" || mq.config.ReportSubject == "" {
+		return nil
+	}
+
+	qMgr, err := mq.bridge.QueueManagerPool().Acquire(mq.config.MQ, false, conn)
+	if err != nil {
+		return err
+	}
+	mq.reportQMgr = qMgr
+
+	mqod := ibmmq.NewMQOD()
+	mqod.ObjectType = ibmmq.MQOT_Q
+	mqod.ObjectName = mq.config.ReportQueue
+
+	queue, err := qMgr.Open(mqod, ibmmq.MQOO_INPUT_SHARED)
+	if err != nil {
+		return err
+	}
+	mq.reportQueue = &queue
+
+	cb, err := mq.setUpListenerOn(qMgr, mq.reportQueue, mq.reportMessageHandler, conn)
+	if err != nil {
+		return err
+	}
+	mq.reportCB = cb
+
+	mq.bridge.Logger().Tracef("forwarding reports from %s to %s for %s", mq.config.ReportQueue, mq.config.ReportSubject, mq.String())
+	return nil
+}
+
+// stopReportListener closes the report queue and connection, expects the lock to be held by the caller
+func (mq *BridgeConnector) stopReportListener(conn Connector) {
+	if mq.reportCB != nil {
+		if err := mq.reportCB(); err != nil {
+			mq.bridge.Logger().Noticef("error stopping report listener for %s, %s", mq.String(), err.Error())
+		}
+		mq.reportCB = nil
+	}
+
+	if mq.reportQueue != nil {
+		if err := mq.reportQueue.Close(0); err != nil {
+			mq.bridge.Logger().Noticef("error closing report queue for %s, %s", mq.String(), err.Error())
+		}
+		mq.reportQueue = nil
+	}
+
+	if mq.reportQMgr != nil {
+		if err := mq.bridge.QueueManagerPool().Release(mq.reportQMgr, conn); err != nil {
+			mq.bridge.Logger().Noticef("error disconnecting report queue manager for %s, %s", mq.String(), err.Error())
+		}
+		mq.reportQMgr = nil
+	}
+}
+
+func (mq *BridgeConnector) reportMessageHandler(natsMsg []byte, replyTo string, expires time.Time, msgID []byte, putTime time.Time) error {
+	return mq.natsConn().Publish(mq.config.ReportSubject, natsMsg)
+}
+
+// setReportQueue points reports requested by a message headed to MQ at the report queue, unless the
+// message already has a reply to queue
+func (mq *BridgeConnector) setReportQueue(mqmd *ibmmq.MQMD) {
+	if mq.config.ReportQueue == "" || mqmd.ReplyToQ != "" || mqmd.Report == ibmmq.MQRO_NONE {
+		return
+	}
+	mqmd.ReplyToQ = mq.config.ReportQueue
+	mqmd.ReplyToQMgr = mq.config.MQ.QueueManager
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportData(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 2*reportDataLength)
+
+	require.Len(t, reportData(ibmmq.MQRO_COD, ibmmq.MQRO_COD_WITH_DATA, ibmmq.MQRO_COD_WITH_FULL_DATA, data), 0)
+	require.Len(t, reportData(ibmmq.MQRO_COD_WITH_DATA, ibmmq.MQRO_COD_WITH_DATA, ibmmq.MQRO_COD_WITH_FULL_DATA, data), reportDataLength)
+	require.Len(t, reportData(ibmmq.MQRO_COD_WITH_FULL_DATA, ibmmq.MQRO_COD_WITH_DATA, ibmmq.MQRO_COD_WITH_FULL_DATA, data), len(data))
+	require.Len(t, reportData(ibmmq.MQRO_EXCEPTION_WITH_DATA, ibmmq.MQRO_EXCEPTION_WITH_DATA, ibmmq.MQRO_EXCEPTION_WITH_FULL_DATA, data[:10]), 10)
+
+	// COD with data shouldn't include data for exceptions
+	require.Len(t, reportData(ibmmq.MQRO_COD_WITH_DATA|ibmmq.MQRO_EXCEPTION, ibmmq.MQRO_EXCEPTION_WITH_DATA, ibmmq.MQRO_EXCEPTION_WITH_FULL_DATA, data), 0)
+}
+
+func TestPermanentPublishErrors(t *testing.T) {
+	require.True(t, isPermanentPublishError(nats.ErrMaxPayload))
+	require.True(t, isPermanentPublishError(fmt.Errorf("wrapped %w", nats.ErrBadSubject)))
+	require.False(t, isPermanentPublishError(nats.ErrConnectionClosed))
+	require.False(t, isPermanentPublishError(nats.ErrTimeout))
+}
+
+func TestReportsForwardedToSubject(t *testing.T) {
+	reportQueue := "DEV.QUEUE.2"
+	reportSubject := "reports"
+
+	connect := []conf.ConnectorConfig{
+		{
+			Type:          "NATS2Queue",
+			Subject:       "test",
+			Queue:         "DEV.QUEUE.1",
+			ReportQueue:   reportQueue,
+			ReportSubject: reportSubject,
+		},
+	}
+
+	tbs, err := StartTestEnvironment(connect)
+	require.NoError(t, err)
+	defer tbs.Close()
+
+	done := make(chan []byte, 1)
+
+	sub, err := tbs.NC.Subscribe(reportSubject, func(msg *nats.Msg) {
+		done <- msg.Data
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	mqmd := ibmmq.NewMQMD()
+	mqmd.MsgType = ibmmq.MQMT_REPORT
+	mqmd.Feedback = ibmmq.MQFB_COD
+	err = tbs.PutMessageOnQueue(reportQueue, mqmd, []byte("delivered"))
+	require.NoError(t, err)
+
+	var received []byte
+	select {
+	case received = <-done:
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "the report wasn't published")
+	}
+
+	report, err := message.DecodeBridgeMessage(received)
+	require.NoError(t, err)
+	require.Equal(t, "delivered", string(report.Body))
+	require.Equal(t, ibmmq.MQMT_REPORT, report.Header.MsgType)
+	require.Equal(t, ibmmq.MQFB_COD, report.Header.Feedback)
+}
@@ -50,7 +50,7 @@ func (mq *Stan2TopicConnector) Start() error {
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
+	err := mq.connectToSharedMQ(mq)
 	if err != nil {
 		return err
 	}
@@ -63,12 +63,28 @@ func (mq *Stan2TopicConnector) Start() error {
 
 	mq.topic = topicObject
 
-	sub, err := mq.subscribeToChannel(mq.topic)
+	err = mq.startPutWorkers(mq, func(w *connectorWorker) (*ibmmq.MQObject, error) {
+		return w.connectToTopic(mq.config.Topic)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := mq.startReportListener(mq); err != nil {
+		return err
+	}
+
+	sub, err := mq.subscribeToChannel(mq, mq.topic)
 	if err != nil {
 		return err
 	}
 	mq.sub = sub
 
+	if err := mq.startQueueMonitor(mq, mq.sub); err != nil {
+		_ = mq.teardown() // don't leave the listener running, the restart sets it up again
+		return err
+	}
+
 	mq.stats.AddConnect()
 	mq.bridge.Logger().Tracef("opened and reading %s", mq.config.Topic)
 	mq.bridge.Logger().Noticef("started connection %s", mq.String())
@@ -84,11 +100,26 @@ func (mq *Stan2TopicConnector) Shutdown() error {
 
 	mq.bridge.Logger().Noticef("shutting down connection %s", mq.String())
 
-	if mq.sub != nil && mq.config.DurableName == "" { // Don't unsubscribe from durables
-		mq.sub.Unsubscribe()
+	return mq.teardown()
+}
+
+// teardown stops and closes everything Start set up, expects the lock to be held by the caller
+func (mq *Stan2TopicConnector) teardown() error {
+	mq.stopQueueMonitor(mq)
+
+	mq.stopReportListener(mq)
+
+	if mq.sub != nil {
+		if mq.config.DurableName == "" {
+			mq.sub.Unsubscribe()
+		} else {
+			mq.sub.Close() // Don't unsubscribe from durables, closing keeps their position for the restart
+		}
 		mq.sub = nil
 	}
 
+	mq.stopWorkers(mq)
+
 	var err error
 
 	topic := mq.topic
@@ -99,14 +130,23 @@ func (mq *Stan2TopicConnector) Shutdown() error {
 	}
 
 	if mq.qMgr != nil {
-		_ = mq.qMgr.Disc()
-		mq.qMgr = nil
+		_ = mq.disconnectFromMQ(mq)
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return err // ignore the disconnect error
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *Stan2TopicConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *Stan2TopicConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *Stan2TopicConnector) CheckConnections() error {
 	if !mq.bridge.CheckStan() {
@@ -22,37 +22,205 @@ import (
 
 // BridgeStats wraps the current status of the bridge and all of its connectors
 type BridgeStats struct {
-	StartTime    int64            `json:"start_time"`
-	ServerTime   int64            `json:"current_time"`
-	UpTime       string           `json:"uptime"`
-	Connections  []ConnectorStats `json:"connectors"`
-	HTTPRequests map[string]int64 `json:"http_requests"`
+	StartTime       int64                   `json:"start_time"`
+	ServerTime      int64                   `json:"current_time"`
+	UpTime          string                  `json:"uptime"`
+	Connections     []ConnectorStats        `json:"connectors"`
+	NATS            NATSConnectionStats     `json:"nats"`
+	Stan            *StreamingStats         `json:"stan,omitempty"`
+	NATSConnections []NATSConnectionStats   `json:"nats_connections"`
+	MQPool          []QueueManagerPoolStats `json:"mq_pool"`
+	RateLimit       *RateLimitStats         `json:"rate_limit,omitempty"`
+	Startup         *StartupStats           `json:"startup,omitempty"`
+	HA              *HAStats                `json:"ha,omitempty"`
+	HTTPRequests    map[string]int64        `json:"http_requests"`
+}
+
+// QueueManagerPoolStats captures the pooled connections for a single queue manager configuration
+type QueueManagerPoolStats struct {
+	QueueManager        string         `json:"queue_manager"`
+	ConnectionName      string         `json:"connection_name"`
+	ChannelName         string         `json:"channel"`
+	Connections         int            `json:"connections"`
+	Shared              int            `json:"shared"`
+	Exclusive           int            `json:"exclusive"`
+	Connectors          int            `json:"connectors"`
+	Connects            int64          `json:"connects"`
+	Rejected            int64          `json:"rejected"`
+	HealthCheckFailures int64          `json:"health_check_failures"`
+	Instances           map[string]int `json:"instances"` // connections to each queue manager instance
+}
+
+// StreamingStats captures the status of the streaming connection
+type StreamingStats struct {
+	ClusterID string `json:"cluster_id"`
+	ClientID  string `json:"client_id"`
+	Connected bool   `json:"connected"`
+}
+
+// NATSConnectionStats captures the status of a named NATS connection
+type NATSConnectionStats struct {
+	Name        string `json:"name"`
+	Account     string `json:"account"`
+	Connected   bool   `json:"connected"`
+	URL         string `json:"url"`
+	Reconnects  uint64 `json:"reconnects"`
+	MessagesIn  uint64 `json:"msg_in"`
+	MessagesOut uint64 `json:"msg_out"`
+	BytesIn     uint64 `json:"bytes_in"`
+	BytesOut    uint64 `json:"bytes_out"`
 }
 
 // ConnectorStats captures the statistics for a single connector
 type ConnectorStats struct {
-	Name          string  `json:"name"`
-	ID            string  `json:"id"`
+	Name               string          `json:"name"`
+	ID                 string          `json:"id"`
+	Type               string          `json:"type"`
+	State              string          `json:"state"`
+	Connected          bool            `json:"connected"`
+	Connects           int64           `json:"connects"`
+	Disconnects        int64           `json:"disconnects"`
+	MQInstance         string          `json:"mq_instance,omitempty"`
+	MQReconnects       int64           `json:"mq_reconnects"`
+	BytesIn            int64           `json:"bytes_in"`
+	BytesOut           int64           `json:"bytes_out"`
+	MessagesIn         int64           `json:"msg_in"`
+	MessagesOut        int64           `json:"msg_out"`
+	Expired            int64           `json:"expired"`
+	SlowConsumers      int64           `json:"slow_consumers"`
+	Paused             int64           `json:"flow_control_pauses"`
+	Duplicates         int64           `json:"duplicates"`
+	ConversionFailures int64           `json:"conversion_failures"`
+	PutFailures        int64           `json:"put_failures"`
+	PutRetries         int64           `json:"put_retries"`
+	PublishFailures    int64           `json:"publish_failures"`
+	CommitFailures     int64           `json:"commit_failures"`
+	Backouts           int64           `json:"backouts"`
+	RecentErrors       []ErrorStats    `json:"recent_errors,omitempty"`
+	RequestCount       int64           `json:"count"`
+	MovingAverage      float64         `json:"rma"`
+	Quintile50         float64         `json:"q50"`
+	Quintile75         float64         `json:"q75"`
+	Quintile90         float64         `json:"q90"`
+	Quintile95         float64         `json:"q95"`
+	LatencyCount       int64           `json:"latency_count"`
+	LatencyAverage     float64         `json:"latency_rma"`
+	Latency50          float64         `json:"latency_q50"`
+	Latency75          float64         `json:"latency_q75"`
+	Latency90          float64         `json:"latency_q90"`
+	Latency95          float64         `json:"latency_q95"`
+	Workers            []WorkerStats   `json:"workers,omitempty"`
+	Targets            []TargetStats   `json:"targets,omitempty"`
+	RateLimit          *RateLimitStats `json:"rate_limit,omitempty"`
+	Queue              *QueueStats     `json:"queue,omitempty"`
+	Reconnect          *ReconnectStats `json:"reconnect,omitempty"`
+	histogram          *Histogram
+	latency            *Histogram
+}
+
+// WorkerStats captures the statistics for one of a connector's workers
+type WorkerStats struct {
+	Worker        int     `json:"worker"`
 	Connected     bool    `json:"connected"`
-	Connects      int64   `json:"connects"`
-	Disconnects   int64   `json:"disconnects"`
+	MQInstance    string  `json:"mq_instance,omitempty"`
 	BytesIn       int64   `json:"bytes_in"`
 	BytesOut      int64   `json:"bytes_out"`
 	MessagesIn    int64   `json:"msg_in"`
 	MessagesOut   int64   `json:"msg_out"`
+	Expired       int64   `json:"expired"`
 	RequestCount  int64   `json:"count"`
 	MovingAverage float64 `json:"rma"`
-	Quintile50    float64 `json:"q50"`
-	Quintile75    float64 `json:"q75"`
-	Quintile90    float64 `json:"q90"`
-	Quintile95    float64 `json:"q95"`
-	histogram     *Histogram
+	Pending       int     `json:"pending"`
+}
+
+// TargetStats captures the statistics for one of a connector's queue manager targets
+type TargetStats struct {
+	Target       int       `json:"target"`
+	QueueManager string    `json:"queue_manager"`
+	MQInstance   string    `json:"mq_instance,omitempty"`
+	Healthy      bool      `json:"healthy"`
+	Depth        int64     `json:"depth,omitempty"` // the last depth checked plus the messages sent since, for leastdepth
+	NextAttempt  time.Time `json:"next_attempt"`
+	LastError    string    `json:"last_error,omitempty"`
+	BytesOut     int64     `json:"bytes_out"`
+	MessagesOut  int64     `json:"msg_out"`
+	PutFailures  int64     `json:"put_failures"`
+}
+
+// ErrorStats describes one of a connector's recent failures, the reason is the MQ reason code, if there is one
+type ErrorStats struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Reason int32     `json:"reason,omitempty"`
+	Error  string    `json:"error"`
+}
+
+// QueueStats captures the last check of a connector's queue and NATS subscription
+// The age of the oldest message is in milliseconds.
+type QueueStats struct {
+	Queue            string    `json:"queue,omitempty"`
+	Depth            int32     `json:"depth"`
+	OpenInputCount   int32     `json:"open_input_count"`
+	OldestMessageAge int64     `json:"oldest_msg_age"`
+	PendingMsgs      int       `json:"pending_msgs"`
+	PendingBytes     int       `json:"pending_bytes"`
+	Checked          time.Time `json:"checked"`
+	Alerts           int64     `json:"alerts"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// StartupStats summarizes starting the connectors when the bridge started, or reloaded its configuration
+type StartupStats struct {
+	Time       time.Time        `json:"time"`
+	Connectors int              `json:"connectors"`
+	Started    int              `json:"started"`
+	Failed     []StartupFailure `json:"failed"`
+}
+
+// HAStats describes the bridge's high availability role, since is the unix time the bridge took its current role
+type HAStats struct {
+	Role      string `json:"role"`
+	Name      string `json:"name"`             // this bridge's name in the lease
+	Leader    string `json:"leader,omitempty"` // the bridge holding the lease when it was last read
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	Since     int64  `json:"since"`
+	Elections int64  `json:"elections"` // times this bridge became the leader
+	LastError string `json:"last_error,omitempty"`
+}
+
+// StartupFailure describes a connector that couldn't start with the bridge
+type StartupFailure struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// ReconnectStats describes the bridge's attempts to restart a connector after an error
+// The next attempt is zero once the bridge has given up and marked the connector failed.
+type ReconnectStats struct {
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	Failed      bool      `json:"failed"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// RateLimitStats captures the limits and throttle state of a connector's, or the bridge's, rate limit
+// Wait times are in nanoseconds.
+type RateLimitStats struct {
+	MessagesPerSecond int   `json:"msgs_per_sec"`
+	BytesPerSecond    int   `json:"bytes_per_sec"`
+	Throttled         bool  `json:"throttled"`
+	ThrottledMessages int64 `json:"throttled_msgs"`
+	Wait              int64 `json:"wait"`
+	CurrentWait       int64 `json:"current_wait"`
 }
 
 // NewConnectorStats creates an empty stats, and initializes the request time histogram
 func NewConnectorStats() ConnectorStats {
 	return ConnectorStats{
 		histogram: NewHistogram(60),
+		latency:   NewHistogram(60),
 	}
 }
 
@@ -68,6 +236,88 @@ func (stats *ConnectorStats) AddMessageOut(bytes int64) {
 	stats.BytesOut += bytes
 }
 
+// AddExpired updates the expired field, for messages that were discarded because they expired
+func (stats *ConnectorStats) AddExpired() {
+	stats.Expired++
+}
+
+// AddSlowConsumer updates the slow consumers field, for NATS slow consumer errors
+func (stats *ConnectorStats) AddSlowConsumer() {
+	stats.SlowConsumers++
+}
+
+// AddFlowControlPause updates the paused field, for each time the connector stopped reading from MQ
+// because NATS was buffering too much
+func (stats *ConnectorStats) AddFlowControlPause() {
+	stats.Paused++
+}
+
+// AddDuplicate updates the duplicates field, for messages dropped because they were already put
+func (stats *ConnectorStats) AddDuplicate() {
+	stats.Duplicates++
+}
+
+// AddFailure updates the counter for the kind of failure and keeps the failure with the recent errors
+func (stats *ConnectorStats) AddFailure(failure ErrorStats) {
+	switch failure.Kind {
+	case ConversionFailure:
+		stats.ConversionFailures++
+	case PutFailure:
+		stats.PutFailures++
+	case PublishFailure:
+		stats.PublishFailures++
+	case CommitFailure:
+		stats.CommitFailures++
+	}
+
+	// copy so that stats returned to monitoring don't share the array
+	stats.RecentErrors = mergeRecentErrors(stats.RecentErrors, []ErrorStats{failure})
+}
+
+// addCounts adds the message counts, failures and averages from other, used to combine the statistics
+// of a connector's workers or targets, the histograms are merged by the caller
+func (stats *ConnectorStats) addCounts(other ConnectorStats) {
+	stats.BytesIn += other.BytesIn
+	stats.BytesOut += other.BytesOut
+	stats.MessagesIn += other.MessagesIn
+	stats.MessagesOut += other.MessagesOut
+	stats.Expired += other.Expired
+	stats.Duplicates += other.Duplicates
+	stats.ConversionFailures += other.ConversionFailures
+	stats.PutFailures += other.PutFailures
+	stats.PutRetries += other.PutRetries
+	stats.MQReconnects += other.MQReconnects
+	stats.PublishFailures += other.PublishFailures
+	stats.CommitFailures += other.CommitFailures
+	stats.Backouts += other.Backouts
+	stats.RecentErrors = mergeRecentErrors(stats.RecentErrors, other.RecentErrors)
+
+	if count := stats.RequestCount + other.RequestCount; count > 0 {
+		stats.MovingAverage = (stats.MovingAverage*float64(stats.RequestCount) + other.MovingAverage*float64(other.RequestCount)) / float64(count)
+	}
+	stats.RequestCount += other.RequestCount
+
+	if count := stats.LatencyCount + other.LatencyCount; count > 0 {
+		stats.LatencyAverage = (stats.LatencyAverage*float64(stats.LatencyCount) + other.LatencyAverage*float64(other.LatencyCount)) / float64(count)
+	}
+	stats.LatencyCount += other.LatencyCount
+}
+
+// AddMQReconnect updates the mq reconnects field, for connections the MQ client moved on its own
+func (stats *ConnectorStats) AddMQReconnect() {
+	stats.MQReconnects++
+}
+
+// AddPutRetry updates the put retries field, for puts tried again after a transient failure
+func (stats *ConnectorStats) AddPutRetry() {
+	stats.PutRetries++
+}
+
+// AddBackout updates the backouts field, for messages returned to MQ
+func (stats *ConnectorStats) AddBackout() {
+	stats.Backouts++
+}
+
 // AddDisconnect updates the disconnects field
 func (stats *ConnectorStats) AddDisconnect() {
 	stats.Disconnects++
@@ -89,6 +339,15 @@ func (stats *ConnectorStats) AddRequestTime(reqTime time.Duration) {
 	stats.histogram.Add(reqns)
 }
 
+// AddLatency registers the time a message took from being put, or published, to being delivered
+// by the connector, updating the latency count, RMA and histogram
+func (stats *ConnectorStats) AddLatency(latency time.Duration) {
+	ns := float64(latency.Nanoseconds())
+	stats.LatencyCount++
+	stats.LatencyAverage = ((float64(stats.LatencyCount-1) * stats.LatencyAverage) + ns) / float64(stats.LatencyCount)
+	stats.latency.Add(ns)
+}
+
 // UpdateQuintiles updates the quantile fields, these are not updated on each request
 // to reduce the cost of tracking statistics
 func (stats *ConnectorStats) UpdateQuintiles() {
@@ -96,4 +355,8 @@ func (stats *ConnectorStats) UpdateQuintiles() {
 	stats.Quintile75 = stats.histogram.Quantile(0.75)
 	stats.Quintile90 = stats.histogram.Quantile(0.9)
 	stats.Quintile95 = stats.histogram.Quantile(0.95)
+	stats.Latency50 = stats.latency.Quantile(0.5)
+	stats.Latency75 = stats.latency.Quantile(0.75)
+	stats.Latency90 = stats.latency.Quantile(0.9)
+	stats.Latency95 = stats.latency.Quantile(0.95)
 }
@@ -68,3 +68,52 @@ func TestRequestTimes(t *testing.T) {
 	require.Equal(t, float64(dur.Nanoseconds()), stats.MovingAverage)
 	require.Equal(t, int64(1), stats.RequestCount)
 }
+
+func TestLatency(t *testing.T) {
+	stats := NewConnectorStats()
+
+	stats.AddLatency(2 * time.Second)
+	stats.AddLatency(4 * time.Second)
+	stats.UpdateQuintiles()
+
+	require.Equal(t, int64(2), stats.LatencyCount)
+	require.Equal(t, float64((3 * time.Second).Nanoseconds()), stats.LatencyAverage)
+	require.Equal(t, float64((4 * time.Second).Nanoseconds()), stats.Latency95)
+	require.Equal(t, int64(0), stats.RequestCount)
+}
+
+func TestFailureCounts(t *testing.T) {
+	stats := NewConnectorStats()
+	start := time.Now()
+
+	for i := 0; i < 12; i++ {
+		stats.AddFailure(ErrorStats{Time: start.Add(time.Duration(i) * time.Second), Kind: PutFailure, Reason: 2053, Error: "queue full"})
+	}
+	stats.AddFailure(ErrorStats{Time: start.Add(time.Minute), Kind: ConversionFailure, Error: "bad message"})
+	stats.AddFailure(ErrorStats{Time: start.Add(time.Minute), Kind: PublishFailure, Error: "nats down"})
+	stats.AddFailure(ErrorStats{Time: start.Add(time.Minute), Kind: CommitFailure, Error: "commit failed"})
+	stats.AddBackout()
+
+	require.Equal(t, int64(12), stats.PutFailures)
+	require.Equal(t, int64(1), stats.ConversionFailures)
+	require.Equal(t, int64(1), stats.PublishFailures)
+	require.Equal(t, int64(1), stats.CommitFailures)
+	require.Equal(t, int64(1), stats.Backouts)
+
+	require.Len(t, stats.RecentErrors, maxRecentErrors)
+	require.Equal(t, start.Add(5*time.Second), stats.RecentErrors[0].Time)
+	require.Equal(t, int32(2053), stats.RecentErrors[0].Reason)
+	require.Equal(t, CommitFailure, stats.RecentErrors[maxRecentErrors-1].Kind)
+}
+
+func TestMergeRecentErrors(t *testing.T) {
+	start := time.Now()
+	a := []ErrorStats{{Time: start, Kind: PutFailure}, {Time: start.Add(2 * time.Second), Kind: PutFailure}}
+	b := []ErrorStats{{Time: start.Add(time.Second), Kind: ConversionFailure}}
+
+	merged := mergeRecentErrors(a, b)
+	require.Len(t, merged, 3)
+	require.Equal(t, ConversionFailure, merged[1].Kind)
+
+	require.Nil(t, mergeRecentErrors(nil, nil))
+}
@@ -38,6 +38,9 @@ import (
 	stan "github.com/nats-io/stan.go"
 )
 
+// testAdminToken is the admin token for the test bridge's monitoring server
+const testAdminToken = "admin"
+
 // TestEnv encapsulate a bridge test environment
 type TestEnv struct {
 	MQServer *MQTestServer
@@ -116,8 +119,10 @@ func (tbs *TestEnv) StartBridge(connections []conf.ConnectorConfig, useTLS bool)
 	config := conf.DefaultBridgeConfig()
 	//config.Logging.Debug = true
 	//config.Logging.Trace = true
+	config.MaxReconnectInterval = 0 // the reconnect tests wait for a fixed number of intervals
 	config.Monitoring = conf.MonitoringConfig{
-		HTTPPort: -1,
+		HTTPPort:   -1,
+		AdminToken: testAdminToken,
 	}
 	config.NATS = conf.NATSConfig{
 		Servers:        []string{tbs.natsURL},
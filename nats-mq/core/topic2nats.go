@@ -44,13 +44,13 @@ func (mq *Topic2NATSConnector) Start() error {
 	mq.Lock()
 	defer mq.Unlock()
 
-	if !mq.bridge.CheckNATS() {
+	if !mq.checkNATS() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
+	err := mq.connectToMQ(mq)
 	if err != nil {
 		return err
 	}
@@ -117,19 +117,28 @@ func (mq *Topic2NATSConnector) Shutdown() error {
 
 	if mq.qMgr != nil {
 		mq.bridge.Logger().Noticef("shutting down qmgr")
-		if err := mq.qMgr.Disc(); err != nil {
+		if err := mq.disconnectFromMQ(mq); err != nil {
 			mq.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", mq.String(), err.Error())
 		}
-		mq.qMgr = nil
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return nil
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *Topic2NATSConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *Topic2NATSConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *Topic2NATSConnector) CheckConnections() error {
-	if !mq.bridge.CheckNATS() {
+	if !mq.natsAvailable() {
 		return fmt.Errorf("%s connector requires nats to be available", mq.String())
 	}
 	return nil
@@ -50,7 +50,7 @@ func (mq *Topic2StanConnector) Start() error {
 
 	mq.bridge.Logger().Tracef("starting connection %s", mq.String())
 
-	err := mq.connectToMQ()
+	err := mq.connectToMQ(mq)
 	if err != nil {
 		return err
 	}
@@ -117,16 +117,25 @@ func (mq *Topic2StanConnector) Shutdown() error {
 
 	if mq.qMgr != nil {
 		mq.bridge.Logger().Noticef("shutting down qmgr")
-		if err := mq.qMgr.Disc(); err != nil {
+		if err := mq.disconnectFromMQ(mq); err != nil {
 			mq.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", mq.String(), err.Error())
 		}
-		mq.qMgr = nil
 		mq.bridge.Logger().Tracef("disconnected from queue manager for %s", mq.String())
 	}
 
 	return nil
 }
 
+// Pause shuts the connector down until it is resumed
+func (mq *Topic2StanConnector) Pause() error {
+	return mq.pause(mq)
+}
+
+// Resume restarts a paused connector
+func (mq *Topic2StanConnector) Resume() error {
+	return mq.resume(mq)
+}
+
 // CheckConnections ensures the nats/stan connection and report an error if it is down
 func (mq *Topic2StanConnector) CheckConnections() error {
 	if !mq.bridge.CheckStan() {
@@ -0,0 +1,366 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/ibm-messaging/mq-golang/v5/ibmmq"
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+	stan "github.com/nats-io/stan.go"
+)
+
+// workerQueueLength is the number of messages that can wait for each put worker
+const workerQueueLength = 256
+
+// The keys used to keep messages in order across workers
+const (
+	orderBySubject  = "subject"
+	orderByCorrelID = "correlid"
+	orderByGroupID  = "groupid"
+	orderByProperty = "property"
+)
+
+// connectorWorker moves messages for a connector on its own MQ connection and object handle
+// Each worker has its own lock and statistics, so workers don't wait for each other.
+type connectorWorker struct {
+	BridgeConnector
+
+	index      int
+	owner      Connector // restarted when a put shows the worker's connection is broken
+	target     *ibmmq.MQObject
+	shutdownCB ShutdownCallback
+	messages   chan interface{}
+	quit       chan struct{} // closed to stop the worker, messages is never closed so senders can't panic
+	done       chan struct{}
+}
+
+// validateWorkers checks the worker and ordering settings for a connector
+func validateWorkers(config conf.ConnectorConfig) error {
+	if config.Workers < 0 {
+		return fmt.Errorf("workers %d is invalid, expected a positive number", config.Workers)
+	}
+
+	switch strings.ToLower(config.OrderBy) {
+	case "", orderBySubject, orderByCorrelID, orderByGroupID:
+	case orderByProperty:
+		if config.OrderProperty == "" {
+			return fmt.Errorf("ordering by property requires an orderproperty")
+		}
+	default:
+		return fmt.Errorf("unknown orderby %q, expected subject, correlid, groupid or property", config.OrderBy)
+	}
+
+	if config.Workers <= 1 {
+		return nil
+	}
+
+	switch config.Type {
+	case conf.Topic2NATS, conf.Topic2Stan:
+		return fmt.Errorf("%s connectors can't use workers, each worker would get a copy of every message", config.Type)
+	case conf.Queue2NATS, conf.Queue2Stan:
+		if config.OrderBy != "" {
+			return fmt.Errorf("%s connectors can't order messages across workers, MQ shares the messages between them", config.Type)
+		}
+	}
+
+	return nil
+}
+
+// ensureWorkers creates the workers, workers are kept across restarts so their statistics are too
+func (mq *BridgeConnector) ensureWorkers() {
+	for i := len(mq.workers); i < mq.config.Workers; i++ {
+		w := &connectorWorker{
+			index: i,
+		}
+		w.config = mq.config
+		w.bridge = mq.bridge
+		w.putDefaults = mq.putDefaults
+		w.putRules = mq.putRules
+		w.limiter = mq.limiter
+		w.dedup = mq.dedup
+		w.stats = NewConnectorStats()
+		w.stats.Name = fmt.Sprintf("%s worker %d", mq.String(), i)
+		w.stats.ID = mq.stats.ID
+
+		mq.workers = append(mq.workers, w)
+	}
+}
+
+// startPutWorkers starts a worker for each of the configured workers, open is called to open the
+// destination for each worker on its own connection. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) startPutWorkers(conn Connector, open func(w *connectorWorker) (*ibmmq.MQObject, error)) error {
+	if mq.config.Workers <= 1 {
+		return nil
+	}
+
+	mq.stopWorkers(conn)
+	mq.ensureWorkers()
+
+	for _, w := range mq.workers {
+		if err := w.connectToMQ(conn); err != nil {
+			return err
+		}
+
+		target, err := open(w)
+		if err != nil {
+			return err
+		}
+
+		w.target = target
+		w.owner = conn
+		w.messages = make(chan interface{}, workerQueueLength)
+		w.quit = make(chan struct{})
+		w.done = make(chan struct{})
+		w.stats.AddConnect()
+
+		go w.run(w.messages, w.quit, w.done)
+	}
+
+	mq.bridge.Logger().Tracef("started %d workers for %s", len(mq.workers), mq.String())
+	return nil
+}
+
+// startGetWorkers starts a listener on the queue for each of the configured workers, each on its own
+// connection, MQ shares the messages between them. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) startGetWorkers(conn Connector, queueName string, cb func(w *connectorWorker) NATSCallback) error {
+	mq.stopWorkers(conn)
+	mq.ensureWorkers()
+
+	for _, w := range mq.workers {
+		if err := w.startGetWorker(conn, queueName, cb(w)); err != nil {
+			return err
+		}
+	}
+
+	mq.bridge.Logger().Tracef("started %d workers for %s", len(mq.workers), mq.String())
+	return nil
+}
+
+func (w *connectorWorker) startGetWorker(conn Connector, queueName string, cb NATSCallback) error {
+	w.Lock()
+	defer w.Unlock()
+
+	if err := w.connectToMQ(conn); err != nil {
+		return err
+	}
+
+	target, err := w.connectToQueue(queueName, ibmmq.MQOO_INPUT_SHARED)
+	if err != nil {
+		return err
+	}
+	w.target = target
+
+	shutdownCB, err := w.setUpListener(w.target, cb, conn)
+	if err != nil {
+		return err
+	}
+	w.shutdownCB = shutdownCB
+
+	w.stats.AddConnect()
+	return nil
+}
+
+// run puts the messages handed to the worker until it is stopped, then puts the messages that are still waiting
+func (w *connectorWorker) run(messages chan interface{}, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case msg := <-messages:
+			w.put(msg)
+		case <-stop:
+			for {
+				select {
+				case msg := <-messages:
+					w.put(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *connectorWorker) put(msg interface{}) {
+	switch m := msg.(type) {
+	case *nats.Msg:
+		w.putNATSMessage(w.owner, m, w.target)
+	case *stan.Msg:
+		w.putStanMessage(w.owner, m, w.target)
+	}
+}
+
+// stopWorkers waits for the put workers to finish the messages they have, stops the get workers and
+// closes the worker connections. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) stopWorkers(conn Connector) {
+	for _, w := range mq.workers {
+		if w.messages != nil {
+			close(w.quit)
+			<-w.done
+			w.messages = nil
+			w.quit = nil
+		}
+
+		w.stop(conn)
+	}
+}
+
+func (w *connectorWorker) stop(conn Connector) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.qMgr == nil {
+		return // not running
+	}
+
+	if w.shutdownCB != nil {
+		if err := w.shutdownCB(); err != nil {
+			w.bridge.Logger().Noticef("error stopping listener for %s, %s", w.String(), err.Error())
+		}
+		w.shutdownCB = nil
+	}
+
+	if w.target != nil {
+		if err := w.target.Close(0); err != nil {
+			w.bridge.Logger().Noticef("error closing %s, %s", w.String(), err.Error())
+		}
+		w.target = nil
+	}
+
+	if err := w.disconnectFromMQ(conn); err != nil {
+		w.bridge.Logger().Noticef("error disconnecting from queue manager for %s, %s", w.String(), err.Error())
+	}
+
+	w.stats.AddDisconnect()
+}
+
+// dispatchToWorker hands a message to a put worker, returns false if the connector doesn't use workers
+// Messages with the same order key always go to the same worker, other messages are shared round robin.
+// The lock is released before waiting for room in the worker's queue, so a slow worker doesn't block
+// the connector's statistics or shutdown.
+func (mq *BridgeConnector) dispatchToWorker(subject string, data []byte, msg interface{}) bool {
+	if mq.config.Workers <= 1 {
+		return false
+	}
+
+	messages, stop := mq.pickWorker(subject, data)
+	if messages == nil {
+		return true // stopped, drop the message like a closed subscription would
+	}
+
+	select {
+	case messages <- msg:
+	case <-stop:
+	}
+	return true
+}
+
+// pickWorker returns the queue and stop channel for the worker that should put the message, nil if the workers are stopped
+func (mq *BridgeConnector) pickWorker(subject string, data []byte) (chan interface{}, chan struct{}) {
+	mq.Lock()
+	defer mq.Unlock()
+
+	if len(mq.workers) == 0 || mq.workers[0].messages == nil {
+		return nil, nil
+	}
+
+	var w *connectorWorker
+
+	if key, ok := mq.orderKey(subject, data); ok {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		w = mq.workers[h.Sum32()%uint32(len(mq.workers))]
+	} else {
+		mq.nextWorker = (mq.nextWorker + 1) % len(mq.workers)
+		w = mq.workers[mq.nextWorker]
+	}
+
+	return w.messages, w.quit
+}
+
+// orderKey returns the key used to keep messages in order, false is returned if the message doesn't have one
+func (mq *BridgeConnector) orderKey(subject string, data []byte) (string, bool) {
+	orderBy := strings.ToLower(mq.config.OrderBy)
+
+	switch orderBy {
+	case "":
+		return "", false
+	case orderBySubject:
+		return subject, true
+	}
+
+	if mq.config.ExcludeHeaders {
+		return "", false
+	}
+
+	msg, err := message.DecodeBridgeMessage(data)
+	if err != nil {
+		return "", false
+	}
+
+	switch orderBy {
+	case orderByCorrelID:
+		return string(msg.Header.CorrelID), len(msg.Header.CorrelID) > 0
+	case orderByGroupID:
+		return string(msg.Header.GroupID), len(msg.Header.GroupID) > 0
+	case orderByProperty:
+		value, ok := msg.GetTypedProperty(mq.config.OrderProperty)
+		return fmt.Sprint(value), ok
+	}
+
+	return "", false
+}
+
+// workerStats combines the connector's statistics with its workers. Expects the lock to be held by the caller.
+func (mq *BridgeConnector) workerStats() ConnectorStats {
+	stats := mq.stats
+	stats.histogram = NewHistogram(60)
+	stats.histogram.Merge(mq.stats.histogram)
+	stats.latency = NewHistogram(60)
+	stats.latency.Merge(mq.stats.latency)
+	stats.Workers = []WorkerStats{}
+
+	for _, w := range mq.workers {
+		w.Lock()
+		ws := w.stats
+		stats.histogram.Merge(w.stats.histogram)
+		stats.latency.Merge(w.stats.latency)
+		w.Unlock()
+
+		stats.addCounts(ws)
+
+		stats.Workers = append(stats.Workers, WorkerStats{
+			Worker:        w.index,
+			Connected:     ws.Connected,
+			MQInstance:    ws.MQInstance,
+			BytesIn:       ws.BytesIn,
+			BytesOut:      ws.BytesOut,
+			MessagesIn:    ws.MessagesIn,
+			MessagesOut:   ws.MessagesOut,
+			Expired:       ws.Expired,
+			RequestCount:  ws.RequestCount,
+			MovingAverage: ws.MovingAverage,
+			Pending:       len(w.messages),
+		})
+	}
+
+	return stats
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2012-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-mq/message"
+	"github.com/nats-io/nats-mq/nats-mq/conf"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWorkers(t *testing.T) {
+	require.NoError(t, validateWorkers(conf.ConnectorConfig{Type: conf.NATS2Queue, Workers: 4, OrderBy: "subject"}))
+	require.NoError(t, validateWorkers(conf.ConnectorConfig{Type: conf.Queue2NATS, Workers: 4}))
+	require.NoError(t, validateWorkers(conf.ConnectorConfig{Type: conf.Topic2NATS, Workers: 1}))
+
+	require.Error(t, validateWorkers(conf.ConnectorConfig{Type: conf.NATS2Queue, Workers: -1}))
+	require.Error(t, validateWorkers(conf.ConnectorConfig{Type: conf.NATS2Queue, Workers: 4, OrderBy: "size"}))
+	require.Error(t, validateWorkers(conf.ConnectorConfig{Type: conf.NATS2Queue, Workers: 4, OrderBy: "property"}))
+	require.Error(t, validateWorkers(conf.ConnectorConfig{Type: conf.Topic2NATS, Workers: 4}))
+	require.Error(t, validateWorkers(conf.ConnectorConfig{Type: conf.Queue2NATS, Workers: 4, OrderBy: "subject"}))
+}
+
+func TestOrderKey(t *testing.T) {
+	msg := message.NewBridgeMessage([]byte("hello"))
+	msg.Header.CorrelID = []byte("corr")
+	msg.SetProperty("account", "1234")
+	data, err := msg.Encode()
+	require.NoError(t, err)
+
+	connector := &BridgeConnector{}
+
+	connector.config = conf.ConnectorConfig{}
+	_, ok := connector.orderKey("a", data)
+	require.False(t, ok)
+
+	connector.config = conf.ConnectorConfig{OrderBy: "subject"}
+	key, ok := connector.orderKey("a", data)
+	require.True(t, ok)
+	require.Equal(t, "a", key)
+
+	connector.config = conf.ConnectorConfig{OrderBy: "correlid"}
+	key, ok = connector.orderKey("a", data)
+	require.True(t, ok)
+	require.Equal(t, "corr", key)
+
+	connector.config = conf.ConnectorConfig{OrderBy: "groupid"}
+	_, ok = connector.orderKey("a", data)
+	require.False(t, ok)
+
+	connector.config = conf.ConnectorConfig{OrderBy: "property", OrderProperty: "account"}
+	key, ok = connector.orderKey("a", data)
+	require.True(t, ok)
+	require.Equal(t, "1234", key)
+
+	connector.config = conf.ConnectorConfig{OrderBy: "property", OrderProperty: "account", ExcludeHeaders: true}
+	_, ok = connector.orderKey("a", data)
+	require.False(t, ok)
+}
+
+func TestDispatchKeepsKeysOnOneWorker(t *testing.T) {
+	connector := &BridgeConnector{}
+	connector.init(nil, conf.ConnectorConfig{Workers: 4, OrderBy: "subject"}, "test")
+	connector.ensureWorkers()
+
+	for _, w := range connector.workers {
+		w.messages = make(chan interface{}, 100)
+	}
+
+	for i := 0; i < 100; i++ {
+		subject := fmt.Sprintf("subject.%d", i%10)
+		require.True(t, connector.dispatchToWorker(subject, nil, &nats.Msg{Subject: subject}))
+	}
+
+	workers := map[string]int{}
+	total := 0
+
+	for _, w := range connector.workers {
+		close(w.messages)
+		for msg := range w.messages {
+			subject := msg.(*nats.Msg).Subject
+			if index, ok := workers[subject]; ok {
+				require.Equal(t, index, w.index)
+			}
+			workers[subject] = w.index
+			total++
+		}
+		w.messages = nil
+	}
+
+	require.Equal(t, 100, total)
+	require.Len(t, workers, 10)
+
+	// stopped workers drop messages
+	require.True(t, connector.dispatchToWorker("subject.1", nil, &nats.Msg{Subject: "subject.1"}))
+
+	stats := connector.Stats()
+	require.Len(t, stats.Workers, 4)
+}
+
+func TestDispatchWaitsWithoutTheLock(t *testing.T) {
+	connector := &BridgeConnector{}
+	connector.init(nil, conf.ConnectorConfig{Workers: 2}, "test")
+	connector.ensureWorkers()
+
+	for _, w := range connector.workers {
+		w.messages = make(chan interface{}) // nothing reads, like a stuck worker
+		w.quit = make(chan struct{})
+	}
+
+	dispatched := make(chan bool)
+	go func() {
+		dispatched <- connector.dispatchToWorker("test", nil, &nats.Msg{Subject: "test"})
+	}()
+
+	locked := make(chan struct{})
+	go func() {
+		connector.Lock()
+		connector.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		require.FailNow(t, "the dispatch held the connector lock")
+	}
+
+	// stopping the workers releases the dispatch
+	for _, w := range connector.workers {
+		close(w.quit)
+	}
+
+	select {
+	case ok := <-dispatched:
+		require.True(t, ok)
+	case <-time.After(time.Second):
+		require.FailNow(t, "the dispatch didn't return when the workers stopped")
+	}
+}
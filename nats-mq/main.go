@@ -57,19 +57,16 @@ func main() {
 				if server.Logger() != nil {
 					server.Logger().Errorf("received sig-hup, restarting")
 				}
-				server.Stop()
-				server := core.NewBridgeServer()
-				server.LoadConfigFile(configFile)
-				err = server.Start()
+				err = server.Reload()
 
+				// keep running, like a RELOAD request, the old configuration is kept if the file
+				// can't be read and another sig-hup retries a restart that failed
 				if err != nil {
 					if server.Logger() != nil {
-						server.Logger().Errorf("error starting bridge, %s", err.Error())
+						server.Logger().Errorf("error reloading bridge, %s", err.Error())
 					} else {
-						log.Printf("error starting bridge, %s", err.Error())
+						log.Printf("error reloading bridge, %s", err.Error())
 					}
-					server.Stop()
-					os.Exit(0)
 				}
 			}
 		}